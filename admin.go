@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// adminToken is the bearer token required by the admin endpoints that change
+// state, such as the log sampling rates and dead-letter replays. Without it,
+// they only answer requests from the loopback interface.
+var adminToken = envString("ADMIN_TOKEN", "")
+
+// requireAdmin rejects requests to mutating admin endpoints without the
+// ADMIN_TOKEN bearer token, or, when no token is configured, from anywhere
+// but the loopback interface
+func requireAdmin(c *fiber.Ctx) error {
+	if adminToken == "" {
+		if c.Context().RemoteIP().IsLoopback() {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "set ADMIN_TOKEN to change settings from other hosts",
+		})
+	}
+	if !hasBearerToken(c, adminToken) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "missing or invalid admin token",
+		})
+	}
+	return c.Next()
+}
+
+// hasBearerToken reports whether the request carries the token as its
+// bearer token
+func hasBearerToken(c *fiber.Ctx, token string) bool {
+	got, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
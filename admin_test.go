@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestRequireAdmin checks that mutating admin endpoints need the admin token,
+// or a loopback client when no token is configured
+func TestRequireAdmin(t *testing.T) {
+	app := fiber.New()
+	app.Put("/admin", requireAdmin, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	tests := []struct {
+		name   string
+		token  string // ADMIN_TOKEN
+		auth   string // Authorization header
+		status int
+	}{
+		// Requests made with app.Test do not come from the loopback interface
+		{name: "no token configured", status: fiber.StatusForbidden},
+		{name: "missing token", token: "secret", status: fiber.StatusUnauthorized},
+		{name: "wrong token", token: "secret", auth: "Bearer guess", status: fiber.StatusUnauthorized},
+		{name: "token", token: "secret", auth: "Bearer secret", status: fiber.StatusNoContent},
+	}
+	defer func(token string) { adminToken = token }(adminToken)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adminToken = tt.token
+			req := httptest.NewRequest(fiber.MethodPut, "/admin", nil)
+			if tt.auth != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.auth)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d, expected %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+// TestLogSampling checks that PUT requests change the rates given, and
+// neither when one is invalid, and that the current rates are returned
+func TestLogSampling(t *testing.T) {
+	defer func(access, planner int64) {
+		accessLog.rate.Store(access)
+		plannerLog.rate.Store(planner)
+	}(accessLog.rate.Load(), plannerLog.rate.Load())
+
+	app := fiber.New()
+	app.Get("/admin/log-sampling", handleLogSampling)
+	app.Put("/admin/log-sampling", handleLogSampling)
+
+	tests := []struct {
+		method  string
+		query   string
+		status  int
+		access  int64
+		planner int64
+	}{
+		{method: fiber.MethodGet, query: "access=10&planner=5", status: fiber.StatusOK, access: 1, planner: 1},
+		{method: fiber.MethodPut, query: "access=10&planner=5", status: fiber.StatusOK, access: 10, planner: 5},
+		{method: fiber.MethodPut, query: "access=10", status: fiber.StatusOK, access: 10, planner: 1},
+		{method: fiber.MethodPut, query: "planner=7", status: fiber.StatusOK, access: 1, planner: 7},
+		{method: fiber.MethodPut, query: "", status: fiber.StatusOK, access: 1, planner: 1},
+		// An invalid rate leaves both rates unchanged
+		{method: fiber.MethodPut, query: "access=10&planner=0", status: fiber.StatusBadRequest, access: 1, planner: 1},
+		{method: fiber.MethodPut, query: "access=0&planner=10", status: fiber.StatusBadRequest, access: 1, planner: 1},
+		{method: fiber.MethodPut, query: "access=-3", status: fiber.StatusBadRequest, access: 1, planner: 1},
+		{method: fiber.MethodPut, query: "access=10&planner=x", status: fiber.StatusBadRequest, access: 1, planner: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.query, func(t *testing.T) {
+			accessLog.rate.Store(1)
+			plannerLog.rate.Store(1)
+			resp, err := app.Test(httptest.NewRequest(tt.method, "/admin/log-sampling?"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d, expected %d", resp.StatusCode, tt.status)
+			}
+			if a, p := accessLog.rate.Load(), plannerLog.rate.Load(); a != tt.access || p != tt.planner {
+				t.Fatalf("rates are access %d and planner %d, expected %d and %d", a, p, tt.access, tt.planner)
+			}
+			if tt.status != fiber.StatusOK {
+				return
+			}
+			var rates struct{ Access, Planner int64 }
+			if err := json.NewDecoder(resp.Body).Decode(&rates); err != nil {
+				t.Fatal(err)
+			}
+			if rates.Access != tt.access || rates.Planner != tt.planner {
+				t.Fatalf("returned access %d and planner %d, expected %d and %d", rates.Access, rates.Planner, tt.access, tt.planner)
+			}
+		})
+	}
+}
+
+// TestLogSampler checks which entries are kept at each rate
+func TestLogSampler(t *testing.T) {
+	tests := []struct {
+		rate int
+		kept string // Of ten writes, in order
+	}{
+		{rate: 1, kept: "0123456789"},
+		{rate: 0, kept: "0123456789"}, // Rates below 1 log everything
+		{rate: 3, kept: "0369"},
+		{rate: 20, kept: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.rate), func(t *testing.T) {
+			var out bytes.Buffer
+			w := newLogSampler(tt.rate).Writer(&out)
+			for i := 0; i < 10; i++ {
+				if n, err := fmt.Fprint(w, i); err != nil || n != 1 {
+					t.Fatalf("write %d: %d bytes, %v", i, n, err)
+				}
+			}
+			if out.String() != tt.kept {
+				t.Fatalf("kept %q, expected %q", out.String(), tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// mimeNDJSON is the content type of newline delimited JSON responses
+const mimeNDJSON = "application/x-ndjson"
+
+// maxBatchClusters caps the clusters accepted in a single batch request
+var maxBatchClusters = envInt("BATCH_MAX_CLUSTERS", 1000)
+
+// batchWorkers is the number of clusters of a batch planned concurrently
+var batchWorkers = envInt("BATCH_WORKERS", runtime.NumCPU())
+
+// batchCluster is a single cluster of a batch request
+type batchCluster struct {
+	Name   string            `json:"name,omitempty"`   // Echoed in the result to identify the cluster
+	Labels map[string]string `json:"labels,omitempty"` // Matched by ?selector= and grouped by ?group_by=
+	planner.Request
+}
+
+// batchRequest is the request body of /api/plan-batch
+type batchRequest struct {
+	Clusters []batchCluster `json:"clusters"`
+
+	// positions maps the clusters left after the selector to their position
+	// in the request body
+	positions []int
+}
+
+// batchResult is the plan or error for a single cluster of a batch
+type batchResult struct {
+	Index int           `json:"index"` // Position of the cluster in the request
+	Name  string        `json:"name,omitempty"`
+	Plan  *planner.Plan `json:"plan,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Code  string        `json:"code,omitempty"` // Code of the error, see planErrorCode
+}
+
+// batchResponse is the JSON response body of /api/plan-batch
+type batchResponse struct {
+	Results []batchResult   `json:"results"`
+	Effort  *planner.Effort `json:"effort,omitempty"` // Total of the clusters' plans
+}
+
+// addEffort adds the plan's effort estimate to the total
+func (r *batchResponse) addEffort(e *planner.Effort) {
+	r.Effort = sumEffort(r.Effort, e)
+}
+
+// sumEffort adds the estimate to the total, which starts out nil and stays
+// nil until an estimate is added
+func sumEffort(total, e *planner.Effort) *planner.Effort {
+	if e == nil {
+		return total
+	}
+	if total == nil {
+		total = &planner.Effort{}
+	}
+	total.Hours += e.Hours
+	total.Rancher += e.Rancher
+	total.Kubernetes += e.Kubernetes
+	total.Migration += e.Migration
+	return total
+}
+
+// clusterPlanner plans the clusters of a batch, such as a *planner.Planner
+type clusterPlanner interface {
+	PlanContext(ctx context.Context, req planner.Request) (*planner.Plan, error)
+}
+
+// planCluster plans a single cluster of a batch. A panic is reported as the
+// cluster's error so it cannot take down the rest of the batch.
+func planCluster(ctx context.Context, p clusterPlanner, i int, cluster batchCluster) (result batchResult) {
+	result = batchResult{Index: i, Name: cluster.Name}
+	defer func() {
+		if r := recover(); r != nil {
+			reportPanic(panicReport{
+				Time:  time.Now().UTC(),
+				Panic: fmt.Sprintf("planning cluster %d (%s): %v", i, cluster.Name, r),
+				Stack: string(debug.Stack()),
+			})
+			plansTotal.WithLabelValues(outcomeFailed).Inc()
+			result.Plan, result.Error, result.Code = nil, "internal error while planning the cluster", "internal_error"
+		}
+	}()
+	versionsSubmitted.WithLabelValues(cluster.Platform, cluster.CurrentRancher, cluster.CurrentK8s).Inc()
+
+	plan, err := p.PlanContext(ctx, cluster.Request)
+	recordPlanOutcome(err)
+	if err != nil {
+		result.Error = err.Error()
+		result.Code = planErrorCode(err)
+		return result
+	}
+	result.Plan = plan
+	return result
+}
+
+// planBatch plans the clusters on up to workers goroutines and passes every
+// result to emit as soon as it is ready, in completion order. emit is never
+// called concurrently; returning false stops the remaining work. Clusters not
+// planned before ctx is done report the cancellation as their error.
+func planBatch(ctx context.Context, p clusterPlanner, clusters []batchCluster, workers int, emit func(batchResult) bool) {
+	if workers < 1 {
+		workers = 1
+	}
+	// Canceling aborts the plans in progress once emit gives up
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	results := make(chan batchResult)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(clusters); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case results <- planCluster(ctx, p, i, clusters[i]):
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range clusters {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if !emit(r) {
+			close(stop)
+			return
+		}
+	}
+}
+
+// streamBatch plans the batch and writes each result to w as a line of JSON,
+// flushed as soon as it is ready
+func streamBatch(ctx context.Context, p clusterPlanner, req batchRequest, w *bufio.Writer) {
+	enc := json.NewEncoder(w)
+	planBatch(ctx, p, req.Clusters, batchWorkers, func(r batchResult) bool {
+		r.Index = req.positions[r.Index]
+		if err := enc.Encode(r); err != nil {
+			log.Printf("Error encoding batch result: %v", err)
+			return false
+		}
+		// A failed flush means the client went away
+		return w.Flush() == nil
+	})
+}
+
+// readBatchRequest parses and checks the batch in the request body, keeping
+// the clusters whose labels match ?selector=. Clusters without their own
+// language use the request's.
+func readBatchRequest(c *fiber.Ctx) (batchRequest, error) {
+	var req batchRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return req, fmt.Errorf("invalid batch request: %v", err)
+	}
+	if len(req.Clusters) > maxBatchClusters {
+		return req, fmt.Errorf("batch of %d clusters exceeds the limit of %d", len(req.Clusters), maxBatchClusters)
+	}
+	sel, err := parseSelector(c.Query("selector"))
+	if err != nil {
+		return req, err
+	}
+
+	lang := requestLanguage(c)
+	selected := req.Clusters[:0]
+	for i, cluster := range req.Clusters {
+		if !sel.matches(cluster.Labels) {
+			continue
+		}
+		if cluster.Language == "" {
+			cluster.Language = lang
+		}
+		selected = append(selected, cluster)
+		req.positions = append(req.positions, i)
+	}
+	req.Clusters = selected
+	return req, nil
+}
+
+// handlePlanBatch plans every cluster in the request body. Clients accepting
+// application/x-ndjson receive one result per line in completion order, each
+// flushed as soon as it is computed; everyone else receives a single JSON
+// document in request order.
+func handlePlanBatch(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// The whole batch is planned against the same data
+		p := ds.Planner()
+
+		req, err := readBatchRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for _, cluster := range req.Clusters {
+			noteUsage(c, cluster.Request)
+		}
+
+		if c.Accepts(fiber.MIMEApplicationJSON, mimeNDJSON) == mimeNDJSON {
+			c.Set(fiber.HeaderContentType, mimeNDJSON)
+			release := keepPermit(c)
+			parent, conn := c.UserContext(), c.Context().Conn()
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				// The writer runs after the handler returns, so it owns the
+				// timeout and the load shedding slot
+				defer release()
+				ctx, cancel := connContext(parent, conn)
+				defer cancel()
+				streamBatch(ctx, p, req, w)
+			})
+			return nil
+		}
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+		return c.JSON(collectBatch(ctx, p, req))
+	}
+}
+
+// collectBatch plans the batch and returns the results in request order
+func collectBatch(ctx context.Context, p clusterPlanner, req batchRequest) batchResponse {
+	resp := batchResponse{Results: make([]batchResult, len(req.Clusters))}
+	planBatch(ctx, p, req.Clusters, batchWorkers, func(r batchResult) bool {
+		i := r.Index
+		r.Index = req.positions[i]
+		resp.Results[i] = r
+		if r.Plan != nil {
+			resp.addEffort(r.Plan.Effort)
+		}
+		return true
+	})
+	return resp
+}
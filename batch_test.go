@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// writeRecorder records every write reaching it
+type writeRecorder struct {
+	writes [][]byte
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// TestStreamBatch checks that every result is a line of JSON carrying the
+// cluster's position in the request, flushed on its own as it is produced
+func TestStreamBatch(t *testing.T) {
+	defer func(n int) { batchWorkers = n }(batchWorkers)
+	batchWorkers = 1
+
+	req := batchRequest{
+		Clusters: []batchCluster{
+			{Name: "a", Request: planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9+rke2r1"}},
+			{Name: "b", Request: planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "1.x"}},
+			{Name: "c", Request: planner.Request{Platform: "k3s", CurrentRancher: "2.8.5", CurrentK8s: "v1.27.16+k3s1"}},
+		},
+		// As left by a selector that skipped the clusters at 1 and 3
+		positions: []int{0, 2, 4},
+	}
+	rec := &writeRecorder{}
+	// The buffer holds every line, so only flushes reach the recorder
+	streamBatch(context.Background(), testDataset(t).Planner(), req, bufio.NewWriterSize(rec, 1<<20))
+
+	if len(rec.writes) != len(req.Clusters) {
+		t.Fatalf("%d writes, expected one per cluster", len(rec.writes))
+	}
+	for i, line := range rec.writes {
+		if bytes.Count(line, []byte("\n")) != 1 || line[len(line)-1] != '\n' {
+			t.Fatalf("write %d is not a single line: %q", i, line)
+		}
+		var r batchResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		// One worker completes the clusters in order
+		if r.Name != req.Clusters[i].Name || r.Index != req.positions[i] {
+			t.Fatalf("line %d is cluster %q at %d, expected %q at %d", i, r.Name, r.Index, req.Clusters[i].Name, req.positions[i])
+		}
+		if (r.Plan == nil) != (r.Name == "b") {
+			t.Fatalf("cluster %s: plan %v, error %q", r.Name, r.Plan != nil, r.Error)
+		}
+	}
+}
+
+// TestPlanBatchStreamRelease checks that a streamed batch, which keeps its
+// load shedding slot past the handler, releases it once the stream ends
+func TestPlanBatchStreamRelease(t *testing.T) {
+	l := newLimiter("test-batch", 1)
+	app := fiber.New()
+	app.Post("/api/plan-batch", l.handler, handlePlanBatch(testDataset(t)))
+
+	body := `{"clusters": [
+		{"name": "a", "platform": "rke2", "current_rancher": "2.7.5", "current_k8s": "v1.25.9+rke2r1"},
+		{"name": "b", "platform": "k3s", "current_rancher": "2.8.5", "current_k8s": "v1.27.16+k3s1"}
+	]}`
+	req := httptest.NewRequest(fiber.MethodPost, "/api/plan-batch", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAccept, mimeNDJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get(fiber.HeaderContentType); ct != mimeNDJSON {
+		t.Fatalf("content type %q, expected %s", ct, mimeNDJSON)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 2 {
+		t.Fatalf("%d lines, expected one per cluster: %q", len(lines), data)
+	}
+
+	waitFor(t, "the slot to be released", func() bool { return len(l.slots) == 0 })
+}
+
+// fakePlanner plans clusters with plan, tracking how many it plans at once
+type fakePlanner struct {
+	plan func(req planner.Request) (*planner.Plan, error)
+
+	mu                sync.Mutex
+	inFlight, maxSeen int
+}
+
+func (f *fakePlanner) PlanContext(_ context.Context, req planner.Request) (*planner.Plan, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.maxSeen = max(f.maxSeen, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	return f.plan(req)
+}
+
+// panicRecorder is an error reporter keeping the panics it receives
+type panicRecorder struct {
+	mu      sync.Mutex
+	reports []panicReport
+}
+
+func (r *panicRecorder) Report(p panicReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, p)
+}
+
+// recordPanics registers a panicRecorder for the duration of the test
+func recordPanics(t *testing.T) *panicRecorder {
+	r := &panicRecorder{}
+	reportersMu.Lock()
+	saved := reporters
+	reporters = []errorReporter{r}
+	reportersMu.Unlock()
+	t.Cleanup(func() {
+		reportersMu.Lock()
+		reporters = saved
+		reportersMu.Unlock()
+	})
+	return r
+}
+
+// TestPlanBatchIsolation checks that a cluster whose planning panics or
+// fails does not keep the other clusters of the batch from their plans
+func TestPlanBatchIsolation(t *testing.T) {
+	panics := recordPanics(t)
+	real := testDataset(t).Planner()
+	p := &fakePlanner{plan: func(req planner.Request) (*planner.Plan, error) {
+		if req.Platform == "crash" {
+			panic("planner bug")
+		}
+		return real.Plan(req)
+	}}
+	clusters := []batchCluster{
+		{Name: "ok", Request: planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9+rke2r1"}},
+		{Name: "panics", Request: planner.Request{Platform: "crash", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9"}},
+		{Name: "invalid", Request: planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "1.x"}},
+		{Name: "also ok", Request: planner.Request{Platform: "k3s", CurrentRancher: "2.8.5", CurrentK8s: "v1.27.16+k3s1"}},
+	}
+
+	results := make([]batchResult, len(clusters))
+	planBatch(context.Background(), p, clusters, 2, func(r batchResult) bool {
+		results[r.Index] = r
+		return true
+	})
+
+	expected := map[string]string{"ok": "", "panics": "internal_error", "invalid": planner.CodeInvalidVersion, "also ok": ""}
+	for i, r := range results {
+		if r.Name != clusters[i].Name {
+			t.Fatalf("result %d is cluster %q, expected %q", i, r.Name, clusters[i].Name)
+		}
+		code := expected[r.Name]
+		if r.Code != code || (code == "") != (r.Plan != nil) {
+			t.Errorf("cluster %s: code %q and plan %v, expected code %q", r.Name, r.Code, r.Plan != nil, code)
+		}
+	}
+	if len(panics.reports) != 1 || !strings.Contains(panics.reports[0].Panic, "planner bug") {
+		t.Errorf("reported panics %+v, expected the cluster's", panics.reports)
+	}
+}
+
+// TestPlanBatchWorkers checks that clusters are planned on up to the
+// configured number of workers at once
+func TestPlanBatchWorkers(t *testing.T) {
+	clusters := make([]batchCluster, 6)
+	tests := []struct {
+		workers, expected int
+	}{
+		{workers: 0, expected: 1},
+		{workers: 1, expected: 1},
+		{workers: 3, expected: 3},
+		{workers: 10, expected: len(clusters)}, // No more than the clusters
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.workers), func(t *testing.T) {
+			// Every cluster waits until the expected number is in flight, so
+			// the count is reached, but never exceeded, whatever the timing
+			var started sync.WaitGroup
+			started.Add(tt.expected)
+			var once sync.Map
+			p := &fakePlanner{plan: func(req planner.Request) (*planner.Plan, error) {
+				if _, seen := once.LoadOrStore(req.CurrentRancher, true); !seen && len(req.CurrentRancher) <= tt.expected {
+					started.Done()
+					started.Wait()
+				}
+				return &planner.Plan{}, nil
+			}}
+			for i := range clusters {
+				clusters[i].Request.CurrentRancher = strings.Repeat("x", i+1)
+			}
+
+			n := 0
+			planBatch(context.Background(), p, clusters, tt.workers, func(batchResult) bool {
+				n++
+				return true
+			})
+			if n != len(clusters) {
+				t.Fatalf("%d results, expected %d", n, len(clusters))
+			}
+			if p.maxSeen != tt.expected {
+				t.Fatalf("%d clusters planned at once, expected %d", p.maxSeen, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCollectBatchOrder checks that JSON results are in request order, with
+// the positions of the clusters in the request, whatever order they finish
+// in, and that the effort of their plans is summed
+func TestCollectBatchOrder(t *testing.T) {
+	defer func(n int) { batchWorkers = n }(batchWorkers)
+	batchWorkers = 3
+
+	// Clusters finish in reverse order: each waits for the one after it
+	done := make([]chan struct{}, 3)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	p := &fakePlanner{plan: func(req planner.Request) (*planner.Plan, error) {
+		i := len(req.CurrentRancher) - 1
+		if i+1 < len(done) {
+			<-done[i+1]
+		}
+		defer close(done[i])
+		return &planner.Plan{Platform: req.Platform, Effort: &planner.Effort{Hours: 1}}, nil
+	}}
+	req := batchRequest{
+		Clusters: []batchCluster{
+			{Name: "a", Request: planner.Request{CurrentRancher: "x"}},
+			{Name: "b", Request: planner.Request{CurrentRancher: "xx"}},
+			{Name: "c", Request: planner.Request{CurrentRancher: "xxx"}},
+		},
+		positions: []int{1, 3, 4},
+	}
+
+	resp := collectBatch(context.Background(), p, req)
+	for i, r := range resp.Results {
+		if r.Name != req.Clusters[i].Name || r.Index != req.positions[i] {
+			t.Fatalf("result %d is cluster %q at %d, expected %q at %d", i, r.Name, r.Index, req.Clusters[i].Name, req.positions[i])
+		}
+	}
+	if resp.Effort == nil || resp.Effort.Hours != 3 {
+		t.Fatalf("effort %+v, expected 3 hours", resp.Effort)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// loadBlackouts reads the blackout periods from a JSON list or, for .ics
+// files, from the events of an iCalendar export; no file means none
+func loadBlackouts(path string) ([]planner.Blackout, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var blackouts []planner.Blackout
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		blackouts, err = parseICalendar(data)
+	} else {
+		err = json.Unmarshal(data, &blackouts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid blackouts in %s: %v", path, err)
+	}
+	for _, b := range blackouts {
+		if err := b.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return blackouts, nil
+}
+
+// parseICalendar turns the VEVENTs of an iCalendar file into blackouts. Only
+// DTSTART, DTEND and SUMMARY are read; recurring events are not expanded.
+func parseICalendar(data []byte) ([]planner.Blackout, error) {
+	// Unfold continuation lines, which start with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var blackouts []planner.Blackout
+	var event *planner.Blackout
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(name, ";")
+		switch strings.ToUpper(params[0]) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				event = &planner.Blackout{}
+			}
+		case "END":
+			if strings.EqualFold(value, "VEVENT") && event != nil {
+				if event.Start == "" {
+					return nil, fmt.Errorf("event %q has no DTSTART", event.Name)
+				}
+				if event.End == "" {
+					event.End = event.Start
+				}
+				blackouts = append(blackouts, *event)
+				event = nil
+			}
+		case "SUMMARY":
+			if event != nil {
+				event.Name = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+			}
+		case "DTSTART", "DTEND":
+			if event == nil {
+				continue
+			}
+			day, allDay, err := parseICalendarDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of event %q: %v", params[0], event.Name, err)
+			}
+			if strings.EqualFold(params[0], "DTSTART") {
+				event.Start = day.Format("2006-01-02")
+				continue
+			}
+			// The DTEND of all-day events is the day after the last one
+			if allDay {
+				day = day.AddDate(0, 0, -1)
+			}
+			event.End = day.Format("2006-01-02")
+		}
+	}
+	return blackouts, nil
+}
+
+// parseICalendarDate parses an iCalendar DATE or DATE-TIME value, reporting
+// whether it was a DATE
+func parseICalendarDate(value string) (time.Time, bool, error) {
+	if len(value) == len("20060102") {
+		day, err := time.Parse("20060102", value)
+		return day, true, err
+	}
+	day, err := time.Parse("20060102T150405", strings.TrimSuffix(value, "Z"))
+	return day, false, err
+}
+
+// handleBlackouts returns the blackout periods planned dates may not fall in
+func handleBlackouts(blackouts []planner.Blackout) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list := blackouts
+		if list == nil {
+			list = []planner.Blackout{}
+		}
+		return c.JSON(fiber.Map{"blackouts": list})
+	}
+}
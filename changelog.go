@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+var (
+	// changelogAPI is the GitHub API release notes are fetched from
+	changelogAPI = strings.TrimSuffix(envString("CHANGELOG_API_URL", "https://api.github.com"), "/")
+	// githubToken authenticates release note fetches, raising GitHub's rate limit
+	githubToken = envString("GITHUB_TOKEN", "")
+	// changelogs caches fetched release notes across requests
+	changelogs = &changelogCache{
+		ttl:      envDuration("CHANGELOG_CACHE_TTL", 24*time.Hour),
+		errorTTL: 5 * time.Minute,
+		entries:  make(map[string]changelogEntry),
+	}
+)
+
+// changelogRepositories maps components to the repository publishing their releases
+var changelogRepositories = map[string]string{
+	"rancher": "rancher/rancher",
+	"rke2":    "rancher/rke2",
+	"k3s":     "k3s-io/k3s",
+}
+
+// Changelog scopes selected with ?changelog=
+const (
+	changelogRancher = "rancher" // Rancher steps only
+	changelogAll     = "all"     // Rancher and Kubernetes steps
+)
+
+// changelogEntry is a cached fetch result
+type changelogEntry struct {
+	notes   planner.ReleaseNotes
+	err     error
+	fetched time.Time
+}
+
+// changelogCache keeps release notes, and failures for a shorter time, so
+// repeated plans do not refetch them
+type changelogCache struct {
+	ttl, errorTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]changelogEntry
+}
+
+// get returns the notes of the release tagged tag in repo, and whether they
+// were cached
+func (c *changelogCache) get(ctx context.Context, repo, tag string) (planner.ReleaseNotes, bool, error) {
+	key := repo + "@" + tag
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		ttl := c.ttl
+		if e.err != nil {
+			ttl = c.errorTTL
+		}
+		if time.Since(e.fetched) < ttl {
+			return e.notes, true, e.err
+		}
+	}
+
+	notes, err := fetchReleaseNotes(ctx, repo, tag)
+	if ctx.Err() == nil {
+		c.mu.Lock()
+		c.entries[key] = changelogEntry{notes: notes, err: err, fetched: time.Now()}
+		c.mu.Unlock()
+	}
+	return notes, false, err
+}
+
+// fetchReleaseNotes fetches the GitHub release tagged tag in repo
+func fetchReleaseNotes(ctx context.Context, repo, tag string) (planner.ReleaseNotes, error) {
+	u := fmt.Sprintf("%s/repos/%s/releases/tags/%s", changelogAPI, repo, url.PathEscape(tag))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return planner.ReleaseNotes{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if githubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+githubToken)
+	}
+
+	resp, err := outbound.Do(req)
+	if err != nil {
+		return planner.ReleaseNotes{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return planner.ReleaseNotes{}, fmt.Errorf("fetching %s %s: %s", repo, tag, resp.Status)
+	}
+
+	var release struct {
+		HTMLURL string `json:"html_url"`
+		Body    string `json:"body"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return planner.ReleaseNotes{}, fmt.Errorf("decoding %s %s: %v", repo, tag, err)
+	}
+	return planner.ReleaseNotes{Version: tag, URL: release.HTMLURL, Notes: release.Body}, nil
+}
+
+// attachChangelogs adds the release notes of every version each step passes
+// through. Rancher steps cover the Rancher versions in the data; with
+// changelogAll, Kubernetes steps on RKE2 and K3s cover the releases the data
+// lists for the platform. It returns the number of release notes found in the
+// cache and fetched.
+func attachChangelogs(ctx context.Context, plan *planner.Plan, paths planner.UpgradePaths, scope string) (hits, misses int) {
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		var component string
+		var candidates []string
+		switch {
+		case step.Type == "Rancher":
+			component = "rancher"
+			for v := range paths.RancherManager {
+				candidates = append(candidates, v)
+			}
+		case step.Type == "Kubernetes" && scope == changelogAll:
+			component = plan.Platform
+			candidates = paths.Releases[plan.Platform]
+		}
+		repo, ok := changelogRepositories[component]
+		if !ok {
+			continue
+		}
+
+		passed := passedVersions(candidates, step.From, step.To)
+		if len(passed) == 0 {
+			continue
+		}
+		changelog := &planner.Changelog{Releases: []planner.ReleaseNotes{}}
+		for _, v := range passed {
+			tag := "v" + strings.TrimPrefix(v, "v")
+			notes, cached, err := changelogs.get(ctx, repo, tag)
+			if cached {
+				hits++
+			} else {
+				misses++
+			}
+			if err != nil {
+				changelog.Unavailable = append(changelog.Unavailable, tag)
+				continue
+			}
+			changelog.Releases = append(changelog.Releases, notes)
+		}
+		step.Changelog = changelog
+	}
+	return hits, misses
+}
+
+// passedVersions returns the released versions above from up to and including
+// to, ascending. Prereleases are only included when they are the target.
+func passedVersions(candidates []string, from, to string) []string {
+	fromVer, err := version.NewVersion(from)
+	if err != nil {
+		return nil
+	}
+	toVer, err := version.NewVersion(to)
+	if err != nil {
+		return nil
+	}
+
+	var passed []*version.Version
+	for _, c := range candidates {
+		v, err := version.NewVersion(c)
+		if err != nil || !v.GreaterThan(fromVer) || v.GreaterThan(toVer) {
+			continue
+		}
+		if v.Prerelease() != "" && c != to {
+			continue
+		}
+		passed = append(passed, v)
+	}
+	sort.Stable(version.Collection(passed))
+
+	versions := make([]string, 0, len(passed))
+	for _, v := range passed {
+		versions = append(versions, v.Original())
+	}
+	return versions
+}
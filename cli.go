@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner/fixture"
+)
+
+// runCommand runs a CLI subcommand and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "fixtures":
+		return runFixtures(args[1:], os.Stdout)
+	case "data":
+		return runData(args[1:], os.Stdout)
+	case "plan":
+		return runPlan(args[1:], os.Stdout)
+	case "replay":
+		return runReplay(args[1:], os.Stdout)
+	case "update":
+		return runUpdate(args[1:], os.Stdout)
+	case "tui":
+		return runTUI(args[1:], os.Stdin, os.Stdout)
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+// printUsage lists the available subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: rancher-upgrade-tool [command]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Without a command the HTTP service is started. -data names its compatibility")
+	fmt.Fprintln(w, "data file or directory of JSON fragments, overriding UPGRADE_PATHS_FILE.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  fixtures   Run golden planner fixtures against live or fixture data")
+	fmt.Fprintln(w, "  data diff  Compare two data files and the fixture plans they change")
+	fmt.Fprintln(w, "  data import-kdm")
+	fmt.Fprintln(w, "             Generate the data's RKE1, RKE2, and K3s ranges from Rancher KDM")
+	fmt.Fprintln(w, "  plan       Plan an upgrade and print it as a table or JSON")
+	fmt.Fprintln(w, "  replay     Plan recorded requests again and report changed plans")
+	fmt.Fprintln(w, "  tui        Plan an upgrade interactively in the terminal")
+	fmt.Fprintln(w, "  update     Install a newer binary and compatibility data")
+	fmt.Fprintln(w, "  help       Show this help")
+}
+
+// runFixtures runs the golden fixtures and reports every plan that differs
+func runFixtures(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("fixtures", flag.ContinueOnError)
+	dir := fs.String("dir", "pkg/planner/testdata/fixtures", "directory containing fixture files")
+	data := fs.String("data", upgradePathsFile, "dataset to plan against; empty uses each fixture's own dataset")
+	update := fs.Bool("update", false, "rewrite fixture expectations (requires -data=\"\")")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *update && *data != "" {
+		fmt.Fprintln(os.Stderr, "-update only applies to fixture datasets, use it with -data=\"\"")
+		return 2
+	}
+
+	fixtures, err := fixture.Load(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var override func(*fixture.Fixture) fixture.Result
+	if *data != "" {
+		paths, err := loadUpgradePathsFile(*data)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		override = func(f *fixture.Fixture) fixture.Result { return f.RunWith(paths) }
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		var r fixture.Result
+		if override != nil {
+			r = override(f)
+		} else {
+			r = f.Run()
+		}
+
+		if *update {
+			if err := f.Update(r); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			fmt.Fprintf(out, "UPDATED %s\n", f.Name)
+			continue
+		}
+		if r.Passed() {
+			fmt.Fprintf(out, "PASS    %s\n", f.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL    %s (%s)\n%s\n", f.Name, f.Path(), r.Diff)
+	}
+
+	fmt.Fprintf(out, "\n%d fixtures, %d failed\n", len(fixtures), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/gofiber/fiber/v2"
+
+// handleRancherForK8s returns the Rancher versions supporting a Kubernetes
+// version on a platform
+func handleRancherForK8s(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		compat, err := ds.Planner().RancherFor(c.Params("platform"), c.Params("k8s"))
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		return c.JSON(compat)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// datasetETag is the entity tag of responses derived only from the data with
+// the hash. It is weak, as the same data is served in several formats.
+func datasetETag(hash string) string {
+	return `W/"` + hash + `"`
+}
+
+// conditionalOnDataset is middleware for responses derived only from the
+// compatibility data. It tags them with the data hash and load time, and
+// answers conditional requests with 304 while the data is unchanged, so
+// clients polling for updates do not download the same data again.
+func conditionalOnDataset(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_, provenance := ds.Snapshot()
+		etag := datasetETag(provenance.Hash)
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderLastModified, provenance.LoadedAt.Format(http.TimeFormat))
+		if notModified(c, etag, provenance.LoadedAt) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.Next()
+	}
+}
+
+// notModified reports whether the request's preconditions match the current
+// representation. If-None-Match takes precedence over If-Modified-Since, and
+// entity tags are compared weakly.
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		for _, tag := range strings.Split(noneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if modifiedSince := c.Get(fiber.HeaderIfModifiedSince); modifiedSince != "" {
+		since, err := http.ParseTime(modifiedSince)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
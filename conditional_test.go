@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestConditionalOnDataset checks which preconditions answer 304, and that
+// If-None-Match takes precedence over If-Modified-Since
+func TestConditionalOnDataset(t *testing.T) {
+	ds := testDataset(t)
+	_, provenance := ds.Snapshot()
+	etag := datasetETag(provenance.Hash)
+	loaded := provenance.LoadedAt.Format(http.TimeFormat)
+	before := provenance.LoadedAt.Add(-time.Hour).Format(http.TimeFormat)
+
+	app := fiber.New()
+	app.Get("/data", conditionalOnDataset(ds), func(c *fiber.Ctx) error { return c.SendString("data") })
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{name: "unconditional", status: fiber.StatusOK},
+		{name: "matching tag", header: map[string]string{fiber.HeaderIfNoneMatch: etag}, status: fiber.StatusNotModified},
+		{name: "strong form of the tag", header: map[string]string{fiber.HeaderIfNoneMatch: `"` + provenance.Hash + `"`}, status: fiber.StatusNotModified},
+		{name: "tag among others", header: map[string]string{fiber.HeaderIfNoneMatch: `W/"old", ` + etag}, status: fiber.StatusNotModified},
+		{name: "any tag", header: map[string]string{fiber.HeaderIfNoneMatch: "*"}, status: fiber.StatusNotModified},
+		{name: "other tag", header: map[string]string{fiber.HeaderIfNoneMatch: `W/"old"`}, status: fiber.StatusOK},
+		{name: "unmodified since load", header: map[string]string{fiber.HeaderIfModifiedSince: loaded}, status: fiber.StatusNotModified},
+		{name: "modified since", header: map[string]string{fiber.HeaderIfModifiedSince: before}, status: fiber.StatusOK},
+		{name: "invalid date", header: map[string]string{fiber.HeaderIfModifiedSince: "yesterday"}, status: fiber.StatusOK},
+		{
+			name:   "other tag wins over the date",
+			header: map[string]string{fiber.HeaderIfNoneMatch: `W/"old"`, fiber.HeaderIfModifiedSince: loaded},
+			status: fiber.StatusOK,
+		},
+		{
+			name:   "matching tag wins over the date",
+			header: map[string]string{fiber.HeaderIfNoneMatch: etag, fiber.HeaderIfModifiedSince: before},
+			status: fiber.StatusNotModified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/data", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d, expected %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderETag); got != etag {
+				t.Errorf("ETag %q, expected %q", got, etag)
+			}
+			if got := resp.Header.Get(fiber.HeaderLastModified); got != loaded {
+				t.Errorf("Last-Modified %q, expected %q", got, loaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// envString returns the value of the environment variable or the default
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt returns the integer value of the environment variable or the default
+func envInt(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return n
+}
+
+// envFloat returns the floating point value of the environment variable or the default
+func envFloat(key string, def float64) float64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return f
+}
+
+// envDuration returns the duration value of the environment variable or the default
+func envDuration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return d
+}
+
+// envMap returns the comma-separated key=value pairs of the environment variable
+func envMap(key string) map[string]string {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" || strings.TrimSpace(val) == "" {
+			log.Printf("Ignoring invalid entry %q in %s, expected key=value", pair, key)
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+	}
+	return m
+}
+
+// envVersionPolicy returns the planner version policy named by the environment
+// variable, or the planner's default when it is unset or invalid
+func envVersionPolicy(key string) planner.VersionPolicy {
+	policy, err := planner.ParseVersionPolicy(os.Getenv(key))
+	if err != nil {
+		log.Printf("Ignoring invalid %s: %v", key, err)
+		return ""
+	}
+	return policy
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// dashboardUID identifies the generated dashboard in Grafana, so importing a
+// newer version replaces the old one
+const dashboardUID = "rancher-upgrade-tool"
+
+// dashboardSkipPrefixes are metric families left off the dashboard: runtime
+// metrics already covered by the standard Go and process dashboards
+var dashboardSkipPrefixes = []string{"go_", "process_", "promhttp_"}
+
+// dashboardSkipLabels are labels the dashboard does not break series down by,
+// because their cardinality is unbounded or they are constant
+var dashboardSkipLabels = map[string]bool{
+	"rancher_version": true,
+	"k8s_version":     true,
+	"service":         true,
+}
+
+// grafanaPanel is a single panel of the generated dashboard
+type grafanaPanel struct {
+	ID          int              `json:"id"`
+	Type        string           `json:"type"`
+	Title       string           `json:"title"`
+	Description string           `json:"description,omitempty"`
+	GridPos     grafanaGridPos   `json:"gridPos"`
+	Datasource  grafanaDSRef     `json:"datasource"`
+	Targets     []grafanaTarget  `json:"targets"`
+	FieldConfig grafanaFieldConf `json:"fieldConfig"`
+}
+
+// grafanaGridPos places a panel on Grafana's 24 column grid
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// grafanaDSRef selects the data source a panel queries
+type grafanaDSRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// grafanaTarget is a single PromQL query of a panel
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+// grafanaFieldConf sets how a panel displays its values
+type grafanaFieldConf struct {
+	Defaults struct {
+		Unit string `json:"unit,omitempty"`
+	} `json:"defaults"`
+}
+
+// grafanaDashboard is the subset of Grafana's dashboard model the service emits
+type grafanaDashboard struct {
+	UID           string         `json:"uid"`
+	Title         string         `json:"title"`
+	Tags          []string       `json:"tags"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Time          map[string]any `json:"time"`
+	Refresh       string         `json:"refresh"`
+	Templating    map[string]any `json:"templating"`
+	Panels        []grafanaPanel `json:"panels"`
+}
+
+// buildDashboard generates a panel for every metric family the gatherer
+// reports, so the dashboard follows the metrics the code registers
+func buildDashboard(gatherer prometheus.Gatherer) (grafanaDashboard, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return grafanaDashboard{}, err
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+
+	d := grafanaDashboard{
+		UID:           dashboardUID,
+		Title:         "Rancher Upgrade Tool",
+		Tags:          []string{"rancher-upgrade-tool"},
+		SchemaVersion: 39,
+		Time:          map[string]any{"from": "now-6h", "to": "now"},
+		Refresh:       "1m",
+		Templating: map[string]any{"list": []map[string]any{{
+			"name":  "datasource",
+			"label": "Data source",
+			"type":  "datasource",
+			"query": "prometheus",
+		}}},
+	}
+	for _, mf := range families {
+		if skipFamily(mf.GetName()) {
+			continue
+		}
+		panel, ok := panelFor(mf)
+		if !ok {
+			continue
+		}
+		n := len(d.Panels)
+		panel.ID = n + 1
+		panel.GridPos = grafanaGridPos{H: 8, W: 12, X: (n % 2) * 12, Y: (n / 2) * 8}
+		panel.Datasource = grafanaDSRef{Type: "prometheus", UID: "${datasource}"}
+		d.Panels = append(d.Panels, panel)
+	}
+	return d, nil
+}
+
+// skipFamily reports whether the metric family is left off the dashboard
+func skipFamily(name string) bool {
+	for _, prefix := range dashboardSkipPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// panelFor charts the metric family according to its type
+func panelFor(mf *dto.MetricFamily) (grafanaPanel, bool) {
+	name := mf.GetName()
+	by := breakdownLabels(mf)
+	legend := legendFormat(by)
+	panel := grafanaPanel{Type: "timeseries", Title: name, Description: mf.GetHelp()}
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		panel.Targets = []grafanaTarget{{Expr: sumBy(by, fmt.Sprintf("rate(%s[$__rate_interval])", name)), LegendFormat: legend, RefID: "A"}}
+		panel.FieldConfig.Defaults.Unit = "ops"
+	case dto.MetricType_GAUGE:
+		if strings.HasSuffix(name, "_timestamp_seconds") {
+			// Chart how long ago the timestamp was, e.g. the age of the data
+			panel.Type = "stat"
+			panel.Targets = []grafanaTarget{{Expr: fmt.Sprintf("time() - max(%s)", name), RefID: "A"}}
+			panel.FieldConfig.Defaults.Unit = "s"
+			break
+		}
+		panel.Targets = []grafanaTarget{{Expr: sumBy(by, name), LegendFormat: legend, RefID: "A"}}
+	case dto.MetricType_HISTOGRAM:
+		by = append([]string{"le"}, by...)
+		for i, q := range []struct{ quantile, name string }{{"0.5", "p50"}, {"0.95", "p95"}, {"0.99", "p99"}} {
+			panel.Targets = append(panel.Targets, grafanaTarget{
+				Expr:         fmt.Sprintf("histogram_quantile(%s, %s)", q.quantile, sumBy(by, fmt.Sprintf("rate(%s_bucket[$__rate_interval])", name))),
+				LegendFormat: strings.TrimSpace(q.name + " " + legend),
+				RefID:        string(rune('A' + i)),
+			})
+		}
+		if strings.HasSuffix(name, "_seconds") {
+			panel.FieldConfig.Defaults.Unit = "s"
+		}
+	default:
+		return grafanaPanel{}, false
+	}
+	return panel, true
+}
+
+// breakdownLabels returns the sorted labels the family's series carry,
+// without the ones listed in dashboardSkipLabels
+func breakdownLabels(mf *dto.MetricFamily) []string {
+	seen := make(map[string]bool)
+	for _, m := range mf.GetMetric() {
+		for _, l := range m.GetLabel() {
+			if !dashboardSkipLabels[l.GetName()] {
+				seen[l.GetName()] = true
+			}
+		}
+	}
+	labels := make([]string, 0, len(seen))
+	for l := range seen {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// sumBy wraps the expression in a sum over the labels
+func sumBy(labels []string, expr string) string {
+	if len(labels) == 0 {
+		return "sum(" + expr + ")"
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(labels, ", "), expr)
+}
+
+// legendFormat names series by their labels
+func legendFormat(labels []string) string {
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if l != "le" {
+			parts = append(parts, "{{"+l+"}}")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// handleGrafanaDashboard serves a Grafana dashboard for the service's metrics
+func handleGrafanaDashboard(c *fiber.Ctx) error {
+	d, err := buildDashboard(prometheus.DefaultGatherer)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(d)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner/fixture"
+)
+
+// runData runs a data maintenance subcommand
+func runData(args []string, out io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			return runDataDiff(args[1:], out)
+		case "import-kdm":
+			return runImportKDM(args[1:], out)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: rancher-upgrade-tool data diff [-fixtures dir] [-v] <old.json> <new.json>")
+	fmt.Fprintln(os.Stderr, "       rancher-upgrade-tool data import-kdm [-kdm url-or-file]... [-base file] [-rancher versions] [-rancher-releases] [-o file]")
+	return 2
+}
+
+// rangeChange is how the Kubernetes range a Rancher version supports on a
+// platform changes between two datasets. Before or After is empty when the
+// platform is only supported in the other.
+type rangeChange struct {
+	Rancher  string
+	Platform string
+	Before   string
+	After    string
+}
+
+// runDataDiff prints the Rancher versions added and removed between two data
+// files, the supported Kubernetes ranges that changed, and the golden
+// fixtures whose plans the change alters
+func runDataDiff(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("data diff", flag.ContinueOnError)
+	dir := fs.String("fixtures", "pkg/planner/testdata/fixtures", "directory containing fixture files; empty skips them")
+	verbose := fs.Bool("v", false, "print how each changed fixture plan changes")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: rancher-upgrade-tool data diff [-fixtures dir] [-v] <old.json> <new.json>")
+		return 2
+	}
+
+	before, err := loadUpgradePathsFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	after, err := loadUpgradePathsFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	added, removed := diffRancherVersions(before, after)
+	fmt.Fprintf(out, "Rancher versions added (%d)\n", len(added))
+	for _, v := range added {
+		fmt.Fprintf(out, "  + %s\n", v)
+	}
+	fmt.Fprintf(out, "Rancher versions removed (%d)\n", len(removed))
+	for _, v := range removed {
+		fmt.Fprintf(out, "  - %s\n", v)
+	}
+
+	changes := diffRanges(before, after)
+	fmt.Fprintf(out, "Supported ranges changed (%d)\n", len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(out, "  %s %s: %s -> %s\n", c.Rancher, c.Platform, orNone(c.Before), orNone(c.After))
+	}
+
+	if *dir == "" {
+		return 0
+	}
+	fixtures, err := fixture.Load(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var changed []string
+	var diffs []string
+	for _, f := range fixtures {
+		if diff := f.Compare(before, after); diff != "" {
+			changed = append(changed, fmt.Sprintf("%s (%s)", f.Name, f.Path()))
+			diffs = append(diffs, diff)
+		}
+	}
+	fmt.Fprintf(out, "Fixture plans changed (%d of %d)\n", len(changed), len(fixtures))
+	for i, name := range changed {
+		fmt.Fprintf(out, "  %s\n", name)
+		if *verbose {
+			fmt.Fprintln(out, diffs[i])
+		}
+	}
+	return 0
+}
+
+// diffRancherVersions returns the Rancher versions only the new data lists
+// and those only the old data lists, in version order
+func diffRancherVersions(before, after planner.UpgradePaths) (added, removed []string) {
+	for v := range after.RancherManager {
+		if _, ok := before.RancherManager[v]; !ok {
+			added = append(added, v)
+		}
+	}
+	for v := range before.RancherManager {
+		if _, ok := after.RancherManager[v]; !ok {
+			removed = append(removed, v)
+		}
+	}
+	sortVersions(added)
+	sortVersions(removed)
+	return added, removed
+}
+
+// diffRanges returns the platform ranges that changed for the Rancher
+// versions both datasets list, ordered by Rancher version and platform
+func diffRanges(before, after planner.UpgradePaths) []rangeChange {
+	var common []string
+	for v := range before.RancherManager {
+		if _, ok := after.RancherManager[v]; ok {
+			common = append(common, v)
+		}
+	}
+	sortVersions(common)
+
+	var changes []rangeChange
+	for _, v := range common {
+		old := platformRanges(before.RancherManager[v])
+		cur := platformRanges(after.RancherManager[v])
+		platforms := make([]string, 0, len(old)+len(cur))
+		for p := range old {
+			platforms = append(platforms, p)
+		}
+		for p := range cur {
+			if _, ok := old[p]; !ok {
+				platforms = append(platforms, p)
+			}
+		}
+		sort.Strings(platforms)
+		for _, p := range platforms {
+			if old[p] != cur[p] {
+				changes = append(changes, rangeChange{Rancher: v, Platform: p, Before: old[p], After: cur[p]})
+			}
+		}
+	}
+	return changes
+}
+
+// platformRanges returns the Kubernetes ranges a Rancher version supports,
+// keyed by lowercase platform name. A platform listed more than once has its
+// ranges joined.
+func platformRanges(r planner.RancherManagerVersion) map[string]string {
+	ranges := make(map[string]string)
+	for _, p := range r.SupportedPlatforms {
+		platform := strings.ToLower(p.Platform)
+		span := p.MinVersion + " - " + p.MaxVersion
+		if ranges[platform] != "" {
+			span = ranges[platform] + ", " + span
+		}
+		ranges[platform] = span
+	}
+	return ranges
+}
+
+// sortVersions sorts versions semantically, with unparsable ones last
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, errA := version.NewVersion(versions[i])
+		b, errB := version.NewVersion(versions[j])
+		if errA != nil || errB != nil {
+			return errA == nil || (errB != nil && versions[i] < versions[j])
+		}
+		return a.LessThan(b)
+	})
+}
+
+// orNone returns s, or "none" when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// dataset holds the planner for the current compatibility data and replaces
+// it when the data is reloaded
+type dataset struct {
+	path string
+	opts planner.Options
+
+	mu       sync.RWMutex
+	paths    planner.UpgradePaths
+	planner  *planner.Planner
+	source   string // Where the current data came from: the file, or the primary of a replica
+	loadedAt time.Time
+	missing  map[string]string // Announced release tags not in the data, by tag, to their component
+}
+
+// loadDataset loads the compatibility data at path and builds its planner
+func loadDataset(path string, opts planner.Options) (*dataset, error) {
+	ds := &dataset{path: path, opts: opts, missing: make(map[string]string)}
+	if err := ds.Reload(); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// Planner returns the planner for the current data
+func (ds *dataset) Planner() *planner.Planner {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.planner
+}
+
+// Current returns the planner and the data it plans with
+func (ds *dataset) Current() (*planner.Planner, planner.UpgradePaths) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.planner, ds.paths
+}
+
+// Snapshot returns the current data and where it comes from
+func (ds *dataset) Snapshot() (planner.UpgradePaths, dataProvenance) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.paths, dataProvenance{
+		Source:   ds.source,
+		Hash:     ds.planner.DatasetHash(),
+		Version:  ds.paths.Version,
+		LoadedAt: ds.loadedAt,
+	}
+}
+
+// WhatIf returns a planner for the current data with the overrides applied.
+// The overrides are not kept.
+func (ds *dataset) WhatIf(overrides planner.UpgradePaths) *planner.Planner {
+	ds.mu.RLock()
+	paths := ds.paths
+	ds.mu.RUnlock()
+	return planner.New(planner.Merge(paths, overrides), ds.opts)
+}
+
+// Reload reads the data file again and swaps in a planner for it. Plans in
+// progress finish with the planner they started with. On error the current
+// data stays in place.
+func (ds *dataset) Reload() error {
+	paths, err := loadUpgradePathsFile(ds.path)
+	if err != nil {
+		return err
+	}
+	ds.Replace(paths, ds.path)
+	return nil
+}
+
+// Replace swaps in a planner for data obtained elsewhere, such as from the
+// primary of a replica
+func (ds *dataset) Replace(paths planner.UpgradePaths, source string) {
+	p := planner.New(paths, ds.opts)
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.paths, ds.planner, ds.source, ds.loadedAt = paths, p, source, time.Now().UTC()
+	for tag, component := range ds.missing {
+		if hasRelease(paths, component, tag) {
+			delete(ds.missing, tag)
+		}
+	}
+	datasetLoadedTimestamp.SetToCurrentTime()
+	datasetMissingReleases.Set(float64(len(ds.missing)))
+}
+
+// CheckRelease reports whether the data lists the release of the component
+// (rancher, rke2, or k3s), remembering it as missing until a reload finds it
+func (ds *dataset) CheckRelease(component, tag string) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if hasRelease(ds.paths, component, tag) {
+		return true
+	}
+	ds.missing[tag] = component
+	datasetMissingReleases.Set(float64(len(ds.missing)))
+	return false
+}
+
+// hasRelease reports whether the data lists the release: Rancher releases as
+// a rancher_manager version, Kubernetes distribution releases in releases
+func hasRelease(paths planner.UpgradePaths, component, tag string) bool {
+	want, err := version.NewVersion(tag)
+	if err != nil {
+		return false
+	}
+	if component == "rancher" {
+		for v := range paths.RancherManager {
+			if known, err := version.NewVersion(v); err == nil && known.Equal(want) {
+				return true
+			}
+		}
+		return false
+	}
+	for name, releases := range paths.Releases {
+		if !strings.EqualFold(name, component) {
+			continue
+		}
+		for _, r := range releases {
+			if strings.EqualFold(strings.TrimPrefix(r, "v"), strings.TrimPrefix(tag, "v")) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Delivery settings of outbound webhooks
+var (
+	webhookQueueSize    = envInt("WEBHOOK_QUEUE_SIZE", 1000)
+	webhookMaxAttempts  = envInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	webhookRetryBackoff = envDuration("WEBHOOK_RETRY_BACKOFF", time.Minute)
+	webhookMaxBackoff   = envDuration("WEBHOOK_MAX_BACKOFF", time.Hour)
+	webhookDeadLetters  = envInt("WEBHOOK_DEAD_LETTERS", 1000)
+	webhookQueueDir     = envString("WEBHOOK_QUEUE_DIR", "")
+)
+
+// Subdirectories of WEBHOOK_QUEUE_DIR holding a file per delivery
+const (
+	deliveriesPending = "pending"
+	deliveriesDead    = "dead"
+)
+
+// Delivery outcomes counted by webhook_deliveries_total
+const (
+	deliveryDelivered    = "delivered"
+	deliveryRetried      = "retried"
+	deliveryDeadLettered = "dead_lettered"
+)
+
+// webhooks queues the outbound webhook deliveries
+var webhooks *deliveryQueue
+
+// delivery is an outbound webhook and its delivery attempts
+type delivery struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	URL       string          `json:"url"`
+	Body      json.RawMessage `json:"body"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  time.Time       `json:"failed_at,omitempty"`
+	// NextAttempt is when a delivery waiting for a retry is queued again
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+}
+
+// deliveryQueue sends webhooks in the background. Failed deliveries are
+// retried with exponential backoff on top of the outbound client's own
+// retries, and moved to a bounded dead-letter list after the last attempt,
+// where they can be inspected and replayed. With a directory, pending and
+// dead-lettered deliveries are saved to it as they change and restored on
+// start, so they survive restarts.
+type deliveryQueue struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxDead     int
+	dir         string // Empty keeps the deliveries in memory only
+	queue       chan *delivery
+
+	mu   sync.Mutex
+	dead []*delivery // Oldest first
+}
+
+// newDeliveryQueue creates a queue holding up to size pending deliveries,
+// restoring those saved to dir unless it is empty
+func newDeliveryQueue(size, maxAttempts int, backoff, maxBackoff time.Duration, maxDead int, dir string) (*deliveryQueue, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	q := &deliveryQueue{
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  max(maxBackoff, backoff),
+		maxDead:     maxDead,
+		dir:         dir,
+		queue:       make(chan *delivery, size),
+	}
+	if dir == "" {
+		return q, nil
+	}
+	for _, state := range []string{deliveriesPending, deliveriesDead} {
+		if err := os.MkdirAll(filepath.Join(dir, state), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.restore(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// restore loads the saved dead letters, and queues the saved pending
+// deliveries again, oldest first, keeping the retry time of those waiting for
+// one
+func (q *deliveryQueue) restore() error {
+	dead, err := q.loadSaved(deliveriesDead)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(dead, func(i, j int) bool { return dead[i].FailedAt.Before(dead[j].FailedAt) })
+	for _, d := range dead {
+		q.keepDead(d)
+	}
+
+	pending, err := q.loadSaved(deliveriesPending)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	for _, d := range pending {
+		if wait := time.Until(d.NextAttempt); wait > 0 {
+			time.AfterFunc(wait, func() { q.push(d) })
+			continue
+		}
+		q.push(d)
+	}
+	if len(pending)+len(dead) > 0 {
+		log.Printf("Restored %d pending and %d dead-lettered webhook deliveries from %s", len(pending), len(dead), q.dir)
+	}
+	return nil
+}
+
+// loadSaved reads the deliveries saved in the state's directory
+func (q *deliveryQueue) loadSaved(state string) ([]*delivery, error) {
+	files, err := filepath.Glob(filepath.Join(q.dir, state, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var list []*delivery
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("failed to parse saved delivery %s: %v", file, err)
+		}
+		list = append(list, &d)
+	}
+	return list, nil
+}
+
+// save writes the delivery to the state's directory, replacing the file
+// atomically, and removes it from the other state's
+func (q *deliveryQueue) save(state string, d *delivery) {
+	if q.dir == "" {
+		return
+	}
+	data, err := json.Marshal(d)
+	if err == nil {
+		tmp := filepath.Join(q.dir, state, d.ID+".json.tmp")
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, filepath.Join(q.dir, state, d.ID+".json"))
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save webhook delivery %s: %v", d.ID, err)
+	}
+	other := deliveriesDead
+	if state == deliveriesDead {
+		other = deliveriesPending
+	}
+	q.unsave(other, d.ID)
+}
+
+// unsave removes the saved delivery from the state's directory
+func (q *deliveryQueue) unsave(state, id string) {
+	if q.dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(q.dir, state, id+".json")); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove saved webhook delivery %s: %v", id, err)
+	}
+}
+
+// retryDelay is the backoff before the next attempt of a delivery that
+// failed attempts times: the backoff doubled per earlier failure, capped at
+// maxBackoff
+func (q *deliveryQueue) retryDelay(attempts int) time.Duration {
+	delay := q.backoff
+	for i := 1; i < attempts && delay < q.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.maxBackoff)
+}
+
+// Enqueue queues the payload for delivery to the URL as JSON
+func (q *deliveryQueue) Enqueue(event, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	d := &delivery{
+		ID:        hex.EncodeToString(id),
+		Event:     event,
+		URL:       url,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	q.save(deliveriesPending, d)
+	q.push(d)
+	return nil
+}
+
+// push queues the delivery, dead-lettering it when the queue is full
+func (q *deliveryQueue) push(d *delivery) {
+	select {
+	case q.queue <- d:
+	default:
+		d.LastError = "delivery queue is full"
+		q.deadLetter(d)
+	}
+}
+
+// run sends the queued deliveries until ctx is done
+func (q *deliveryQueue) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-q.queue:
+			q.attempt(ctx, d)
+		}
+	}
+}
+
+// attempt sends the delivery once, scheduling a retry or dead-lettering it
+// on failure
+func (q *deliveryQueue) attempt(ctx context.Context, d *delivery) {
+	d.Attempts++
+	err := q.send(ctx, d)
+	if err == nil {
+		webhookDeliveries.WithLabelValues(deliveryDelivered).Inc()
+		q.unsave(deliveriesPending, d.ID)
+		return
+	}
+	d.LastError = err.Error()
+	if d.Attempts >= q.maxAttempts {
+		log.Printf("Giving up on %s webhook %s to %s after %d attempts: %v", d.Event, d.ID, d.URL, d.Attempts, err)
+		q.deadLetter(d)
+		return
+	}
+	webhookDeliveries.WithLabelValues(deliveryRetried).Inc()
+	delay := q.retryDelay(d.Attempts)
+	d.NextAttempt = time.Now().UTC().Add(delay)
+	q.save(deliveriesPending, d)
+	time.AfterFunc(delay, func() { q.push(d) })
+}
+
+// send posts the delivery
+func (q *deliveryQueue) send(ctx context.Context, d *delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", d.ID)
+	resp, err := outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook rejected with status %s", resp.Status)
+	}
+	return nil
+}
+
+// deadLetter keeps the failed delivery, dropping the oldest beyond maxDead
+func (q *deliveryQueue) deadLetter(d *delivery) {
+	webhookDeliveries.WithLabelValues(deliveryDeadLettered).Inc()
+	d.FailedAt = time.Now().UTC()
+	d.NextAttempt = time.Time{}
+	q.save(deliveriesDead, d)
+	q.keepDead(d)
+}
+
+// keepDead adds the delivery to the dead letters, dropping and unsaving the
+// oldest beyond maxDead
+func (q *deliveryQueue) keepDead(d *delivery) {
+	q.mu.Lock()
+	q.dead = append(q.dead, d)
+	var dropped []*delivery
+	if len(q.dead) > q.maxDead {
+		dropped = q.dead[:len(q.dead)-q.maxDead]
+		q.dead = append([]*delivery(nil), q.dead[len(q.dead)-q.maxDead:]...)
+	}
+	q.mu.Unlock()
+	for _, old := range dropped {
+		q.unsave(deliveriesDead, old.ID)
+	}
+}
+
+// DeadLetters returns the failed deliveries, oldest first
+func (q *deliveryQueue) DeadLetters() []delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]delivery, len(q.dead))
+	for i, d := range q.dead {
+		list[i] = *d
+	}
+	return list
+}
+
+// Replay queues the dead-lettered delivery again with fresh attempts
+func (q *deliveryQueue) Replay(id string) bool {
+	q.mu.Lock()
+	var found *delivery
+	for i, d := range q.dead {
+		if d.ID == id {
+			found = d
+			q.dead = append(q.dead[:i], q.dead[i+1:]...)
+			break
+		}
+	}
+	q.mu.Unlock()
+	if found == nil {
+		return false
+	}
+	found.Attempts, found.LastError, found.FailedAt = 0, "", time.Time{}
+	q.save(deliveriesPending, found)
+	q.push(found)
+	return true
+}
+
+// handleDeadLetters lists the webhook deliveries that failed every attempt
+func handleDeadLetters(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"deliveries": webhooks.DeadLetters()})
+}
+
+// handleReplayDelivery queues a dead-lettered delivery again
+func handleReplayDelivery(c *fiber.Ctx) error {
+	if !webhooks.Replay(c.Params("id")) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("no dead-lettered delivery %q", c.Params("id")),
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "queued"})
+}
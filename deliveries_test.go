@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// receiver is a webhook endpoint failing the first failures requests
+type receiver struct {
+	*httptest.Server
+	failures atomic.Int32
+	hits     atomic.Int32
+}
+
+func newReceiver(t *testing.T, failures int) *receiver {
+	r := &receiver{}
+	r.failures.Store(int32(failures))
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r.hits.Add(1)
+		if r.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// startQueue creates a queue retrying after a millisecond and runs it until
+// the test ends
+func startQueue(t *testing.T, maxAttempts, maxDead int, dir string) *deliveryQueue {
+	t.Helper()
+	q, err := newDeliveryQueue(10, maxAttempts, time.Millisecond, 4*time.Millisecond, maxDead, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go q.run(ctx)
+	return q
+}
+
+// saved returns the IDs of the deliveries saved in the state's directory
+func saved(t *testing.T, dir, state string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, state, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(files))
+	for i, f := range files {
+		ids[i] = filepath.Base(f[:len(f)-len(".json")])
+	}
+	return ids
+}
+
+// TestDeliveryRetried checks that a failed delivery is retried until it is
+// delivered, and is no longer saved once delivered
+func TestDeliveryRetried(t *testing.T) {
+	r := newReceiver(t, 2)
+	dir := t.TempDir()
+	q := startQueue(t, 5, 10, dir)
+
+	if err := q.Enqueue("test", r.URL, map[string]string{"hello": "world"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the third attempt", func() bool { return r.hits.Load() == 3 })
+	waitFor(t, "the delivery to be unsaved", func() bool { return len(saved(t, dir, deliveriesPending)) == 0 })
+	if dead := q.DeadLetters(); len(dead) != 0 {
+		t.Fatalf("%d dead letters, expected none", len(dead))
+	}
+}
+
+// TestDeliveryDeadLettered checks that a delivery failing every attempt is
+// dead-lettered after maxAttempts and saved as such
+func TestDeliveryDeadLettered(t *testing.T) {
+	r := newReceiver(t, 100)
+	dir := t.TempDir()
+	q := startQueue(t, 3, 10, dir)
+
+	if err := q.Enqueue("test", r.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the dead letter", func() bool { return len(q.DeadLetters()) == 1 })
+	d := q.DeadLetters()[0]
+	if d.Attempts != 3 || d.LastError == "" || d.FailedAt.IsZero() {
+		t.Fatalf("dead letter has %d attempts, error %q, failed at %v, expected 3 attempts with an error and time", d.Attempts, d.LastError, d.FailedAt)
+	}
+	if n := r.hits.Load(); n != 3 {
+		t.Fatalf("receiver got %d attempts, expected 3", n)
+	}
+	if ids := saved(t, dir, deliveriesDead); len(ids) != 1 || ids[0] != d.ID {
+		t.Fatalf("saved dead letters %v, expected %s", ids, d.ID)
+	}
+	if ids := saved(t, dir, deliveriesPending); len(ids) != 0 {
+		t.Fatalf("saved pending deliveries %v, expected none", ids)
+	}
+}
+
+// TestDeadLetterLimit checks that only the newest maxDead dead letters are
+// kept, in memory and on disk
+func TestDeadLetterLimit(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newDeliveryQueue(10, 1, time.Millisecond, time.Millisecond, 2, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		q.deadLetter(&delivery{ID: id})
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 2 || dead[0].ID != "b" || dead[1].ID != "c" {
+		t.Fatalf("dead letters %v, expected b and c", dead)
+	}
+	if ids := saved(t, dir, deliveriesDead); len(ids) != 2 || ids[0] != "b" || ids[1] != "c" {
+		t.Fatalf("saved dead letters %v, expected b and c", ids)
+	}
+}
+
+// TestReplayDelivery checks that the admin endpoint queues a dead letter
+// again with fresh attempts, and answers 404 for unknown deliveries
+func TestReplayDelivery(t *testing.T) {
+	r := newReceiver(t, 2)
+	defer func(q *deliveryQueue) { webhooks = q }(webhooks)
+	webhooks = startQueue(t, 2, 10, t.TempDir())
+
+	if err := webhooks.Enqueue("test", r.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the dead letter", func() bool { return len(webhooks.DeadLetters()) == 1 })
+	id := webhooks.DeadLetters()[0].ID
+
+	app := fiber.New()
+	app.Post("/admin/webhooks/dead-letters/:id/replay", handleReplayDelivery)
+	for _, tt := range []struct {
+		id     string
+		status int
+	}{{"unknown", fiber.StatusNotFound}, {id, fiber.StatusAccepted}, {id, fiber.StatusNotFound}} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/admin/webhooks/dead-letters/"+tt.id+"/replay", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("replaying %s: got %d, expected %d", tt.id, resp.StatusCode, tt.status)
+		}
+	}
+	waitFor(t, "the replayed delivery", func() bool { return r.hits.Load() == 3 })
+	if dead := webhooks.DeadLetters(); len(dead) != 0 {
+		t.Fatalf("%d dead letters after the replay was delivered, expected none", len(dead))
+	}
+}
+
+// TestDeliveriesRestored checks that saved pending deliveries are sent and
+// saved dead letters are listed after a restart
+func TestDeliveriesRestored(t *testing.T) {
+	r := newReceiver(t, 0)
+	dir := t.TempDir()
+	q, err := newDeliveryQueue(10, 1, time.Millisecond, time.Millisecond, 10, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing runs the first queue, as if it stopped before sending
+	if err := q.Enqueue("test", r.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	q.deadLetter(&delivery{ID: "failed", URL: r.URL, LastError: "gone"})
+	if err := os.WriteFile(filepath.Join(dir, deliveriesPending, "stray.json.tmp"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := startQueue(t, 1, 10, dir)
+	waitFor(t, "the restored delivery", func() bool { return r.hits.Load() == 1 })
+	if dead := restarted.DeadLetters(); len(dead) != 1 || dead[0].ID != "failed" || dead[0].LastError != "gone" {
+		t.Fatalf("restored dead letters %v, expected the saved one", dead)
+	}
+}
+
+// TestRetryDelay checks that the backoff doubles per attempt up to the cap,
+// without overflowing after many attempts
+func TestRetryDelay(t *testing.T) {
+	q, err := newDeliveryQueue(1, 100, time.Minute, time.Hour, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for attempts, expected := range map[int]time.Duration{
+		1:   time.Minute,
+		2:   2 * time.Minute,
+		6:   32 * time.Minute,
+		7:   time.Hour,
+		64:  time.Hour,
+		100: time.Hour,
+	} {
+		if delay := q.retryDelay(attempts); delay != expected {
+			t.Errorf("delay after %d attempts is %v, expected %v", attempts, delay, expected)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// Diagram formats of plans and compatibility graphs, only chosen with
+// ?format= as they have no registered media type
+const (
+	diagramMermaid = "mermaid"
+	diagramDOT     = "dot"
+)
+
+// renderPlanDiagram renders the plan's steps as a flowchart: every step is a
+// node, with an edge from each step it depends on, and steps depending on
+// nothing follow the cluster's current state
+func renderPlanDiagram(plan *planner.Plan, format string) string {
+	var start string
+	if len(plan.Steps) == 0 {
+		start = "Up to date"
+	} else {
+		start = "Current " + plan.Platform + " cluster"
+	}
+	nodes := []diagramNode{{id: "current", label: start}}
+	var edges [][2]string
+	ids := make(map[string]string, len(plan.Steps))
+	for i, s := range plan.Steps {
+		id := fmt.Sprintf("step%d", i+1)
+		ids[s.ID] = id
+		nodes = append(nodes, diagramNode{id: id, label: fmt.Sprintf("%d. %s %s → %s", i+1, strings.TrimSpace(s.Platform+" "+s.Type), s.From, s.To)})
+		if len(s.DependsOn) == 0 {
+			edges = append(edges, [2]string{"current", id})
+		}
+		for _, dep := range s.DependsOn {
+			if from, ok := ids[dep]; ok {
+				edges = append(edges, [2]string{from, id})
+			}
+		}
+	}
+	return renderDiagram(format, "plan", "TD", nodes, edges)
+}
+
+// renderCompatibilityDiagram renders which Kubernetes minors every Rancher
+// version supports on the platform, with an edge from each Rancher version
+// to the minors of its range
+func renderCompatibilityDiagram(p *planner.Planner, g *planner.Graph, format string) string {
+	var nodes []diagramNode
+	var edges [][2]string
+	minors := make(map[string]bool)
+	for _, rv := range p.Versions() {
+		lo, hi, ok := g.Range(rv)
+		if !ok {
+			continue
+		}
+		id := "rancher_" + diagramID(rv)
+		nodes = append(nodes, diagramNode{id: id, label: "Rancher " + rv})
+		ls, hs := lo.Segments(), hi.Segments()
+		if ls[0] != hs[0] {
+			continue
+		}
+		for minor := ls[1]; minor <= hs[1]; minor++ {
+			name := fmt.Sprintf("v%d.%d", ls[0], minor)
+			k8s := "k8s_" + diagramID(name)
+			if !minors[name] {
+				minors[name] = true
+				nodes = append(nodes, diagramNode{id: k8s, label: g.Platform + " " + name})
+			}
+			edges = append(edges, [2]string{id, k8s})
+		}
+	}
+	return renderDiagram(format, "compatibility", "LR", nodes, edges)
+}
+
+// diagramNode is a labeled node of a diagram
+type diagramNode struct {
+	id, label string
+}
+
+// renderDiagram writes the nodes and edges as a Mermaid flowchart or a
+// Graphviz digraph of the name in the direction, TD or LR
+func renderDiagram(format, name, direction string, nodes []diagramNode, edges [][2]string) string {
+	var b strings.Builder
+	if format == diagramDOT {
+		rankdir := "TB"
+		if direction == "LR" {
+			rankdir = "LR"
+		}
+		fmt.Fprintf(&b, "digraph %s {\n  rankdir=%s;\n  node [shape=box];\n", name, rankdir)
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "  %s [label=%q];\n", n.id, n.label)
+		}
+		for _, e := range edges {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e[0], e[1])
+		}
+		b.WriteString("}\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "flowchart %s\n", direction)
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, strings.ReplaceAll(n.label, `"`, "#quot;"))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", e[0], e[1])
+	}
+	return b.String()
+}
+
+// diagramID turns a version into a node identifier both formats accept
+func diagramID(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, v)
+}
+
+// handleCompatibilityGraph returns the compatibility graph of a platform as
+// a Mermaid flowchart, or a Graphviz digraph with ?format=dot
+func handleCompatibilityGraph(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := c.Query("format", diagramMermaid)
+		if format != diagramMermaid && format != diagramDOT {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("unknown format %q, expected %s or %s", format, diagramMermaid, diagramDOT),
+			})
+		}
+		p := ds.Planner()
+		g, err := p.Graph(c.Params("platform"))
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		c.Set(fiber.HeaderContentType, diagramContentType(format))
+		return c.SendString(renderCompatibilityDiagram(p, g, format))
+	}
+}
+
+// diagramContentType is the media type a diagram format is served with
+func diagramContentType(format string) string {
+	if format == diagramDOT {
+		return "text/vnd.graphviz; charset=utf-8"
+	}
+	return fiber.MIMETextPlainCharsetUTF8
+}
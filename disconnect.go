@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// disconnectPollInterval is how often the connection of a request in
+// progress is checked for a client that went away
+const disconnectPollInterval = 100 * time.Millisecond
+
+// requestContext returns the context bounding the work of a request: it is
+// done after requestTimeout, or as soon as the client closes the connection
+func requestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	return connContext(c.UserContext(), c.Context().Conn())
+}
+
+// connContext returns a context derived from parent that is done after
+// requestTimeout or once the peer closes the connection. Handlers streaming
+// their response call it with the connection taken before they return.
+func connContext(parent context.Context, conn net.Conn) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, requestTimeout)
+	if conn == nil {
+		return ctx, cancel
+	}
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if connClosed(conn) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "net"
+
+// connClosed cannot tell closed connections apart on this platform, so
+// requests only stop at their timeout
+func connClosed(net.Conn) bool {
+	return false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// TestRequestContextDisconnect checks that the context of a request is
+// canceled when the client closes the connection, long before the timeout
+func TestRequestContextDisconnect(t *testing.T) {
+	defer func(timeout time.Duration) { requestTimeout = timeout }(requestTimeout)
+	requestTimeout = time.Minute
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		ctx, cancel := requestContext(c)
+		defer cancel()
+		close(started)
+		select {
+		case <-ctx.Done():
+			done <- ctx.Err()
+		case <-time.After(10 * time.Second):
+			done <- errors.New("context not canceled")
+		}
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	conn.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("request context ended with %v, expected it canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request context still running after the client disconnected")
+	}
+}
+
+// TestPlanBatchCanceled checks that canceling the context mid-batch stops
+// planning: the clusters left report the cancellation instead of a plan
+func TestPlanBatchCanceled(t *testing.T) {
+	p := testDataset(t).Planner()
+	clusters := make([]batchCluster, 5)
+	for i := range clusters {
+		clusters[i] = batchCluster{Request: planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9+rke2r1"}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var results []batchResult
+	planBatch(ctx, p, clusters, 1, func(r batchResult) bool {
+		results = append(results, r)
+		// The client goes away after the first result
+		cancel()
+		return true
+	})
+
+	if len(results) != len(clusters) {
+		t.Fatalf("got %d results, expected %d", len(results), len(clusters))
+	}
+	if results[0].Plan == nil {
+		t.Fatalf("first cluster failed with %q, expected a plan", results[0].Error)
+	}
+	canceled := 0
+	for _, r := range results[1:] {
+		if r.Plan == nil && strings.Contains(r.Error, context.Canceled.Error()) {
+			canceled++
+		}
+	}
+	// The worker may have started the second cluster before the cancellation
+	if canceled < len(clusters)-2 {
+		t.Fatalf("%d clusters after the cancellation reported it, expected at least %d: %+v", canceled, len(clusters)-2, results)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// TestPlanErrorStatus checks the status and code of every error class
+func TestPlanErrorStatus(t *testing.T) {
+	classified := func(code string) error {
+		return &planner.RequestError{Code: code, Err: errors.New(code)}
+	}
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "invalid request", err: classified(planner.CodeInvalidRequest), status: fiber.StatusBadRequest, code: planner.CodeInvalidRequest},
+		{name: "invalid version", err: classified(planner.CodeInvalidVersion), status: fiber.StatusBadRequest, code: planner.CodeInvalidVersion},
+		{name: "unknown Rancher version", err: &planner.UnknownRancherVersionError{Version: "2.7.99"}, status: fiber.StatusBadRequest, code: planner.CodeInvalidVersion},
+		{name: "unknown platform", err: &planner.UnknownPlatformError{Platform: "rke3"}, status: fiber.StatusNotFound, code: planner.CodeUnknownPlatform},
+		{name: "unsupported combination", err: classified(planner.CodeUnsupportedCombination), status: fiber.StatusUnprocessableEntity, code: planner.CodeUnsupportedCombination},
+		{name: "no path found", err: classified(planner.CodeNoPathFound), status: fiber.StatusUnprocessableEntity, code: planner.CodeNoPathFound},
+		{name: "blackout", err: &planner.BlackoutError{}, status: fiber.StatusUnprocessableEntity, code: planner.CodeBlackout},
+		{name: "timeout", err: fmt.Errorf("planning: %w", context.DeadlineExceeded), status: fiber.StatusRequestTimeout, code: "timeout"},
+		{name: "internal", err: errors.New("data error"), status: fiber.StatusInternalServerError, code: "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := planErrorStatus(tt.err); status != tt.status {
+				t.Errorf("planErrorStatus = %d, expected %d", status, tt.status)
+			}
+			if code := planErrorCode(tt.err); code != tt.code {
+				t.Errorf("planErrorCode = %q, expected %q", code, tt.code)
+			}
+		})
+	}
+}
+
+// TestLookupErrorBodies checks that the lookup endpoints answer errors with
+// the status and body of plan errors
+func TestLookupErrorBodies(t *testing.T) {
+	ds := testDataset(t)
+	app := fiber.New()
+	app.Get("/api/patch-remediation/:platform/:rancher/:k8s", handlePatchRemediation(ds))
+	app.Get("/api/compat/rancher-for-k8s/:platform/:k8s", handleRancherForK8s(ds))
+	app.Get("/api/compat/graph/:platform", handleCompatibilityGraph(ds))
+
+	tests := []struct {
+		url    string
+		status int
+		code   string
+	}{
+		{url: "/api/patch-remediation/rke2/2.8.5/v1.x", status: fiber.StatusBadRequest, code: planner.CodeInvalidVersion},
+		{url: "/api/patch-remediation/rke2/2.8.99/v1.27.16", status: fiber.StatusBadRequest, code: planner.CodeInvalidVersion},
+		{url: "/api/patch-remediation/rke2/2.8.5/v1.20.15", status: fiber.StatusUnprocessableEntity, code: planner.CodeUnsupportedCombination},
+		{url: "/api/compat/rancher-for-k8s/rke2/v1.x", status: fiber.StatusBadRequest, code: planner.CodeInvalidVersion},
+		{url: "/api/compat/rancher-for-k8s/rke3/v1.27.16", status: fiber.StatusNotFound, code: planner.CodeUnknownPlatform},
+		{url: "/api/compat/graph/rke3", status: fiber.StatusNotFound, code: planner.CodeUnknownPlatform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, tt.url, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body struct{ Error, Code, Message string }
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || body.Code != tt.code {
+				t.Fatalf("got %d %q, expected %d %q", resp.StatusCode, body.Code, tt.status, tt.code)
+			}
+			if body.Message == "" || body.Error != body.Message {
+				t.Fatalf("error %q and message %q, expected the same non-empty message", body.Error, body.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dataProvenance identifies the compatibility data an instance plans with
+type dataProvenance struct {
+	Source   string    `json:"source"`            // Path of the data file
+	Hash     string    `json:"hash"`              // As reported in plan metadata
+	Version  string    `json:"version,omitempty"` // Version declared by the data, if any
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// handleDataExport returns the loaded compatibility data with its provenance,
+// as JSON or, with ?format=yaml or an Accept header preferring YAML, as YAML
+func handleDataExport(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Vary(fiber.HeaderAccept)
+		format := c.Query("format")
+		if format == "" {
+			format = "json"
+			if c.Accepts(fiber.MIMEApplicationJSON, "application/yaml") == "application/yaml" {
+				format = "yaml"
+			}
+		}
+		if format != "json" && format != "yaml" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("unknown format %q, expected json or yaml", format),
+			})
+		}
+
+		paths, provenance := ds.Snapshot()
+		export := fiber.Map{"provenance": provenance, "data": paths}
+		if format == "json" {
+			return c.JSON(export)
+		}
+
+		data, err := marshalYAML(export)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		return c.Send(data)
+	}
+}
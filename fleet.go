@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// fleetReport aggregates the plans of a batch of clusters
+type fleetReport struct {
+	Clusters      int    `json:"clusters"`
+	Planned       int    `json:"planned"`
+	Failed        int    `json:"failed"`
+	NewestRancher string `json:"newest_rancher"` // Newest released Rancher version in the data
+
+	Platforms          map[string]int `json:"platforms"`
+	RancherVersions    map[string]int `json:"rancher_versions"`
+	KubernetesVersions map[string]int `json:"kubernetes_versions"` // By minor
+	// MinorsBehind counts clusters by how many Rancher minors they are behind
+	// the newest: "0", "1", "2", "3+", or "unknown"
+	MinorsBehind map[string]int `json:"minors_behind"`
+
+	Effort   *planner.Effort `json:"effort,omitempty"` // Total of the clusters' plans
+	Warnings []fleetIssue    `json:"warnings"`         // Most common warnings first
+	Errors   []fleetIssue    `json:"errors"`           // Most common errors first
+
+	// Groups holds a report per value of the ?group_by= label; clusters
+	// without the label are under "(none)"
+	Groups map[string]fleetReport `json:"groups,omitempty"`
+}
+
+// noLabelGroup groups the clusters without the ?group_by= label
+const noLabelGroup = "(none)"
+
+// fleetIssue is a warning rule or error shared by clusters of the fleet
+type fleetIssue struct {
+	Rule     string   `json:"rule,omitempty"`
+	Message  string   `json:"message"` // Message of the first affected cluster
+	Clusters int      `json:"clusters"`
+	Names    []string `json:"cluster_names,omitempty"`
+}
+
+// minorsBehindBucket returns the bucket of a Rancher version relative to the newest
+func minorsBehindBucket(current string, newest *version.Version) string {
+	v, err := version.NewVersion(strings.TrimSpace(current))
+	if err != nil || newest == nil {
+		return "unknown"
+	}
+	cur, latest := v.Segments(), newest.Segments()
+	behind := (latest[0]-cur[0])*100 + latest[1] - cur[1]
+	switch {
+	case behind <= 0:
+		return "0"
+	case behind >= 3:
+		return "3+"
+	}
+	return fmt.Sprint(behind)
+}
+
+// k8sMinor returns the major.minor of a Kubernetes version, or the version
+// unchanged when it cannot be parsed
+func k8sMinor(k8s string) string {
+	v, err := version.NewVersion(strings.TrimPrefix(strings.TrimSpace(k8s), "v"))
+	if err != nil {
+		return k8s
+	}
+	return fmt.Sprintf("v%d.%d", v.Segments()[0], v.Segments()[1])
+}
+
+// newestRelease returns the newest Rancher version that is not a prerelease
+func newestRelease(versions []string) *version.Version {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v, err := version.NewVersion(versions[i]); err == nil && v.Prerelease() == "" {
+			return v
+		}
+	}
+	return nil
+}
+
+// buildFleetReport aggregates the clusters and their results, keeping the top
+// most common warnings and errors
+func buildFleetReport(clusters []batchCluster, results []batchResult, newest *version.Version, top int) fleetReport {
+	report := fleetReport{
+		Clusters:           len(clusters),
+		Platforms:          make(map[string]int),
+		RancherVersions:    make(map[string]int),
+		KubernetesVersions: make(map[string]int),
+		MinorsBehind:       make(map[string]int),
+		Warnings:           []fleetIssue{},
+		Errors:             []fleetIssue{},
+	}
+	if newest != nil {
+		report.NewestRancher = newest.Original()
+	}
+
+	warnings := make(map[string]*fleetIssue)
+	failures := make(map[string]*fleetIssue)
+	for i, cluster := range clusters {
+		name := cluster.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		report.RancherVersions[cluster.CurrentRancher]++
+		report.KubernetesVersions[k8sMinor(cluster.CurrentK8s)]++
+		report.MinorsBehind[minorsBehindBucket(cluster.CurrentRancher, newest)]++
+
+		r := results[i]
+		if r.Plan == nil {
+			report.Failed++
+			report.Platforms[strings.ToLower(cluster.Platform)]++
+			addIssue(failures, r.Error, "", r.Error, name)
+			continue
+		}
+		report.Planned++
+		report.Platforms[r.Plan.Platform]++
+		report.Effort = sumEffort(report.Effort, r.Plan.Effort)
+
+		seen := make(map[string]bool)
+		for _, w := range r.Plan.Warnings {
+			if !seen[w.Rule] {
+				seen[w.Rule] = true
+				addIssue(warnings, w.Rule, w.Rule, w.Message, name)
+			}
+		}
+	}
+	report.Warnings = topIssues(warnings, top)
+	report.Errors = topIssues(failures, top)
+	return report
+}
+
+// groupFleetReport adds a report per value of the label to the report
+func groupFleetReport(report *fleetReport, label string, clusters []batchCluster, results []batchResult, newest *version.Version, top int) {
+	type group struct {
+		clusters []batchCluster
+		results  []batchResult
+	}
+	groups := make(map[string]*group)
+	for i, cluster := range clusters {
+		value, ok := cluster.Labels[label]
+		if !ok {
+			value = noLabelGroup
+		}
+		g, ok := groups[value]
+		if !ok {
+			g = &group{}
+			groups[value] = g
+		}
+		g.clusters = append(g.clusters, cluster)
+		g.results = append(g.results, results[i])
+	}
+
+	report.Groups = make(map[string]fleetReport, len(groups))
+	for value, g := range groups {
+		report.Groups[value] = buildFleetReport(g.clusters, g.results, newest, top)
+	}
+}
+
+// addIssue counts the cluster against the issue with the key
+func addIssue(issues map[string]*fleetIssue, key, rule, message, cluster string) {
+	issue, ok := issues[key]
+	if !ok {
+		issue = &fleetIssue{Rule: rule, Message: message}
+		issues[key] = issue
+	}
+	issue.Clusters++
+	issue.Names = append(issue.Names, cluster)
+}
+
+// topIssues returns up to top issues, the ones affecting most clusters first
+func topIssues(issues map[string]*fleetIssue, top int) []fleetIssue {
+	list := make([]fleetIssue, 0, len(issues))
+	for _, issue := range issues {
+		list = append(list, *issue)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Clusters != list[j].Clusters {
+			return list[i].Clusters > list[j].Clusters
+		}
+		if list[i].Rule != list[j].Rule {
+			return list[i].Rule < list[j].Rule
+		}
+		return list[i].Message < list[j].Message
+	})
+	if top > 0 && len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
+
+// renderFleetMarkdown renders the report as a Markdown document
+func renderFleetMarkdown(r fleetReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Fleet upgrade posture\n\n")
+	fmt.Fprintf(&b, "%d clusters, %d planned, %d failed. Newest Rancher release: %s.\n\n", r.Clusters, r.Planned, r.Failed, r.NewestRancher)
+	if r.Effort != nil {
+		fmt.Fprintf(&b, "Estimated effort: %.1f hours (Rancher %.1f, Kubernetes %.1f, migration %.1f).\n\n",
+			r.Effort.Hours, r.Effort.Rancher, r.Effort.Kubernetes, r.Effort.Migration)
+	}
+
+	writeCounts(&b, "Rancher minors behind", "Minors behind", r.MinorsBehind)
+	writeCounts(&b, "Platforms", "Platform", r.Platforms)
+	writeCounts(&b, "Rancher versions", "Version", r.RancherVersions)
+	writeCounts(&b, "Kubernetes versions", "Minor", r.KubernetesVersions)
+
+	writeIssues(&b, "Most common warnings", r.Warnings)
+	writeIssues(&b, "Most common errors", r.Errors)
+
+	values := make([]string, 0, len(r.Groups))
+	for value := range r.Groups {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		g := r.Groups[value]
+		group := renderFleetMarkdown(g)
+		// Nest the group's sections under its own heading
+		group = strings.ReplaceAll(group, "\n## ", "\n### ")
+		group = strings.Replace(group, "# Fleet upgrade posture", "## "+markdownCell(value), 1)
+		b.WriteString(group)
+	}
+	return b.String()
+}
+
+// writeCounts writes a table of counts ordered by key
+func writeCounts(b *strings.Builder, title, column string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "## %s\n\n| %s | Clusters |\n| --- | ---: |\n", title, column)
+	for _, k := range keys {
+		fmt.Fprintf(b, "| %s | %d |\n", markdownCell(k), counts[k])
+	}
+	b.WriteString("\n")
+}
+
+// writeIssues writes a table of issues in their order
+func writeIssues(b *strings.Builder, title string, issues []fleetIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "## %s\n\n| Clusters | Rule | Message |\n| ---: | --- | --- |\n", title)
+	for _, issue := range issues {
+		fmt.Fprintf(b, "| %d | %s | %s |\n", issue.Clusters, markdownCell(issue.Rule), markdownCell(issue.Message))
+	}
+	b.WriteString("\n")
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// handleFleetReport plans every cluster in the batch request body and returns
+// the aggregate report, as JSON or as Markdown with ?format=markdown. With
+// ?group_by=<label> the report also breaks the fleet down by the label.
+func handleFleetReport(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := c.Query("format", "json")
+		if format != "json" && format != "markdown" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("unknown format %q, expected json or markdown", format),
+			})
+		}
+		req, err := readBatchRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for _, cluster := range req.Clusters {
+			noteUsage(c, cluster.Request)
+		}
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		p := ds.Planner()
+		results := make([]batchResult, len(req.Clusters))
+		planBatch(ctx, p, req.Clusters, batchWorkers, func(r batchResult) bool {
+			results[r.Index] = r
+			return true
+		})
+		newest, top := newestRelease(p.Versions()), c.QueryInt("top", 10)
+		report := buildFleetReport(req.Clusters, results, newest, top)
+		if label := c.Query("group_by"); label != "" {
+			groupFleetReport(&report, label, req.Clusters, results, newest, top)
+		}
+
+		if format == "markdown" {
+			c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+			return c.SendString(renderFleetMarkdown(report))
+		}
+		return c.JSON(report)
+	}
+}
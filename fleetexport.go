@@ -0,0 +1,250 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"gopkg.in/yaml.v3"
+)
+
+// upgradeImages are the system-upgrade-controller images upgrading the
+// platforms Fleet exports support
+var upgradeImages = map[string]string{
+	"rke2": "rancher/rke2-upgrade",
+	"k3s":  "rancher/k3s-upgrade",
+}
+
+// upgradeChannels are the release channel servers of the platforms, used
+// when a step targets a Kubernetes minor rather than a release
+var upgradeChannels = map[string]string{
+	"rke2": "https://update.rke2.io/v1-release/channels/",
+	"k3s":  "https://update.k3s.io/v1-release/channels/",
+}
+
+// resourceName matches valid Kubernetes resource names
+var resourceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// fleetFile is a file of the exported Fleet repository
+type fleetFile struct {
+	path string
+	doc  interface{} // Encoded as YAML; text when a string
+}
+
+// sucPlans returns the system-upgrade-controller plans upgrading the server
+// and then the agent nodes of the platform to the step's target. Full
+// releases are pinned; minors follow their release channel.
+func sucPlans(platform string, step planner.UpgradeStep) []interface{} {
+	image := upgradeImages[platform]
+	upgrade := map[string]interface{}{"image": image}
+	target := map[string]interface{}{}
+	if strings.Contains(step.To, "+") {
+		target["version"] = step.To
+	} else {
+		target["channel"] = upgradeChannels[platform] + step.To
+	}
+
+	plan := func(name string, spec map[string]interface{}) interface{} {
+		spec["concurrency"] = 1
+		spec["cordon"] = true
+		spec["serviceAccountName"] = "system-upgrade"
+		spec["upgrade"] = upgrade
+		for k, v := range target {
+			spec[k] = v
+		}
+		return map[string]interface{}{
+			"apiVersion": "upgrade.cattle.io/v1",
+			"kind":       "Plan",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "system-upgrade"},
+			"spec":       spec,
+		}
+	}
+	controlPlane := "node-role.kubernetes.io/control-plane"
+	return []interface{}{
+		plan("server-plan", map[string]interface{}{
+			"nodeSelector": map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": controlPlane, "operator": "In", "values": []string{"true"}},
+				},
+			},
+		}),
+		plan("agent-plan", map[string]interface{}{
+			"nodeSelector": map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": controlPlane, "operator": "DoesNotExist"},
+				},
+			},
+			"prepare": map[string]interface{}{"image": image, "args": []string{"prepare", "server-plan"}},
+			"drain":   map[string]interface{}{"force": true},
+		}),
+	}
+}
+
+// fleetRepository lays out the plan as a Fleet repository: a directory per
+// Kubernetes step holding its system-upgrade-controller plans, a GitRepo
+// deploying the first step to the selected clusters, and a README listing
+// the steps. Rancher steps run on the management cluster and are listed for
+// running by hand.
+func fleetRepository(plan *planner.Plan, name, repo, branch string, sel labelSelector) ([]fleetFile, error) {
+	var files []fleetFile
+	var paths []string
+	var readme strings.Builder
+	fmt.Fprintf(&readme, "# %s\n\nUpgrade plan for %s clusters matching the GitRepo's targets. The GitRepo deploys one step directory at a time; once a step is verified on every cluster, point `spec.paths` at the next one.\n\n", name, plan.Platform)
+
+	for i, step := range plan.Steps {
+		switch step.Type {
+		case "Kubernetes":
+			dir := fmt.Sprintf("steps/%02d-%s", i+1, step.ID)
+			paths = append(paths, dir)
+			files = append(files,
+				fleetFile{dir + "/fleet.yaml", map[string]interface{}{"defaultNamespace": "system-upgrade"}},
+				fleetFile{dir + "/plans.yaml", sucPlans(plan.Platform, step)},
+			)
+			fmt.Fprintf(&readme, "%d. Kubernetes %s to %s: `%s`\n", i+1, step.From, step.To, dir)
+		default:
+			fmt.Fprintf(&readme, "%d. %s %s to %s: not managed by Fleet, run it by hand\n", i+1, step.Type, step.From, step.To)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("the plan has no Kubernetes steps to roll out with Fleet")
+	}
+
+	files = append(files, fleetFile{"gitrepo.yaml", map[string]interface{}{
+		"apiVersion": "fleet.cattle.io/v1alpha1",
+		"kind":       "GitRepo",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "fleet-default"},
+		"spec": map[string]interface{}{
+			"repo":    repo,
+			"branch":  branch,
+			"paths":   paths[:1],
+			"targets": []interface{}{map[string]interface{}{"clusterSelector": sel.kubernetes()}},
+		},
+	}})
+	readme.WriteString("\nThe clusters need the system-upgrade-controller installed in the `system-upgrade` namespace.\n")
+	files = append(files, fleetFile{"README.md", readme.String()})
+	return files, nil
+}
+
+// writeFleetArchive writes the files as a gzipped tarball under the directory
+func writeFleetArchive(dir string, files []fleetFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		var data []byte
+		switch doc := f.doc.(type) {
+		case string:
+			data = []byte(doc)
+		case []interface{}:
+			var out bytes.Buffer
+			enc := yaml.NewEncoder(&out)
+			enc.SetIndent(2)
+			for _, d := range doc {
+				if err := enc.Encode(d); err != nil {
+					return nil, err
+				}
+			}
+			data = out.Bytes()
+		default:
+			var out bytes.Buffer
+			enc := yaml.NewEncoder(&out)
+			enc.SetIndent(2)
+			if err := enc.Encode(doc); err != nil {
+				return nil, err
+			}
+			data = out.Bytes()
+		}
+		hdr := &tar.Header{Name: dir + "/" + f.path, Mode: 0o644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// handleFleetExport plans the request in the body and returns the plan as a
+// Fleet repository tarball. ?repo= is the Git repository the GitRepo points
+// at and ?selector= the labels of the clusters it targets; both are required
+// so an export never targets every cluster by accident.
+func handleFleetExport(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		repo := c.Query("repo")
+		if repo == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "?repo= is required: the Git repository the GitRepo deploys from",
+			})
+		}
+		sel, err := parseSelector(c.Query("selector"))
+		if err == nil && len(sel) == 0 {
+			err = fmt.Errorf("?selector= is required: the labels of the clusters to upgrade")
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		var req planner.Request
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid request body: %v", err),
+			})
+		}
+		if req.Language == "" {
+			req.Language = requestLanguage(c)
+		}
+		noteUsage(c, req)
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		plan, err := ds.Planner().PlanContext(ctx, req)
+		recordPlanOutcome(err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		if _, ok := upgradeImages[plan.Platform]; !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Fleet export supports rke2 and k3s clusters, not %s", plan.Platform),
+			})
+		}
+
+		name := c.Query("name", "rancher-upgrade")
+		if !resourceName.MatchString(name) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid name %q, expected a lowercase Kubernetes resource name", name),
+			})
+		}
+		files, err := fleetRepository(plan, name, repo, c.Query("branch", "main"), sel)
+		if err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		archive, err := writeFleetArchive(name, files)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "application/gzip")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+		return c.Send(archive)
+	}
+}
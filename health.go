@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/remote"
+)
+
+// Component health states, from best to worst
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded" // Working, but needs attention
+	healthDown     = "down"     // Not working
+)
+
+// componentHealth is the status of one part of the service
+type componentHealth struct {
+	Status  string                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+var (
+	healthChecksMu sync.RWMutex
+	healthChecks   = make(map[string]func() componentHealth)
+)
+
+// registerHealthCheck adds a component to the verbose health report
+func registerHealthCheck(name string, check func() componentHealth) {
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+	healthChecks[name] = check
+}
+
+// worse returns the worse of two health states
+func worse(a, b string) string {
+	rank := map[string]int{healthOK: 0, healthDegraded: 1, healthDown: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// handleHealth answers OK, or with ?verbose=1 the status of every component.
+// The verbose report is served with 503 when a component is down.
+func handleHealth(c *fiber.Ctx) error {
+	if !c.QueryBool("verbose") {
+		return c.SendString("OK")
+	}
+
+	healthChecksMu.RLock()
+	names := make([]string, 0, len(healthChecks))
+	for name := range healthChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	components := make(map[string]componentHealth, len(names))
+	status := healthOK
+	for _, name := range names {
+		h := healthChecks[name]()
+		components[name] = h
+		status = worse(status, h.Status)
+	}
+	healthChecksMu.RUnlock()
+
+	code := fiber.StatusOK
+	if status == healthDown {
+		code = fiber.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(fiber.Map{"status": status, "components": components})
+}
+
+// datasetHealth reports the loaded data, degraded once it is older than
+// ALERT_DATASET_MAX_AGE, like the generated alert
+func datasetHealth(ds *dataset) func() componentHealth {
+	return func() componentHealth {
+		_, provenance := ds.Snapshot()
+		age := time.Since(provenance.LoadedAt)
+		ds.mu.RLock()
+		missing := len(ds.missing)
+		ds.mu.RUnlock()
+		h := componentHealth{Status: healthOK, Details: map[string]interface{}{
+			"source":           provenance.Source,
+			"hash":             provenance.Hash,
+			"version":          provenance.Version,
+			"loaded_at":        provenance.LoadedAt,
+			"age_seconds":      int(age.Seconds()),
+			"missing_releases": missing,
+		}}
+		if age > alertDatasetMaxAge {
+			h.Status = healthDegraded
+		}
+		return h
+	}
+}
+
+// outboundHealth reports the circuit breakers of the hosts contacted so far,
+// degraded while any is open
+func outboundHealth() componentHealth {
+	h := componentHealth{Status: healthOK, Details: map[string]interface{}{}}
+	for host, state := range outbound.States() {
+		h.Details[host] = state.String()
+		if state == remote.StateOpen {
+			h.Status = healthDegraded
+		}
+	}
+	return h
+}
+
+// changelogHealth reports the size of the release notes cache
+func changelogHealth() componentHealth {
+	changelogs.mu.Lock()
+	defer changelogs.mu.Unlock()
+	return componentHealth{Status: healthOK, Details: map[string]interface{}{
+		"entries": len(changelogs.entries),
+	}}
+}
+
+// webhookHealth reports the outbound webhook queue, degraded while it holds
+// dead letters
+func webhookHealth() componentHealth {
+	webhooks.mu.Lock()
+	dead := len(webhooks.dead)
+	webhooks.mu.Unlock()
+	h := componentHealth{Status: healthOK, Details: map[string]interface{}{
+		"queued":       len(webhooks.queue),
+		"dead_letters": dead,
+	}}
+	if dead > 0 {
+		h.Status = healthDegraded
+	}
+	return h
+}
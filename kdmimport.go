@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/kdm"
+)
+
+// defaultKDMSource is the KDM data of the newest Rancher minor in the data
+const defaultKDMSource = "https://releases.rancher.com/kontainer-driver-metadata/release-v2.9/data.json"
+
+// stringList is a repeatable string flag
+type stringList []string
+
+// String implements flag.Value
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+// Set implements flag.Value
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// runImportKDM generates the RKE1, RKE2, and K3s ranges of the data from
+// Rancher's kontainer-driver-metadata and writes the updated data
+func runImportKDM(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("data import-kdm", flag.ContinueOnError)
+	var sources stringList
+	fs.Var(&sources, "kdm", "KDM data.json URL or file, repeatable for the branches of several Rancher minors (default "+defaultKDMSource+")")
+	base := fs.String("base", upgradePathsFile, "data file the imported ranges are applied to; empty starts from no data")
+	extra := fs.String("rancher", "", "comma-separated Rancher versions to import in addition to those in the base data")
+	releases := fs.Bool("rancher-releases", false, "also import every published Rancher release from GitHub")
+	output := fs.String("o", "", "file to write the data to; empty writes to standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(sources) == 0 {
+		sources = stringList{defaultKDMSource}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	client, err := newOutboundClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	outbound = client
+
+	metadata := &kdm.Metadata{}
+	for _, src := range sources {
+		data, err := readSource(ctx, src)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		m, err := kdm.Parse(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", src, err)
+			return 1
+		}
+		metadata.Add(m)
+	}
+
+	paths, err := loadUpgradePathsFile(*base)
+	if *base == "" {
+		err = nil
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var versions []string
+	for v := range paths.RancherManager {
+		versions = append(versions, v)
+	}
+	for _, v := range strings.Split(*extra, ",") {
+		if v = strings.TrimPrefix(strings.TrimSpace(v), "v"); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	if *releases {
+		published, err := rancherReleases(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		versions = append(versions, published...)
+	}
+
+	imported := metadata.Import(versions)
+	added := 0
+	for v := range imported.RancherManager {
+		if _, ok := paths.RancherManager[v]; !ok {
+			added++
+		}
+	}
+	merged := kdm.Apply(paths, imported)
+
+	data, err := json.MarshalIndent(merged, "", "    ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		out.Write(data)
+	} else if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Imported the ranges of %d Rancher versions, %d of them new\n", len(imported.RancherManager), added)
+	return 0
+}
+
+// readSource reads a URL with the outbound client, or a local file
+func readSource(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.ReadFile(src)
+	}
+	resp, err := outbound.Get(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", src, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// rancherReleases returns the versions of every published, non-prerelease
+// Rancher release on GitHub
+func rancherReleases(ctx context.Context) ([]string, error) {
+	var versions []string
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("%s/repos/%s/releases?per_page=100&page=%d", changelogAPI, changelogRepositories["rancher"], page)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if githubToken != "" {
+			req.Header.Set("Authorization", "Bearer "+githubToken)
+		}
+		resp, err := outbound.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var releases []struct {
+			TagName    string `json:"tag_name"`
+			Draft      bool   `json:"draft"`
+			Prerelease bool   `json:"prerelease"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching Rancher releases: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&releases)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding Rancher releases: %v", err)
+		}
+		if len(releases) == 0 {
+			return versions, nil
+		}
+		for _, r := range releases {
+			v, err := version.NewVersion(r.TagName)
+			if r.Draft || r.Prerelease || err != nil || v.Prerelease() != "" {
+				continue
+			}
+			versions = append(versions, strings.TrimPrefix(r.TagName, "v"))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelRequirement is a single term of a label selector
+type labelRequirement struct {
+	key, value string
+	op         string // "=", "!=", "exists", or "!exists"
+}
+
+// labelSelector selects clusters by their labels, like a Kubernetes
+// equality-based selector such as "env=prod,team!=payments,canary"
+type labelSelector []labelRequirement
+
+// parseSelector parses a comma separated list of key=value, key!=value, key
+// (the label is set), and !key (the label is not set) terms
+func parseSelector(s string) (labelSelector, error) {
+	var sel labelSelector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		var r labelRequirement
+		switch {
+		case term == "":
+			continue
+		case strings.Contains(term, "!="):
+			r.key, r.value, _ = strings.Cut(term, "!=")
+			r.op = "!="
+		case strings.Contains(term, "="):
+			r.key, r.value, _ = strings.Cut(strings.Replace(term, "==", "=", 1), "=")
+			r.op = "="
+		case strings.HasPrefix(term, "!"):
+			r.key, r.op = term[1:], "!exists"
+		default:
+			r.key, r.op = term, "exists"
+		}
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// matches reports whether the labels satisfy every term of the selector
+func (sel labelSelector) matches(labels map[string]string) bool {
+	for _, r := range sel {
+		value, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || value != r.value {
+				return false
+			}
+		case "!=":
+			if ok && value == r.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// kubernetes returns the selector as a Kubernetes LabelSelector
+func (sel labelSelector) kubernetes() map[string]interface{} {
+	matchLabels := make(map[string]string)
+	var expressions []interface{}
+	for _, r := range sel {
+		switch r.op {
+		case "=":
+			matchLabels[r.key] = r.value
+		case "!=":
+			expressions = append(expressions, map[string]interface{}{"key": r.key, "operator": "NotIn", "values": []string{r.value}})
+		case "exists":
+			expressions = append(expressions, map[string]interface{}{"key": r.key, "operator": "Exists"})
+		case "!exists":
+			expressions = append(expressions, map[string]interface{}{"key": r.key, "operator": "DoesNotExist"})
+		}
+	}
+	selector := make(map[string]interface{})
+	if len(matchLabels) > 0 {
+		selector["matchLabels"] = matchLabels
+	}
+	if len(expressions) > 0 {
+		selector["matchExpressions"] = expressions
+	}
+	return selector
+}
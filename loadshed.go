@@ -0,0 +1,94 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Load shedding: planning requests are limited to PLAN_CONCURRENCY at once,
+// and batch and fleet requests, which plan many clusters each, to
+// BULK_CONCURRENCY, so they cannot starve single plans. Planning is CPU
+// bound, so the limits default to four single plans or one bulk request per
+// CPU. Requests wait up to
+// LOAD_SHED_QUEUE_TIMEOUT for a slot and are then answered with 503 and a
+// Retry-After header. A limit of 0 disables it.
+var (
+	planLimiter     = newLimiter("plan", envInt("PLAN_CONCURRENCY", 4*runtime.NumCPU()))
+	bulkLimiter     = newLimiter("bulk", envInt("BULK_CONCURRENCY", runtime.NumCPU()))
+	loadShedWait    = envDuration("LOAD_SHED_QUEUE_TIMEOUT", time.Second)
+	loadShedRetryIn = envDuration("LOAD_SHED_RETRY_AFTER", 5*time.Second)
+)
+
+// permitLocalsKey holds the request's slot in the context locals
+const permitLocalsKey = "load-shed-permit"
+
+// limiter bounds the requests of a class processed at once
+type limiter struct {
+	class string
+	slots chan struct{} // Nil when unlimited
+}
+
+// newLimiter returns a limiter allowing max requests at once, or any number
+// when max is not positive
+func newLimiter(class string, max int) *limiter {
+	l := &limiter{class: class}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// permit is a held slot, released once
+type permit struct {
+	once    sync.Once
+	release func()
+	kept    bool // Handed to work outliving the handler
+}
+
+func (p *permit) done() {
+	p.once.Do(p.release)
+}
+
+// handler is middleware holding a slot while the request is processed
+func (l *limiter) handler(c *fiber.Ctx) error {
+	if l.slots == nil {
+		return c.Next()
+	}
+
+	timer := time.NewTimer(loadShedWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+	case <-timer.C:
+		requestsShed.WithLabelValues(l.class).Inc()
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(loadShedRetryIn.Round(time.Second).Seconds())))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "the service is busy, retry later",
+		})
+	}
+
+	p := &permit{release: func() { <-l.slots }}
+	c.Locals(permitLocalsKey, p)
+	defer func() {
+		if !p.kept {
+			p.done()
+		}
+	}()
+	return c.Next()
+}
+
+// keepPermit hands the request's slot to work that runs after the handler
+// returns, such as a streamed response, which must call the returned
+// function when it is done
+func keepPermit(c *fiber.Ctx) func() {
+	p, ok := c.Locals(permitLocalsKey).(*permit)
+	if !ok {
+		return func() {}
+	}
+	p.kept = true
+	return p.done
+}
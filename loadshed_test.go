@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// limitedApp serves /work through a limiter of one slot, holding the slot
+// until release is closed once a request has entered
+func limitedApp(l *limiter, entered chan<- struct{}, release <-chan struct{}) *fiber.App {
+	app := fiber.New()
+	app.Get("/work", l.handler, func(c *fiber.Ctx) error {
+		entered <- struct{}{}
+		<-release
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+// TestLimiter checks that a request waits for a busy slot and is served once
+// it frees, and is shed with 503 and Retry-After when it does not free in
+// time
+func TestLimiter(t *testing.T) {
+	defer func(wait, retry time.Duration) { loadShedWait, loadShedRetryIn = wait, retry }(loadShedWait, loadShedRetryIn)
+	loadShedRetryIn = 5 * time.Second
+
+	tests := []struct {
+		name    string
+		wait    time.Duration // LOAD_SHED_QUEUE_TIMEOUT
+		holdFor time.Duration // Until the first request frees its slot
+		status  int
+	}{
+		{name: "queued", wait: 5 * time.Second, holdFor: 50 * time.Millisecond, status: fiber.StatusNoContent},
+		{name: "shed", wait: 20 * time.Millisecond, holdFor: 200 * time.Millisecond, status: fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadShedWait = tt.wait
+			l := newLimiter("test-"+tt.name, 1)
+			entered, release := make(chan struct{}, 2), make(chan struct{})
+			app := limitedApp(l, entered, release)
+			shedBefore := testutil.ToFloat64(requestsShed.WithLabelValues(l.class))
+
+			first := make(chan error, 1)
+			go func() {
+				resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/work", nil), -1)
+				if err == nil {
+					resp.Body.Close()
+				}
+				first <- err
+			}()
+			<-entered
+			time.AfterFunc(tt.holdFor, func() { close(release) })
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/work", nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d, expected %d", resp.StatusCode, tt.status)
+			}
+			if err := <-first; err != nil {
+				t.Fatal(err)
+			}
+
+			shed := testutil.ToFloat64(requestsShed.WithLabelValues(l.class)) - shedBefore
+			if tt.status == fiber.StatusServiceUnavailable {
+				if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "5" {
+					t.Errorf("Retry-After %q, expected 5", got)
+				}
+				if shed != 1 {
+					t.Errorf("%v requests counted as shed, expected 1", shed)
+				}
+			} else if shed != 0 {
+				t.Errorf("%v requests counted as shed, expected none", shed)
+			}
+			if len(l.slots) != 0 {
+				t.Errorf("%d slots still held", len(l.slots))
+			}
+		})
+	}
+}
+
+// TestLimiterUnlimited checks that a limit of 0 does not limit
+func TestLimiterUnlimited(t *testing.T) {
+	l := newLimiter("test-unlimited", 0)
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	app := limitedApp(l, entered, release)
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/work", nil), -1)
+			if err != nil {
+				done <- 0
+				return
+			}
+			resp.Body.Close()
+			done <- resp.StatusCode
+		}()
+	}
+	// Both requests are processed at once
+	<-entered
+	<-entered
+	close(release)
+	for i := 0; i < 2; i++ {
+		if status := <-done; status != http.StatusNoContent {
+			t.Fatalf("got %d, expected 204", status)
+		}
+	}
+}
+
+// TestKeepPermit checks that a kept slot stays held after the handler
+// returns, until the work it was handed to releases it
+func TestKeepPermit(t *testing.T) {
+	l := newLimiter("test-keep", 1)
+	var release func()
+	app := fiber.New()
+	app.Get("/work", l.handler, func(c *fiber.Ctx) error {
+		release = keepPermit(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/work", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(l.slots) != 1 {
+		t.Fatalf("%d slots held after the handler returned, expected the kept one", len(l.slots))
+	}
+	release()
+	release()
+	if len(l.slots) != 0 {
+		t.Fatalf("%d slots held after the release", len(l.slots))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Log sampling: 1 in ACCESS_LOG_SAMPLE successful requests is logged, and 1
+// in PLANNER_LOG_SAMPLE planner log lines; failed requests are always logged.
+// Both can be changed at runtime on the metrics port.
+var (
+	accessLog  = newLogSampler(envInt("ACCESS_LOG_SAMPLE", 1))
+	plannerLog = newLogSampler(envInt("PLANNER_LOG_SAMPLE", 1))
+)
+
+// logSampler keeps 1 in rate log entries
+type logSampler struct {
+	rate atomic.Int64
+	seen atomic.Uint64
+}
+
+// newLogSampler returns a sampler keeping 1 in rate entries
+func newLogSampler(rate int) *logSampler {
+	s := &logSampler{}
+	s.rate.Store(int64(max(rate, 1)))
+	return s
+}
+
+// keep reports whether the next entry is logged
+func (s *logSampler) keep() bool {
+	rate := uint64(s.rate.Load())
+	return rate <= 1 || s.seen.Add(1)%rate == 1
+}
+
+// Writer returns a writer passing on the sampled writes to w, for loggers
+// writing one line per call
+func (s *logSampler) Writer(w io.Writer) io.Writer {
+	return sampledWriter{s: s, w: w}
+}
+
+type sampledWriter struct {
+	s *logSampler
+	w io.Writer
+}
+
+func (sw sampledWriter) Write(p []byte) (int, error) {
+	if !sw.s.keep() {
+		return len(p), nil
+	}
+	return sw.w.Write(p)
+}
+
+// writeAccessLog is the logger middleware's Done hook: the middleware writes
+// to io.Discard and the sampled lines, and every failed request, are written
+// here
+func writeAccessLog(c *fiber.Ctx, line []byte) {
+	if c.Response().StatusCode() >= fiber.StatusBadRequest || accessLog.keep() {
+		os.Stdout.Write(line)
+	}
+}
+
+// handleLogSampling returns the sampling rates; PUT requests first change
+// those given as ?access= and ?planner=, and change neither unless both are
+// valid
+func handleLogSampling(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodPut {
+		rates := make(map[*logSampler]int64)
+		for name, s := range map[string]*logSampler{"access": accessLog, "planner": plannerLog} {
+			if c.Query(name) == "" {
+				continue
+			}
+			rate := c.QueryInt(name)
+			if rate < 1 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "sampling rates must be positive integers, 1 logs everything",
+				})
+			}
+			rates[s] = int64(rate)
+		}
+		for s, rate := range rates {
+			s.rate.Store(rate)
+		}
+	}
+	return c.JSON(fiber.Map{
+		"access":  accessLog.rate.Load(),
+		"planner": plannerLog.rate.Load(),
+	})
+}
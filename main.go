@@ -1,55 +1,42 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/hashicorp/go-version"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
 )
 
-// Platform defines the compatibility of Kubernetes versions with a Rancher version
-type Platform struct {
-	Platform   string `json:"platform"`
-	MinVersion string `json:"min_version"`
-	MaxVersion string `json:"max_version"`
-	Notes      string `json:"notes,omitempty"`
-}
-
-// RancherManagerVersion contains supported platforms for each Rancher version
-type RancherManagerVersion struct {
-	SupportedPlatforms []Platform `json:"supported_platforms"`
-}
-
-// UpgradePaths stores all Rancher versions and their compatibility data
-type UpgradePaths struct {
-	RancherManager map[string]RancherManagerVersion `json:"rancher_manager"`
-}
-
-// UpgradeStep represents a single upgrade step
-type UpgradeStep struct {
-	Type     string `json:"type"`     // Rancher or Kubernetes
-	Platform string `json:"platform"` // RKE1, RKE2, etc.
-	From     string `json:"from"`     // Previous version
-	To       string `json:"to"`       // New version
-}
-
 // Custom metrics
 var (
 	totalRequestsLast60Seconds prometheus.Gauge
 	versionsSubmitted          *prometheus.CounterVec
-	requestDuration            prometheus.Histogram
+	requestDuration            *prometheus.HistogramVec
 	activeRequests             prometheus.Gauge
+	outboundBreakerState       *prometheus.GaugeVec
+	plansTotal                 *prometheus.CounterVec
+	datasetLoadedTimestamp     prometheus.Gauge
+	datasetMissingReleases     prometheus.Gauge
+	webhookDeliveries          *prometheus.CounterVec
+	requestsShed               *prometheus.CounterVec
 
 	// For tracking request timestamps
 	requestTimestamps []time.Time
@@ -71,381 +58,522 @@ func initMetrics() {
 		[]string{"platform", "rancher_version", "k8s_version"},
 	)
 
-	requestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
-		Name:    "request_duration_seconds",
-		Help:    "Histogram of response latency (seconds) of requests.",
-		Buckets: prometheus.DefBuckets,
-	})
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "request_duration_seconds",
+			Help:    "Histogram of response latency (seconds) of requests, by route template, method, and status.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
 
 	activeRequests = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "active_requests",
 		Help: "Current number of active requests.",
 	})
 
+	outboundBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outbound_circuit_breaker_state",
+			Help: "Circuit breaker state per outbound host (0=closed, 1=half-open, 2=open).",
+		},
+		[]string{"host"},
+	)
+
+	plansTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricPlansTotal,
+			Help: "Total number of plans requested, by outcome (planned, failed, timed_out).",
+		},
+		[]string{"outcome"},
+	)
+	for _, outcome := range []string{outcomePlanned, outcomeFailed, outcomeTimedOut} {
+		plansTotal.WithLabelValues(outcome)
+	}
+
+	datasetLoadedTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: metricDatasetLoaded,
+		Help: "Unix time the compatibility data was loaded.",
+	})
+
+	datasetMissingReleases = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dataset_missing_releases",
+		Help: "Releases announced by GitHub webhooks that the compatibility data does not list yet.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Outbound webhook delivery attempts, by outcome (delivered, retried, dead_lettered).",
+		},
+		[]string{"outcome"},
+	)
+	for _, outcome := range []string{deliveryDelivered, deliveryRetried, deliveryDeadLettered} {
+		webhookDeliveries.WithLabelValues(outcome)
+	}
+
+	requestsShed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "requests_shed_total",
+			Help: "Requests rejected with 503 because too many of their class (plan, bulk) were being processed.",
+		},
+		[]string{"class"},
+	)
+	for _, class := range []string{planLimiter.class, bulkLimiter.class} {
+		requestsShed.WithLabelValues(class)
+	}
+
 	// Register custom metrics with Prometheus
 	prometheus.MustRegister(
 		totalRequestsLast60Seconds,
 		versionsSubmitted,
 		requestDuration,
 		activeRequests,
+		outboundBreakerState,
+		plansTotal,
+		datasetLoadedTimestamp,
+		datasetMissingReleases,
+		webhookDeliveries,
+		requestsShed,
 	)
 }
 
-// LoadUpgradePaths loads the upgrade paths from the JSON file
-func LoadUpgradePaths() (UpgradePaths, error) {
-	file, err := os.Open("./data/upgrade-paths.json")
-	if err != nil {
-		return UpgradePaths{}, fmt.Errorf("failed to load upgrade paths: %v", err)
-	}
-	defer file.Close()
+// Names of the metrics the generated alerting rules refer to
+const (
+	metricPlansTotal    = "plans_total"
+	metricDatasetLoaded = "dataset_loaded_timestamp_seconds"
+)
 
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return UpgradePaths{}, fmt.Errorf("failed to read upgrade paths file: %v", err)
-	}
+// Plan outcomes counted by plans_total
+const (
+	outcomePlanned  = "planned"
+	outcomeFailed   = "failed"
+	outcomeTimedOut = "timed_out"
+)
 
-	var paths UpgradePaths
-	err = json.Unmarshal(bytes, &paths)
-	if err != nil {
-		return UpgradePaths{}, fmt.Errorf("failed to parse upgrade paths JSON: %v", err)
+// recordPlanOutcome counts a plan request by the error it ended with
+func recordPlanOutcome(err error) {
+	switch {
+	case err == nil:
+		plansTotal.WithLabelValues(outcomePlanned).Inc()
+	case errors.Is(err, context.DeadlineExceeded):
+		plansTotal.WithLabelValues(outcomeTimedOut).Inc()
+	default:
+		plansTotal.WithLabelValues(outcomeFailed).Inc()
 	}
-	return paths, nil
 }
 
-// PlanUpgrade generates the Rancher + Kubernetes upgrade plan
-func PlanUpgrade(currentRancher, currentK8s, platform string, versions []string, paths UpgradePaths) ([]UpgradeStep, error) {
-	var upgradeSteps []UpgradeStep
-	keyVersions := GetKeyVersions(versions)
-
-	// Normalize platform name to lowercase for consistent comparison
-	platformLower := strings.ToLower(platform)
+// requestTimeout bounds the time spent planning a single API request
+var requestTimeout = envDuration("REQUEST_TIMEOUT", 30*time.Second)
 
-	currentRancherVersion, err := version.NewVersion(currentRancher)
-	if err != nil {
-		return nil, fmt.Errorf("invalid current Rancher version: %v", err)
+// planErrorStatus returns the HTTP status for a planning error: 400 for
+// invalid requests and versions, 404 for unknown platforms, and 422 for valid
+// requests no plan can be made for
+func planErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fiber.StatusRequestTimeout
 	}
+	switch planner.ErrorCode(err) {
+	case planner.CodeInvalidRequest, planner.CodeInvalidVersion:
+		return fiber.StatusBadRequest
+	case planner.CodeUnknownPlatform:
+		return fiber.StatusNotFound
+	case planner.CodeUnsupportedCombination, planner.CodeNoPathFound, planner.CodeBlackout:
+		return fiber.StatusUnprocessableEntity
+	}
+	return fiber.StatusInternalServerError
+}
 
-	for _, v := range keyVersions {
-		nextVersion, err := version.NewVersion(v)
-		if err != nil {
-			return nil, fmt.Errorf("invalid version in key versions: %v", err)
-		}
-
-		if nextVersion.GreaterThan(currentRancherVersion) {
-			// Add Rancher upgrade step
-			upgradeSteps = append(upgradeSteps, UpgradeStep{
-				Type: "Rancher", From: currentRancher, To: v,
-			})
-
-			// Get Kubernetes upgrades for this Rancher version
-			r1 := paths.RancherManager[currentRancher]
-			r2 := paths.RancherManager[v]
-			k8sUpgrades := GetAllowedK8sUpgrades(currentK8s, platformLower, r1, r2)
-
-			// Add Kubernetes upgrade steps
-			for _, upgrade := range k8sUpgrades {
-				upgradeSteps = append(upgradeSteps, upgrade)
-				currentK8s = upgrade.To // Update current Kubernetes version
-			}
-
-			currentRancher = v                  // Update current Rancher version
-			currentRancherVersion = nextVersion // Update current Rancher version object
-		}
+// planErrorCode returns the planner's code for a planning error, "timeout"
+// for requests that ran out of time, and "internal_error" otherwise
+func planErrorCode(err error) string {
+	if code := planner.ErrorCode(err); code != "" {
+		return code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
 	}
+	return "internal_error"
+}
 
-	return upgradeSteps, nil
+// planErrorBody is the response body of a failed plan: the error's code and
+// message, and the message as "error" like every other API error
+func planErrorBody(err error) fiber.Map {
+	return fiber.Map{
+		"error":   err.Error(),
+		"code":    planErrorCode(err),
+		"message": err.Error(),
+	}
 }
 
-// GetAllowedK8sUpgrades determines the Kubernetes upgrade path based on platform rules
-func GetAllowedK8sUpgrades(currentK8s, platform string, r1, r2 RancherManagerVersion) []UpgradeStep {
-	var upgrades []UpgradeStep
-	k8sVersions := getSortedK8sVersions(platform, r1, r2)
+// requestLanguage returns the language requested with ?lang=, falling back to
+// the Accept-Language header
+func requestLanguage(c *fiber.Ctx) string {
+	return c.Query("lang", c.Get(fiber.HeaderAcceptLanguage))
+}
 
-	currentVer, err := parseK8sVersion(currentK8s)
-	if err != nil {
-		return upgrades
+// queryValues returns every value of a repeated query parameter
+func queryValues(c *fiber.Ctx, key string) []string {
+	var values []string
+	for _, v := range c.Context().QueryArgs().PeekMulti(key) {
+		values = append(values, string(v))
 	}
+	return values
+}
 
-	// Ensure current version is in the list
-	if !versionInList(currentVer, k8sVersions) {
-		k8sVersions = append(k8sVersions, currentVer)
-		sort.Sort(version.Collection(k8sVersions))
+// requestExtensions returns the UI extensions given as ?extension=name:range,
+// repeated for every installed extension
+func requestExtensions(c *fiber.Ctx) (map[string]string, error) {
+	values := queryValues(c, "extension")
+	if len(values) == 0 {
+		return nil, nil
 	}
+	extensions := make(map[string]string, len(values))
+	for _, v := range values {
+		name, r, ok := strings.Cut(v, ":")
+		if !ok || name == "" || r == "" {
+			return nil, fmt.Errorf("invalid extension %q, expected name:range", v)
+		}
+		extensions[name] = r
+	}
+	return extensions, nil
+}
 
-	// Decide whether to allow skipping minor versions based on platform
-	allowSkip := platform == "rke1" || platform == "rke2" || platform == "k3s"
+// planRequest builds the plan request of the /:platform/:rancher/:k8s route
+// parameters and the optional query parameters
+func planRequest(c *fiber.Ctx) (planner.Request, error) {
+	extensions, err := requestExtensions(c)
+	if err != nil {
+		return planner.Request{}, err
+	}
 
-	for {
-		nextVer := findNextAcceptableK8sVersion(currentVer, k8sVersions, allowSkip)
-		if nextVer == nil {
-			break
+	var management *planner.ManagementCluster
+	if local := c.Query("local_platform"); local != "" {
+		management = &planner.ManagementCluster{
+			Platform: local,
+			K8s:      c.Query("local_k8s"),
+			Nodes:    c.QueryInt("local_nodes"),
+			CPUs:     c.QueryInt("local_cpus"),
+			MemoryG:  c.QueryInt("local_memory_gb"),
 		}
-
-		upgrades = append(upgrades, UpgradeStep{
-			Type:     "Kubernetes",
-			Platform: platform,
-			From:     "v" + currentVer.Original(),
-			To:       "v" + nextVer.Original(),
-		})
-		currentVer = nextVer
 	}
 
-	return upgrades
+	return planner.Request{
+		Platform:          c.Params("platform"),
+		CurrentRancher:    c.Params("rancher"),
+		CurrentK8s:        c.Params("k8s"),
+		TargetRancher:     c.Query("target_rancher"),
+		AllowPrerelease:   c.QueryBool("allow_prerelease"),
+		PlannedDate:       c.Query("planned_date"),
+		CertificateExpiry: c.Query("certificate_expiry"),
+		ManagementCluster: management,
+		LTSS:              c.QueryBool("ltss"),
+		AlwaysSupported:   c.QueryBool("always_supported"),
+		NodeOS:            c.Query("node_os"),
+		NodeOSVersion:     c.Query("node_os_version"),
+		DockerVersion:     c.Query("docker_version"),
+		AuthProvider:      c.Query("auth_provider"),
+		Features:          queryValues(c, "feature"),
+		Extensions:        extensions,
+		Strategy:          c.Query("strategy"),
+		RancherHops:       c.Query("rancher_hops"),
+		MigrateTo:         c.Query("migrate_to"),
+		Language:          requestLanguage(c),
+		Nodes:             c.QueryInt("nodes"),
+	}, nil
 }
 
-// findNextAcceptableK8sVersion finds the next acceptable Kubernetes version
-func findNextAcceptableK8sVersion(currentVer *version.Version, k8sVersions []*version.Version, allowSkip bool) *version.Version {
-	currentSegments := currentVer.Segments()
-	if len(currentSegments) < 2 {
-		return nil
+// loadPolicy reads the organization policy from a JSON file; no file means
+// no policy
+func loadPolicy(path string) (*planner.Policy, error) {
+	if path == "" {
+		return nil, nil
 	}
-	currentMinor := currentSegments[1]
-	maxAllowedMinor := currentMinor + 1
-	if allowSkip {
-		maxAllowedMinor = currentMinor + 2
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	var policy planner.Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy in %s: %v", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
 
-	var candidate *version.Version
-	for _, v := range k8sVersions {
-		if v.LessThanOrEqual(currentVer) {
-			continue
-		}
-		nextSegments := v.Segments()
-		if len(nextSegments) < 2 {
-			continue
-		}
-		nextMinor := nextSegments[1]
-		if nextMinor > maxAllowedMinor {
-			break // No further versions are acceptable
-		}
-		candidate = v // Update candidate to the current acceptable version
+// upgradePathsFile is the compatibility data served by the service, and the
+// default of the CLI commands. It is set with UPGRADE_PATHS_FILE or the
+// service's -data flag and may be a directory of JSON fragments.
+var upgradePathsFile = envString("UPGRADE_PATHS_FILE", "./data/upgrade-paths.json")
 
-		if !allowSkip {
-			// For platforms that do not allow skipping, return the first acceptable version immediately
-			break
-		}
-	}
-	return candidate
+// LoadUpgradePaths loads the upgrade paths from the JSON file
+func LoadUpgradePaths() (planner.UpgradePaths, error) {
+	return loadUpgradePathsFile(upgradePathsFile)
 }
 
-// Checks if a version is in the list
-func versionInList(ver *version.Version, list []*version.Version) bool {
-	for _, v := range list {
-		if v.Equal(ver) {
-			return true
-		}
+// plannerOptions returns the planner options configured by the environment:
+// the organization policy and blackouts applied to every plan, platform
+// aliases, version policies, and the effort model
+func plannerOptions() (planner.Options, error) {
+	policy, err := loadPolicy(envString("POLICY_FILE", ""))
+	if err != nil {
+		return planner.Options{}, fmt.Errorf("error loading policy: %v", err)
+	}
+	blackouts, err := loadBlackouts(envString("BLACKOUT_FILE", ""))
+	if err != nil {
+		return planner.Options{}, fmt.Errorf("error loading blackouts: %v", err)
 	}
-	return false
+	return planner.Options{
+		Policy:      policy,
+		Blackouts:   blackouts,
+		Aliases:     envMap("PLATFORM_ALIASES"),
+		Prereleases: envVersionPolicy("RANCHER_PRERELEASE_POLICY"),
+		Hotfixes:    envVersionPolicy("RANCHER_HOTFIX_POLICY"),
+		Effort: planner.EffortModel{
+			RancherHop:   envFloat("EFFORT_RANCHER_HOP_HOURS", 0),
+			K8sHop:       envFloat("EFFORT_K8S_HOP_HOURS", 0),
+			NodesPerUnit: envInt("EFFORT_K8S_NODES_PER_UNIT", 10),
+			Migration:    envFloat("EFFORT_MIGRATION_HOURS", 0),
+		},
+	}, nil
 }
 
-// getSortedK8sVersions retrieves and sorts the Kubernetes versions for the given platform
-func getSortedK8sVersions(platform string, r1, r2 RancherManagerVersion) []*version.Version {
-	versionSet := make(map[string]*version.Version)
-	platforms := append(r1.SupportedPlatforms, r2.SupportedPlatforms...)
-	platformLower := strings.ToLower(platform)
-
-	for _, p := range platforms {
-		pPlatformLower := strings.ToLower(p.Platform)
-		if pPlatformLower == platformLower {
-			minVerStr := cleanVersion(p.MinVersion)
-			maxVerStr := cleanVersion(p.MaxVersion)
-			minVer, err := version.NewVersion(minVerStr)
-			if err != nil {
-				continue
-			}
-			maxVer, err := version.NewVersion(maxVerStr)
-			if err != nil {
-				continue
-			}
-			// Generate all minor versions between minVer and maxVer
-			versionsBetween := getMinorVersionsBetween(minVer, maxVer, p)
-			for _, v := range versionsBetween {
-				versionSet[v.Original()] = v
-			}
-		}
+// loadUpgradePathsFile loads upgrade paths from the given JSON file, or from
+// every *.json fragment of the given directory merged in file name order,
+// later fragments replacing the Rancher versions of earlier ones
+func loadUpgradePathsFile(path string) (planner.UpgradePaths, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return loadUpgradePathsDir(path)
 	}
 
-	// Convert map to slice
-	var versionList []*version.Version
-	for _, v := range versionSet {
-		versionList = append(versionList, v)
+	file, err := os.Open(path)
+	if err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to load upgrade paths: %v", err)
 	}
+	defer file.Close()
 
-	// Sort the versions
-	sort.Sort(version.Collection(versionList))
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to read upgrade paths file: %v", err)
+	}
 
-	return versionList
+	var paths planner.UpgradePaths
+	err = json.Unmarshal(bytes, &paths)
+	if err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to parse upgrade paths JSON: %v", err)
+	}
+	if err := paths.Validate(); err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("invalid upgrade paths: %v", err)
+	}
+	return paths, nil
 }
 
-// getMinorVersionsBetween returns all minor versions between min and max versions, including exact versions from data
-func getMinorVersionsBetween(minVer, maxVer *version.Version, platformData Platform) []*version.Version {
-	var versions []*version.Version
-
-	// Include exact min and max versions with their metadata
-	minVerWithMeta, err := version.NewVersion(cleanVersion(platformData.MinVersion))
-	if err == nil {
-		versions = append(versions, minVerWithMeta)
+// loadUpgradePathsDir merges the JSON fragments of the directory
+func loadUpgradePathsDir(dir string) (planner.UpgradePaths, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return planner.UpgradePaths{}, err
 	}
-
-	maxVerWithMeta, err := version.NewVersion(cleanVersion(platformData.MaxVersion))
-	if err == nil && !maxVerWithMeta.Equal(minVerWithMeta) {
-		versions = append(versions, maxVerWithMeta)
+	if len(files) == 0 {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to load upgrade paths: no *.json files in %s", dir)
 	}
+	sort.Strings(files)
 
-	// Generate intermediate minor versions
-	currentVer := minVer
-	for {
-		// Increment minor version
-		segments := currentVer.Segments()
-		if len(segments) < 2 {
-			break
-		}
-		major := segments[0]
-		minor := segments[1]
-		newMinor := minor + 1
-		newVerStr := fmt.Sprintf("%d.%d.0", major, newMinor)
-		newVer, err := version.NewVersion(newVerStr)
+	var paths planner.UpgradePaths
+	for _, file := range files {
+		fragment, err := loadUpgradePathsFile(file)
 		if err != nil {
-			break
-		}
-		if newVer.GreaterThan(maxVer) {
-			break
+			return planner.UpgradePaths{}, fmt.Errorf("%s: %v", file, err)
 		}
-		versions = append(versions, newVer)
-		currentVer = newVer
+		paths = planner.Merge(paths, fragment)
 	}
-
-	return versions
-}
-
-// cleanVersion removes the "v" prefix from a version string
-func cleanVersion(v string) string {
-	v = strings.TrimPrefix(v, "v")
-	return v
+	return paths, nil
 }
 
-// parseK8sVersion parses a Kubernetes version string
-func parseK8sVersion(v string) (*version.Version, error) {
-	cleaned := cleanVersion(v)
-	ver, err := version.NewVersion(cleaned)
-	if err != nil {
-		log.Printf("Error parsing Kubernetes version '%s': %v", v, err)
-		return nil, err
+// Main application entry point
+func main() {
+	// Run a CLI command instead of the service when one is given
+	if len(os.Args) > 1 && (!strings.HasPrefix(os.Args[1], "-") || os.Args[1] == "-h" || os.Args[1] == "--help") {
+		os.Exit(runCommand(os.Args[1:]))
 	}
-	return ver, nil
-}
+	flag.StringVar(&upgradePathsFile, "data", upgradePathsFile, "compatibility data file, or directory of JSON fragments")
+	flag.Parse()
 
-// GetKeyVersions returns the key Rancher versions for the upgrade plan
-func GetKeyVersions(versions []string) []string {
-	var keyVersions []*version.Version
-	for _, v := range versions {
-		if strings.HasSuffix(v, ".9") || v == "2.7.5" || v == "2.8.8" || v == "2.9.2" {
-			ver, err := version.NewVersion(v)
-			if err != nil {
-				continue
-			}
-			keyVersions = append(keyVersions, ver)
-		}
-	}
+	// Initialize custom metrics
+	initMetrics()
 
-	// Sort the versions
-	sort.Sort(version.Collection(keyVersions))
+	// A signal stops the servers and the background work
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Convert back to string slices
-	sortedKeyVersions := make([]string, len(keyVersions))
-	for i, v := range keyVersions {
-		sortedKeyVersions[i] = v.String()
+	// Shared client for remote data sources
+	var err error
+	outbound, err = newOutboundClient()
+	if err != nil {
+		log.Fatalf("Error configuring outbound client: %v", err)
 	}
 
-	return sortedKeyVersions
-}
+	// Background delivery of outbound webhooks
+	webhooks, err = newDeliveryQueue(webhookQueueSize, webhookMaxAttempts, webhookRetryBackoff, webhookMaxBackoff, webhookDeadLetters, webhookQueueDir)
+	if err != nil {
+		log.Fatalf("Error restoring webhook deliveries: %v", err)
+	}
+	go webhooks.run(ctx)
 
-// Main application entry point
-func main() {
-	// Initialize custom metrics
-	initMetrics()
+	// Main application Fiber instance; paths are unescaped so versions sent
+	// as v1.26.8%2Brke2r1 arrive as v1.26.8+rke2r1
+	app := fiber.New(fiber.Config{UnescapePath: true})
 
-	// Main application Fiber instance
-	app := fiber.New()
+	// Give every request an ID, taken from X-Request-ID when the client sends one
+	app.Use(requestid.New())
 
 	// Add the logger middleware
 	app.Use(logger.New(logger.Config{
-		Format:     "[${time}] ${ip} ${status} - ${latency} ${method} ${path}\n",
+		Format:     "[${time}] ${ip} ${status} - ${latency} ${method} ${path} ${locals:requestid}\n",
 		TimeFormat: "2006-01-02 15:04:05",
 		TimeZone:   "Local",
+		Output:     io.Discard,
+		Done:       writeAccessLog,
 	}))
 
+	// Record requests for the usage statistics served on the metrics port
+	app.Use(recordUsage)
+
+	// Answer handler panics with a 500 carrying the request ID, and report them
+	if errorReportURL != "" {
+		registerErrorReporter(webhookReporter{url: errorReportURL})
+	}
+	app.Use(recoverPanics)
+
 	// Load upgrade paths
-	upgradePaths, err := LoadUpgradePaths()
+	opts, err := plannerOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.Logger = log.New(plannerLog.Writer(log.Writer()), "", log.LstdFlags)
+	data, err := loadDataset(upgradePathsFile, opts)
 	if err != nil {
 		log.Fatalf("Error loading upgrade paths: %v", err)
 	}
 
+	// Runbooks render with the operator's templates and guidance
+	if runbookTemplateDir != "" {
+		if runbook, err = loadRunbookTemplate(runbookTemplateDir); err != nil {
+			log.Fatalf("Error loading runbook templates: %v", err)
+		}
+	}
+
+	// Stamp the build into plan metadata and the log
+	build := currentBuild()
+	planner.Commit = build.Commit
+	_, provenance := data.Snapshot()
+	log.Printf("rancher-upgrade-tool %s (commit %s, built %s, %s) planning with data %s from %s",
+		build.Version, build.Commit, build.BuildDate, build.GoVersion, provenance.Hash, provenance.Source)
+
 	app.Static("/", "./static")
 
-	app.Get("/healthz", func(c *fiber.Ctx) error {
-		return c.SendString("OK")
-	})
+	registerHealthCheck("dataset", datasetHealth(data))
+	registerHealthCheck("outbound", outboundHealth)
+	registerHealthCheck("changelog_cache", changelogHealth)
+	registerHealthCheck("webhook_deliveries", webhookHealth)
+	app.Get("/healthz", handleHealth)
 
 	// API route to generate the upgrade plan
-	app.Get("/api/plan-upgrade/:platform/:rancher/:k8s", func(c *fiber.Ctx) error {
-		// Start timer
-		timer := prometheus.NewTimer(requestDuration)
-		defer timer.ObserveDuration()
+	app.Get("/api/plan-upgrade/:platform/:rancher/:k8s", planLimiter.handler, handlePlanUpgrade(data, planUpgradeParams))
 
-		// Increment active requests gauge
-		activeRequests.Inc()
-		defer activeRequests.Dec()
+	// API route to generate the upgrade plan of a JSON request body
+	app.Post("/api/plan-upgrade", planLimiter.handler, handlePlanUpgrade(data, planUpgradeBody))
 
-		// Handle request timestamps for sliding window
-		updateRequestTimestamps()
+	// API route to return only the next step of the upgrade plan
+	app.Get("/api/next-step/:platform/:rancher/:k8s", planLimiter.handler, handleNextStep(data))
 
-		platform := c.Params("platform")
-		currentRancher := c.Params("rancher")
-		currentK8s := c.Params("k8s")
+	// API route to compare the support matrices of two Rancher versions
+	app.Get("/api/matrix-diff/:from/:to", conditionalOnDataset(data), bulkLimiter.handler, handleMatrixDiff(data))
 
-		// Increment versions submitted counter
-		versionsSubmitted.WithLabelValues(platform, currentRancher, currentK8s).Inc()
+	// API route to recommend the newest patch the running Rancher supports
+	app.Get("/api/patch-remediation/:platform/:rancher/:k8s", planLimiter.handler, handlePatchRemediation(data))
 
-		var versions []string
-		for v := range upgradePaths.RancherManager {
-			versions = append(versions, v)
-		}
+	// API routes to list the Rancher versions in the data and what each supports
+	app.Get("/api/versions", conditionalOnDataset(data), handleVersions(data))
+	app.Get("/api/versions/:rancher", conditionalOnDataset(data), handleRancherVersion(data))
 
-		// Sort versions using semantic versioning
-		parsedVersions := make([]*version.Version, 0, len(versions))
-		for _, v := range versions {
-			ver, err := version.NewVersion(v)
-			if err != nil {
-				continue
-			}
-			parsedVersions = append(parsedVersions, ver)
-		}
-		sort.Sort(version.Collection(parsedVersions))
+	// API route to look up the Rancher versions supporting a Kubernetes version
+	app.Get("/api/compat/rancher-for-k8s/:platform/:k8s", handleRancherForK8s(data))
 
-		// Convert back to string slices
-		sortedKeyVersions := make([]string, len(parsedVersions))
-		for i, v := range parsedVersions {
-			sortedKeyVersions[i] = v.String()
-		}
+	// API route to render the compatibility graph of a platform as a diagram
+	app.Get("/api/compat/graph/:platform", conditionalOnDataset(data), handleCompatibilityGraph(data))
+
+	// API route to normalize user supplied version strings
+	app.Get("/api/normalize", handleNormalize(data))
+
+	// API route to export the loaded compatibility data
+	app.Get("/api/data/export", requireExportToken, conditionalOnDataset(data), handleDataExport(data))
+	if replicaOf == "" && dataExportToken == "" {
+		log.Printf("DATA_EXPORT_TOKEN is not set, /api/data/export serves the compatibility data without authentication")
+	}
+	app.Get("/api/version", handleVersion(data))
+	app.Get("/api/blackouts", handleBlackouts(opts.Blackouts))
+
+	// API route to report the advisories an upgrade fixes and introduces
+	app.Get("/api/security-delta/:platform/:rancher/:k8s", planLimiter.handler, handleSecurityDelta(data))
+
+	// API route to summarize the upgrade posture of many clusters
+	app.Post("/api/fleet-report", bulkLimiter.handler, handleFleetReport(data))
+
+	// API route to plan against hypothetical changes to the data
+	app.Post("/api/plan-what-if", planLimiter.handler, handleWhatIf(data))
+
+	// API route to export a plan as a Fleet repository
+	app.Post("/api/fleet-export", bulkLimiter.handler, handleFleetExport(data))
+
+	// API route to recompute the rest of a partially executed plan
+	app.Post("/api/plan-resume", planLimiter.handler, handleResume(data))
+
+	// API route to plan many clusters in one request
+	app.Post("/api/plan-batch", bulkLimiter.handler, handlePlanBatch(data))
 
-		upgradePath, err := PlanUpgrade(currentRancher, currentK8s, platform, sortedKeyVersions, upgradePaths)
+	// API route to describe the API as an OpenAPI document
+	app.Get("/api/openapi.json", handleOpenAPI(app))
+
+	// Re-plan the watched clusters on a schedule and report changed plans
+	if watchClustersFile != "" {
+		clusters, err := loadWatchedClusters(watchClustersFile)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			log.Fatalf("Error loading watched clusters: %v", err)
 		}
+		replans := newReplanner(data, clusters, replanNotifyURL)
+		go replans.run(ctx, replanInterval)
+		registerHealthCheck("replanner", func() componentHealth { return replans.health(replanInterval) })
+		app.Get("/api/watched-plans", handleWatchedPlans(replans))
+	}
 
-		return c.JSON(fiber.Map{
-			"upgrade_path": upgradePath,
-		})
-	})
+	// Replicas follow the primary's data instead of the local file
+	if replicaOf != "" {
+		r, err := newReplica(data, replicaOf, replicaToken)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go r.run(ctx, replicaSyncInterval)
+		registerHealthCheck("replica", r.health)
+		log.Printf("Replica of %s, syncing the data every %s", replicaOf, replicaSyncInterval)
+	}
 
-	// Start the metrics server on port 9000
-	go startMetricsServer()
+	// Record the releases of the watched repositories missing from the data
+	if replicaOf != "" {
+		log.Printf("REPLICA_OF is set, the GitHub webhook is disabled")
+	} else if githubWebhookSecret != "" {
+		app.Post("/webhooks/github", handleGitHubWebhook(data))
+	} else {
+		log.Printf("GITHUB_WEBHOOK_SECRET is not set, the GitHub webhook is disabled")
+	}
 
-	// Start the main application on port 3000
-	log.Fatal(app.Listen(":3000"))
+	// Serve the API on port 3000 and the metrics on port 9000 until a signal
+	// or a failure stops them
+	if err := serve(ctx, app, newMetricsApp()); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // updateRequestTimestamps handles the sliding window of request timestamps
@@ -471,12 +599,15 @@ func updateRequestTimestamps() {
 	totalRequestsLast60Seconds.Set(float64(len(requestTimestamps)))
 }
 
-// startMetricsServer starts a separate Fiber app to serve metrics on port 9000
-func startMetricsServer() {
+// newMetricsApp returns a separate Fiber app serving metrics and the admin
+// endpoints, to be listened on port 9000
+func newMetricsApp() *fiber.App {
 	metricsApp := fiber.New()
+	metricsApp.Use(recoverPanics)
 
-	// Set up Prometheus middleware
-	prometheusMiddleware := fiberprometheus.New("fiber_app")
+	// Set up Prometheus middleware on the default registry, which holds the
+	// custom metrics
+	prometheusMiddleware := fiberprometheus.NewWithRegistry(prometheus.DefaultRegisterer, "fiber_app", "http", "", nil)
 	prometheusMiddleware.RegisterAt(metricsApp, "/metrics")
 	metricsApp.Use(prometheusMiddleware.Middleware)
 
@@ -486,8 +617,13 @@ func startMetricsServer() {
 		return nil
 	})
 
-	// Start the metrics server
-	if err := metricsApp.Listen(":9000"); err != nil {
-		log.Fatalf("Failed to start metrics server: %v", err)
-	}
+	// Admin endpoints are only exposed on the internal metrics port
+	metricsApp.Get("/admin/stats", handleAdminStats)
+	metricsApp.Get("/admin/grafana-dashboard.json", handleGrafanaDashboard)
+	metricsApp.Get("/admin/prometheus-rules.yaml", handlePrometheusRules)
+	metricsApp.Get("/admin/webhooks/dead-letters", handleDeadLetters)
+	metricsApp.Put("/admin/log-sampling", requireAdmin, handleLogSampling)
+	metricsApp.Get("/admin/log-sampling", handleLogSampling)
+	metricsApp.Post("/admin/webhooks/dead-letters/:id/replay", requireAdmin, handleReplayDelivery)
+	return metricsApp
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/remote"
+)
+
+// TestMain sets up the metrics and the outbound client the service creates
+// at startup. The outbound client does not retry, so tests control every
+// attempt.
+func TestMain(m *testing.M) {
+	initMetrics()
+	outbound = remote.New(remote.Options{
+		Timeout:          5 * time.Second,
+		FailureThreshold: 1000,
+		OpenTimeout:      time.Minute,
+	})
+	os.Exit(m.Run())
+}
+
+// waitFor polls the condition until it holds, failing the test after a few
+// seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// testDataset returns a dataset of the shipped compatibility data
+func testDataset(t *testing.T) *dataset {
+	t.Helper()
+	paths, err := loadUpgradePathsFile("data/upgrade-paths.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dataset{
+		path:     "data/upgrade-paths.json",
+		paths:    paths,
+		planner:  planner.New(paths, planner.Options{}),
+		source:   "data/upgrade-paths.json",
+		loadedAt: time.Now().UTC(),
+		missing:  make(map[string]string),
+	}
+}
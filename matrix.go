@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// handleMatrixDiff returns, per platform, the Kubernetes minors gained, lost,
+// and kept between the support matrices of two Rancher versions
+func handleMatrixDiff(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		diff, err := ds.Planner().MatrixDiff(c.Params("from"), c.Params("to"))
+		if err != nil {
+			status := fiber.StatusBadRequest
+			var unknown *planner.UnknownRancherVersionError
+			if errors.As(err, &unknown) {
+				status = fiber.StatusNotFound
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"from":      c.Params("from"),
+			"to":        c.Params("to"),
+			"platforms": diff,
+		})
+	}
+}
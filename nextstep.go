@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// nextStep is the immediate next action of a plan
+type nextStep struct {
+	Platform string `json:"platform"`
+	// Step is the first step of the plan, or nil when the cluster is up to date
+	Step *planner.UpgradeStep `json:"step,omitempty"`
+	// RemainingSteps counts the steps of the plan after this one
+	RemainingSteps int `json:"remaining_steps"`
+	// Prerequisites are the checks to run before the step
+	Prerequisites []planner.PreflightCheck `json:"prerequisites,omitempty"`
+	// Validations are the checks to pass after the step before continuing
+	Validations []string `json:"validations,omitempty"`
+	// Warnings are the warnings on the step and the plan as a whole
+	Warnings []planner.Warning `json:"warnings,omitempty"`
+	Meta     *planner.Meta     `json:"meta,omitempty"`
+}
+
+// firstStep reduces the plan to its first step
+func firstStep(plan *planner.Plan) nextStep {
+	next := nextStep{Platform: plan.Platform, Meta: plan.Meta}
+	if len(plan.Steps) == 0 {
+		return next
+	}
+	step := plan.Steps[0]
+	next.Step = &step
+	next.RemainingSteps = len(plan.Steps) - 1
+	next.Prerequisites = plan.Preflight
+	next.Validations = step.Verify
+	for _, w := range plan.Warnings {
+		if w.Step <= 0 {
+			next.Warnings = append(next.Warnings, w)
+		}
+	}
+	return next
+}
+
+// handleNextStep plans the versions like /api/plan-upgrade and returns only
+// the first step, for automation that runs one hop per maintenance window
+func handleNextStep(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := planRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		noteUsage(c, req)
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		plan, err := ds.Planner().PlanContext(ctx, req)
+		recordPlanOutcome(err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+
+		c.Set(fiber.HeaderContentLanguage, plan.Meta.Language)
+		return c.JSON(firstStep(plan))
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// maxNormalizeVersions bounds the versions normalized in a single request
+const maxNormalizeVersions = 100
+
+// handleNormalize normalizes every ?version= of the request
+func handleNormalize(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		inputs := queryValues(c, "version")
+		if len(inputs) == 0 || len(inputs) > maxNormalizeVersions {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "expected 1 to 100 ?version= parameters",
+			})
+		}
+		p := ds.Planner()
+		versions := make([]planner.NormalizedVersion, len(inputs))
+		for i, input := range inputs {
+			versions[i] = p.Normalize(input)
+		}
+		return c.JSON(fiber.Map{"versions": versions})
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// apiOperation documents a route of the API for the OpenAPI document
+type apiOperation struct {
+	Summary string
+	Query   []apiParam
+	// Body and Response are values of the request and response body types,
+	// nil for no body and an untyped JSON object respectively
+	Body     interface{}
+	Response interface{}
+	// Produces lists the media types the route returns besides JSON
+	Produces []string
+}
+
+// apiParam is a query parameter of an API route
+type apiParam struct {
+	Name        string
+	Type        string // OpenAPI type, string when empty
+	Repeated    bool
+	Description string
+}
+
+// apiError is the body of every API error response; failed plans also carry
+// the error's code and message
+type apiError struct {
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// planQuery are the query parameters of the routes planning the versions in
+// their path
+var planQuery = []apiParam{
+	{Name: "strategy", Description: "Step selection: greedy, conservative, or shortest-path"},
+	{Name: "rancher_hops", Description: "Rancher hops: latest-patch (default) or minor-only"},
+	{Name: "target_rancher", Description: "Rancher version to stop at instead of the newest"},
+	{Name: "allow_prerelease", Type: "boolean", Description: "Plan with prerelease Rancher versions like releases"},
+	{Name: "migrate_to", Description: "Platform to migrate the cluster to, e.g. rke2 for rke1"},
+	{Name: "always_supported", Type: "boolean", Description: "Keep the cluster on a supported combination at every step"},
+	{Name: "planned_date", Description: "Date the plan is executed on, as YYYY-MM-DD"},
+	{Name: "ltss", Type: "boolean", Description: "The cluster has an LTSS contract"},
+	{Name: "certificate_expiry", Description: "Date the cluster certificates expire, as YYYY-MM-DD"},
+	{Name: "nodes", Type: "integer", Description: "Number of nodes, for effort estimates"},
+	{Name: "node_os", Description: "Operating system of the nodes, e.g. sles"},
+	{Name: "node_os_version", Description: "Operating system version of the nodes, e.g. 15.4"},
+	{Name: "docker_version", Description: "Docker version of the nodes of an RKE1 cluster, e.g. 20.10.21"},
+	{Name: "auth_provider", Description: "Rancher auth provider of the cluster's users"},
+	{Name: "feature", Repeated: true, Description: "Rancher feature the cluster relies on"},
+	{Name: "extension", Repeated: true, Description: "Installed UI extension as name:range"},
+	{Name: "local_platform", Description: "Platform of the cluster Rancher runs on"},
+	{Name: "local_k8s", Description: "Kubernetes version of the cluster Rancher runs on"},
+	{Name: "local_nodes", Type: "integer", Description: "Nodes of the cluster Rancher runs on"},
+	{Name: "local_cpus", Type: "integer", Description: "CPUs per node of the cluster Rancher runs on"},
+	{Name: "local_memory_gb", Type: "integer", Description: "Memory per node of the cluster Rancher runs on"},
+	{Name: "lang", Description: "Language of notes and warnings: en, de, ja, or zh"},
+}
+
+// planFormatParam chooses the format of a plan
+var planFormatParam = apiParam{Name: "format", Description: "json, yaml, text, markdown, mermaid, or dot; negotiated from Accept when omitted"}
+
+// batchQuery are the query parameters of the routes taking a batch request
+var batchQuery = []apiParam{
+	{Name: "selector", Description: "Label selector of the clusters to plan, e.g. env=prod,team!=payments"},
+	{Name: "lang", Description: "Language of notes and warnings: en, de, ja, or zh"},
+}
+
+// apiOperations documents the API routes by method and path as registered.
+// Routes missing here are still listed, without schemas.
+var apiOperations = map[string]apiOperation{
+	"GET /api/plan-upgrade/:platform/:rancher/:k8s": {
+		Summary: "Generate the upgrade plan of a cluster",
+		Query: append([]apiParam{
+			{Name: "changelog", Description: "Attach release notes: rancher or all"},
+			planFormatParam,
+		}, planQuery...),
+		Response: planner.Plan{},
+		Produces: []string{"application/yaml", fiber.MIMETextPlain, "text/markdown"},
+	},
+	"POST /api/plan-upgrade": {
+		Summary:  "Generate the upgrade plan of the request body",
+		Query:    []apiParam{planFormatParam},
+		Body:     planUpgradeRequest{},
+		Response: planner.Plan{},
+		Produces: []string{"application/yaml", fiber.MIMETextPlain, "text/markdown"},
+	},
+	"GET /api/next-step/:platform/:rancher/:k8s": {
+		Summary:  "Return the first step of the upgrade plan",
+		Query:    planQuery,
+		Response: nextStep{},
+	},
+	"GET /api/matrix-diff/:from/:to": {
+		Summary: "Compare the support matrices of two Rancher versions",
+		Response: struct {
+			From      string                          `json:"from"`
+			To        string                          `json:"to"`
+			Platforms map[string]planner.MatrixChange `json:"platforms"`
+		}{},
+	},
+	"GET /api/patch-remediation/:platform/:rancher/:k8s": {
+		Summary:  "Recommend the newest Kubernetes patch the running Rancher supports",
+		Response: planner.PatchRecommendation{},
+	},
+	"GET /api/versions": {
+		Summary: "List the Rancher versions in the data",
+		Response: struct {
+			Versions []string `json:"versions"`
+		}{},
+	},
+	"GET /api/versions/:rancher": {
+		Summary:  "Return the platforms and Kubernetes ranges a Rancher version supports",
+		Response: rancherVersion{},
+	},
+	"GET /api/compat/rancher-for-k8s/:platform/:k8s": {
+		Summary:  "List the Rancher versions supporting a Kubernetes version",
+		Response: planner.RancherCompatibility{},
+	},
+	"GET /api/compat/graph/:platform": {
+		Summary:  "Render the compatibility graph of a platform as a diagram",
+		Query:    []apiParam{{Name: "format", Description: "mermaid (default) or dot"}},
+		Produces: []string{fiber.MIMETextPlain, "text/vnd.graphviz"},
+	},
+	"GET /api/normalize": {
+		Summary: "Normalize version strings",
+		Query:   []apiParam{{Name: "version", Repeated: true, Description: "Version to normalize, up to 100"}},
+		Response: struct {
+			Versions []planner.NormalizedVersion `json:"versions"`
+		}{},
+	},
+	"GET /api/data/export": {
+		Summary: "Export the loaded compatibility data",
+		Query:   []apiParam{{Name: "format", Description: "json or yaml"}},
+		Response: struct {
+			Provenance dataProvenance       `json:"provenance"`
+			Data       planner.UpgradePaths `json:"data"`
+		}{},
+		Produces: []string{"application/yaml"},
+	},
+	"GET /api/version": {
+		Summary: "Return the running build and the data in use",
+		Response: struct {
+			Build   buildInfo      `json:"build"`
+			Dataset dataProvenance `json:"dataset"`
+		}{},
+	},
+	"GET /api/blackouts": {
+		Summary: "List the blackout periods",
+		Response: struct {
+			Blackouts []planner.Blackout `json:"blackouts"`
+		}{},
+	},
+	"GET /api/security-delta/:platform/:rancher/:k8s": {
+		Summary:  "Report the advisories an upgrade fixes and introduces",
+		Query:    planQuery,
+		Response: planner.SecurityDelta{},
+	},
+	"POST /api/fleet-report": {
+		Summary: "Report the upgrade posture of a fleet",
+		Query: append([]apiParam{
+			{Name: "format", Description: "json or markdown"},
+			{Name: "top", Type: "integer", Description: "Number of most common warnings and errors, default 10"},
+			{Name: "group_by", Description: "Label to break the report down by"},
+		}, batchQuery...),
+		Body:     batchRequest{},
+		Response: fleetReport{},
+		Produces: []string{"text/markdown"},
+	},
+	"POST /api/plan-what-if": {
+		Summary:  "Plan against hypothetical changes to the data",
+		Body:     whatIfRequest{},
+		Response: planner.Plan{},
+	},
+	"POST /api/fleet-export": {
+		Summary: "Export the plan of the request body as a Fleet repository",
+		Query: []apiParam{
+			{Name: "repo", Description: "Git repository the GitRepo deploys from"},
+			{Name: "selector", Description: "Labels of the clusters to upgrade"},
+			{Name: "name", Description: "Name of the GitRepo, default rancher-upgrade"},
+			{Name: "branch", Description: "Branch of the GitRepo, default main"},
+		},
+		Body:     planner.Request{},
+		Produces: []string{"application/gzip"},
+	},
+	"POST /api/plan-resume": {
+		Summary:  "Recompute the rest of a partially executed plan",
+		Body:     resumeRequest{},
+		Response: resumeResponse{},
+	},
+	"POST /api/plan-batch": {
+		Summary:  "Plan many clusters",
+		Query:    batchQuery,
+		Body:     batchRequest{},
+		Response: batchResponse{},
+		Produces: []string{mimeNDJSON},
+	},
+	"GET /api/watched-plans": {
+		Summary: "Return the latest plan of every watched cluster",
+		Response: struct {
+			Clusters []watchedPlan `json:"clusters"`
+		}{},
+	},
+	"GET /api/openapi.json": {
+		Summary: "Return this document",
+	},
+}
+
+// handleOpenAPI returns the OpenAPI document of the app's /api routes,
+// generated on the first request once every route is registered
+func handleOpenAPI(app *fiber.App) fiber.Handler {
+	var once sync.Once
+	var doc []byte
+	var err error
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			doc, err = json.Marshal(openAPIDocument(app.GetRoutes(true)))
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(doc)
+	}
+}
+
+// openAPIDocument builds the OpenAPI 3 document of the /api routes, with the
+// schemas of their bodies derived from the Go types
+func openAPIDocument(routes []fiber.Route) fiber.Map {
+	schemas := &schemaBuilder{schemas: make(map[string]interface{})}
+	paths := make(map[string]fiber.Map)
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, "/api/") || r.Method == fiber.MethodHead {
+			continue
+		}
+		op := apiOperations[r.Method+" "+r.Path]
+		path, params := openAPIPath(r.Path)
+		for _, q := range op.Query {
+			params = append(params, queryParam(q))
+		}
+
+		content := fiber.Map{}
+		if op.Response != nil {
+			content[fiber.MIMEApplicationJSON] = fiber.Map{"schema": schemas.schema(reflect.TypeOf(op.Response))}
+		} else if len(op.Produces) == 0 {
+			content[fiber.MIMEApplicationJSON] = fiber.Map{"schema": fiber.Map{"type": "object"}}
+		}
+		for _, mime := range op.Produces {
+			content[mime] = fiber.Map{"schema": fiber.Map{"type": "string"}}
+		}
+		summary := op.Summary
+		if summary == "" {
+			summary = r.Method + " " + r.Path
+		}
+		operation := fiber.Map{
+			"summary": summary,
+			"responses": fiber.Map{
+				"200": fiber.Map{"description": "Success", "content": content},
+				"default": fiber.Map{
+					"description": "Error",
+					"content": fiber.Map{
+						fiber.MIMEApplicationJSON: fiber.Map{"schema": schemas.schema(reflect.TypeOf(apiError{}))},
+					},
+				},
+			},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Body != nil {
+			operation["requestBody"] = fiber.Map{
+				"required": true,
+				"content": fiber.Map{
+					fiber.MIMEApplicationJSON: fiber.Map{"schema": schemas.schema(reflect.TypeOf(op.Body))},
+				},
+			}
+		}
+		if paths[path] == nil {
+			paths[path] = fiber.Map{}
+		}
+		paths[path][strings.ToLower(r.Method)] = operation
+	}
+
+	return fiber.Map{
+		"openapi": "3.0.3",
+		"info": fiber.Map{
+			"title":   "Rancher Upgrade Tool",
+			"version": currentBuild().Version,
+		},
+		"paths":      paths,
+		"components": fiber.Map{"schemas": schemas.schemas},
+	}
+}
+
+// openAPIPath converts a Fiber route path to an OpenAPI path template and
+// returns its path parameters
+func openAPIPath(route string) (string, []fiber.Map) {
+	var params []fiber.Map
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") {
+			continue
+		}
+		name := strings.TrimSuffix(s[1:], "?")
+		segments[i] = "{" + name + "}"
+		params = append(params, fiber.Map{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   fiber.Map{"type": "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// queryParam returns the OpenAPI parameter object of a query parameter
+func queryParam(q apiParam) fiber.Map {
+	typ := q.Type
+	if typ == "" {
+		typ = "string"
+	}
+	schema := fiber.Map{"type": typ}
+	param := fiber.Map{"name": q.Name, "in": "query", "description": q.Description}
+	if q.Repeated {
+		schema = fiber.Map{"type": "array", "items": schema}
+		param["explode"] = true
+	}
+	param["schema"] = schema
+	return param
+}
+
+// schemaBuilder derives JSON schemas from Go types the way encoding/json
+// marshals them, collecting named structs as components
+type schemaBuilder struct {
+	schemas map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of the type, a reference for named structs
+func (b *schemaBuilder) schema(t reflect.Type) fiber.Map {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return fiber.Map{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := schemaName(t)
+		if _, ok := b.schemas[name]; !ok {
+			// Registered first so recursive types end in a reference
+			b.schemas[name] = fiber.Map{}
+			b.schemas[name] = b.object(t)
+		}
+		return fiber.Map{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.object(t)
+	case reflect.Bool:
+		return fiber.Map{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fiber.Map{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return fiber.Map{"type": "number"}
+	case reflect.String:
+		return fiber.Map{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return fiber.Map{"type": "string", "format": "byte"}
+		}
+		return fiber.Map{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return fiber.Map{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	}
+	return fiber.Map{}
+}
+
+// object returns the object schema of a struct's JSON fields, including
+// those of embedded structs
+func (b *schemaBuilder) object(t reflect.Type) fiber.Map {
+	properties := fiber.Map{}
+	b.addFields(t, properties)
+	return fiber.Map{"type": "object", "properties": properties}
+}
+
+// addFields adds the schemas of the struct's JSON fields to the properties
+func (b *schemaBuilder) addFields(t reflect.Type, properties fiber.Map) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.schema(f.Type)
+	}
+}
+
+// schemaName is the component name of a named type, exported style
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
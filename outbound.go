@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/remote"
+)
+
+// outbound is the shared client for every fetch to a remote data source
+var outbound *remote.Client
+
+// newOutboundClient builds the outbound client from OUTBOUND_* environment variables
+func newOutboundClient() (*remote.Client, error) {
+	def := remote.DefaultOptions()
+	opts := remote.Options{
+		Timeout:          envDuration("OUTBOUND_TIMEOUT", def.Timeout),
+		MaxRetries:       envInt("OUTBOUND_MAX_RETRIES", def.MaxRetries),
+		InitialBackoff:   envDuration("OUTBOUND_INITIAL_BACKOFF", def.InitialBackoff),
+		MaxBackoff:       envDuration("OUTBOUND_MAX_BACKOFF", def.MaxBackoff),
+		FailureThreshold: envInt("OUTBOUND_BREAKER_THRESHOLD", def.FailureThreshold),
+		OpenTimeout:      envDuration("OUTBOUND_BREAKER_TIMEOUT", def.OpenTimeout),
+		OnStateChange: func(host string, state remote.State) {
+			// CLI commands run without metrics
+			if outboundBreakerState != nil {
+				outboundBreakerState.WithLabelValues(host).Set(float64(state))
+			}
+		},
+	}
+
+	// Additional CA bundles, e.g. for TLS-intercepting corporate proxies
+	if bundles := envString("OUTBOUND_CA_BUNDLES", ""); bundles != "" {
+		pool, err := remote.LoadCertPool(strings.Split(bundles, ",")...)
+		if err != nil {
+			return nil, err
+		}
+		opts.RootCAs = pool
+	}
+
+	return remote.New(opts), nil
+}
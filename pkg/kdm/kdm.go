@@ -0,0 +1,295 @@
+// Package kdm turns Rancher's kontainer-driver-metadata (KDM) into
+// compatibility data. KDM lists every RKE1, RKE2, and K3s Kubernetes release
+// with the range of Rancher versions that may deploy it, which is the same
+// information the supported_platforms of the compatibility data hold by
+// Rancher version.
+package kdm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// Metadata is the part of a KDM data.json the importer reads
+type Metadata struct {
+	RKE2 Distribution `json:"rke2"`
+	K3s  Distribution `json:"k3s"`
+
+	// K8sVersionInfo holds the Rancher versions supporting each RKE1
+	// Kubernetes version, keyed by version, e.g. v1.26.8-rancher1-1
+	K8sVersionInfo map[string]RKE1Info `json:"K8sVersionInfo"`
+}
+
+// Distribution is the release data of RKE2 or K3s
+type Distribution struct {
+	Releases []Release `json:"releases"`
+}
+
+// Release is an RKE2 or K3s release with the Rancher versions, as channel
+// server versions, that may deploy it
+type Release struct {
+	Version                 string `json:"version"`
+	MinChannelServerVersion string `json:"minChannelServerVersion"`
+	MaxChannelServerVersion string `json:"maxChannelServerVersion"`
+}
+
+// RKE1Info is the Rancher version range of an RKE1 Kubernetes version
+type RKE1Info struct {
+	MinRancherVersion string `json:"minRancherVersion"`
+	MaxRancherVersion string `json:"maxRancherVersion"`
+}
+
+// Parse reads a KDM data.json
+func Parse(data []byte) (*Metadata, error) {
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse KDM data: %v", err)
+	}
+	return &m, nil
+}
+
+// Add merges the releases of other into the metadata, such as those of the
+// KDM branches of several Rancher minors. Releases listed in both keep the
+// entry of other.
+func (m *Metadata) Add(other *Metadata) {
+	m.RKE2.Releases = mergeReleases(m.RKE2.Releases, other.RKE2.Releases)
+	m.K3s.Releases = mergeReleases(m.K3s.Releases, other.K3s.Releases)
+	if m.K8sVersionInfo == nil {
+		m.K8sVersionInfo = make(map[string]RKE1Info, len(other.K8sVersionInfo))
+	}
+	for v, info := range other.K8sVersionInfo {
+		m.K8sVersionInfo[v] = info
+	}
+}
+
+// mergeReleases returns the releases of both lists, those of b replacing
+// those of a with the same version
+func mergeReleases(a, b []Release) []Release {
+	index := make(map[string]int, len(a))
+	merged := append([]Release(nil), a...)
+	for i, r := range merged {
+		index[r.Version] = i
+	}
+	for _, r := range b {
+		if i, ok := index[r.Version]; ok {
+			merged[i] = r
+			continue
+		}
+		index[r.Version] = len(merged)
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// platformVersion is a Kubernetes version with the Rancher range deploying it
+type platformVersion struct {
+	k8s      *version.Version
+	min, max *version.Version // Nil when unbounded
+}
+
+// Import returns the compatibility data KDM describes for the Rancher
+// versions: the Kubernetes minors each version supports on RKE1, RKE2, and
+// K3s, and their published releases. RKE1 releases are listed without their
+// system images revision. Rancher versions KDM has no
+// Kubernetes version for are left out.
+func (m *Metadata) Import(rancherVersions []string) planner.UpgradePaths {
+	platforms := map[string][]platformVersion{
+		"RKE2": m.RKE2.versions(),
+		"K3s":  m.K3s.versions(),
+		"RKE1": m.rke1Versions(),
+	}
+
+	paths := planner.UpgradePaths{
+		RancherManager: make(map[string]planner.RancherManagerVersion),
+		Releases: map[string][]string{
+			"rke1": releaseNames(platforms["RKE1"]),
+			"rke2": releaseNames(platforms["RKE2"]),
+			"k3s":  releaseNames(platforms["K3s"]),
+		},
+	}
+	for _, rv := range rancherVersions {
+		rancher, err := version.NewVersion(rv)
+		if err != nil {
+			continue
+		}
+		var supported []planner.Platform
+		for _, name := range []string{"RKE2", "RKE1", "K3s"} {
+			if p, ok := supportedRange(name, platforms[name], rancher); ok {
+				supported = append(supported, p)
+			}
+		}
+		if len(supported) > 0 {
+			paths.RancherManager[rv] = planner.RancherManagerVersion{SupportedPlatforms: supported}
+		}
+	}
+	return paths
+}
+
+// supportedRange returns the range of Kubernetes minors the Rancher version
+// may deploy on the platform
+func supportedRange(platform string, versions []platformVersion, rancher *version.Version) (planner.Platform, bool) {
+	var lo, hi *version.Version
+	for _, v := range versions {
+		if (v.min != nil && rancher.LessThan(v.min)) || (v.max != nil && rancher.GreaterThan(v.max)) {
+			continue
+		}
+		if lo == nil || v.k8s.LessThan(lo) {
+			lo = v.k8s
+		}
+		if hi == nil || v.k8s.GreaterThan(hi) {
+			hi = v.k8s
+		}
+	}
+	if lo == nil {
+		return planner.Platform{}, false
+	}
+	return planner.Platform{Platform: platform, MinVersion: minorOf(lo), MaxVersion: minorOf(hi)}, true
+}
+
+// versions returns the parsable releases of the distribution
+func (d Distribution) versions() []platformVersion {
+	var versions []platformVersion
+	for _, r := range d.Releases {
+		k8s, err := version.NewVersion(r.Version)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, platformVersion{
+			k8s: k8s,
+			min: channelServerVersion(r.MinChannelServerVersion),
+			max: channelServerVersion(r.MaxChannelServerVersion),
+		})
+	}
+	return versions
+}
+
+// releaseNames returns the Kubernetes versions once, sorted
+func releaseNames(versions []platformVersion) []string {
+	seen := make(map[string]bool, len(versions))
+	var parsed []*version.Version
+	for _, v := range versions {
+		if !seen[v.k8s.Original()] {
+			seen[v.k8s.Original()] = true
+			parsed = append(parsed, v.k8s)
+		}
+	}
+	sort.Sort(version.Collection(parsed))
+	names := make([]string, 0, len(parsed))
+	for _, v := range parsed {
+		names = append(names, v.Original())
+	}
+	return names
+}
+
+// rke1Versions returns the parsable RKE1 Kubernetes versions
+func (m *Metadata) rke1Versions() []platformVersion {
+	var versions []platformVersion
+	for v, info := range m.K8sVersionInfo {
+		// RKE1 versions carry their system images revision, e.g. v1.26.8-rancher1-1
+		base, _, _ := strings.Cut(v, "-")
+		k8s, err := version.NewVersion(base)
+		if err != nil || len(k8s.Segments()) < 3 || !strings.Contains(v, "-rancher") {
+			continue
+		}
+		versions = append(versions, platformVersion{
+			k8s: k8s,
+			min: channelServerVersion(info.MinRancherVersion),
+			max: channelServerVersion(info.MaxRancherVersion),
+		})
+	}
+	return versions
+}
+
+// channelServerVersion parses a Rancher version bound, nil when it is unset
+// or unparsable. Bounds such as v2.7.5-alpha1 or 2.7.5-patch0 sort before
+// the release, so they include it.
+func channelServerVersion(v string) *version.Version {
+	if v == "" {
+		return nil
+	}
+	parsed, err := version.NewVersion(v)
+	if err != nil {
+		return nil
+	}
+	return parsed
+}
+
+// minorOf returns the Kubernetes minor of the version, e.g. v1.26
+func minorOf(v *version.Version) string {
+	s := v.Segments()
+	return fmt.Sprintf("v%d.%d", s[0], s[1])
+}
+
+// Apply returns the base data with the imported ranges and releases applied,
+// leaving both unchanged. The ranges KDM has for a Rancher version replace
+// the base's ranges of the same platforms, keeping their notes and order.
+// Platforms KDM has no range for, such as hosted ones, and everything else
+// in the base stay as they are. Imported releases are added to the base's.
+func Apply(base, imported planner.UpgradePaths) planner.UpgradePaths {
+	merged := base
+	merged.RancherManager = make(map[string]planner.RancherManagerVersion, len(base.RancherManager)+len(imported.RancherManager))
+	for v, r := range base.RancherManager {
+		merged.RancherManager[v] = r
+	}
+	for v, r := range imported.RancherManager {
+		ranges := make(map[string]planner.Platform, len(r.SupportedPlatforms))
+		for _, p := range r.SupportedPlatforms {
+			ranges[strings.ToLower(p.Platform)] = p
+		}
+
+		existing := merged.RancherManager[v]
+		platforms := make([]planner.Platform, 0, len(existing.SupportedPlatforms)+len(ranges))
+		for _, p := range existing.SupportedPlatforms {
+			if imp, ok := ranges[strings.ToLower(p.Platform)]; ok {
+				p.MinVersion, p.MaxVersion = imp.MinVersion, imp.MaxVersion
+				delete(ranges, strings.ToLower(p.Platform))
+			}
+			platforms = append(platforms, p)
+		}
+		for _, p := range r.SupportedPlatforms {
+			if _, ok := ranges[strings.ToLower(p.Platform)]; ok {
+				platforms = append(platforms, p)
+			}
+		}
+		existing.SupportedPlatforms = platforms
+		merged.RancherManager[v] = existing
+	}
+
+	merged.Releases = make(map[string][]string, len(base.Releases)+len(imported.Releases))
+	for platform, releases := range base.Releases {
+		merged.Releases[platform] = releases
+	}
+	for platform, releases := range imported.Releases {
+		merged.Releases[platform] = unionReleases(merged.Releases[platform], releases)
+	}
+	return merged
+}
+
+// unionReleases returns the releases of both lists once, sorted by version
+func unionReleases(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var parsed []*version.Version
+	var unparsable []string
+	for _, r := range append(append([]string(nil), a...), b...) {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		if v, err := version.NewVersion(r); err == nil {
+			parsed = append(parsed, v)
+		} else {
+			unparsable = append(unparsable, r)
+		}
+	}
+	sort.Sort(version.Collection(parsed))
+	releases := make([]string, 0, len(parsed)+len(unparsable))
+	for _, v := range parsed {
+		releases = append(releases, v.Original())
+	}
+	return append(releases, unparsable...)
+}
@@ -0,0 +1,166 @@
+package kdm_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/kdm"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// loadMetadata parses the trimmed KDM data.json in testdata
+func loadMetadata(t *testing.T) *kdm.Metadata {
+	t.Helper()
+	data, err := os.ReadFile("testdata/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := kdm.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// platform is shorthand for a range without notes
+func platform(name, lo, hi string) planner.Platform {
+	return planner.Platform{Platform: name, MinVersion: lo, MaxVersion: hi}
+}
+
+// TestImport checks the ranges and releases imported for each Rancher
+// version, covering open channel server bounds, prerelease bounds, and RKE1
+// versions with their system images revision
+func TestImport(t *testing.T) {
+	paths := loadMetadata(t).Import([]string{"2.6.14", "2.7.1", "2.7.5", "2.8.0", "2.9.1", "bogus"})
+
+	expected := map[string][]planner.Platform{
+		// v1.24 has no lower bound; the v1.26 RKE1 entry has no -rancher
+		// revision and is skipped
+		"2.6.14": {platform("RKE2", "v1.24", "v1.24")},
+		// v2.7.2-alpha1 admits v2.7.2 but not v2.7.1
+		"2.7.1": {platform("RKE2", "v1.24", "v1.25"), platform("RKE1", "v1.25", "v1.25")},
+		"2.7.5": {platform("RKE2", "v1.24", "v1.26"), platform("RKE1", "v1.25", "v1.26")},
+		"2.8.0": {platform("RKE2", "v1.25", "v1.26"), platform("RKE1", "v1.26", "v1.27"), platform("K3s", "v1.27", "v1.27")},
+		// v1.26 on RKE2 and v1.27 on RKE1 have no upper bound
+		"2.9.1": {platform("RKE2", "v1.26", "v1.26"), platform("RKE1", "v1.27", "v1.27"), platform("K3s", "v1.27", "v1.27")},
+	}
+	if len(paths.RancherManager) != len(expected) {
+		t.Errorf("imported %d Rancher versions, expected %d", len(paths.RancherManager), len(expected))
+	}
+	for v, platforms := range expected {
+		if got := paths.RancherManager[v].SupportedPlatforms; !reflect.DeepEqual(got, platforms) {
+			t.Errorf("Rancher %s: got %v, expected %v", v, got, platforms)
+		}
+	}
+
+	releases := map[string][]string{
+		"rke1": {"v1.25.9", "v1.26.8", "v1.27.16"},
+		"rke2": {"v1.24.17+rke2r1", "v1.25.16+rke2r1", "v1.26.15+rke2r1"},
+		"k3s":  {"v1.27.16+k3s1"},
+	}
+	if !reflect.DeepEqual(paths.Releases, releases) {
+		t.Errorf("releases %v, expected %v", paths.Releases, releases)
+	}
+}
+
+// TestAdd checks that merged metadata keeps the releases of both, preferring
+// the added entry of a release listed in both
+func TestAdd(t *testing.T) {
+	m := loadMetadata(t)
+	other, err := kdm.Parse([]byte(`{
+		"rke2": {"releases": [
+			{"version": "v1.26.15+rke2r1", "minChannelServerVersion": "v2.8.0"},
+			{"version": "v1.27.16+rke2r1", "minChannelServerVersion": "v2.8.0"}
+		]},
+		"K8sVersionInfo": {"v1.25.9-rancher2-2": {"minRancherVersion": "v2.7.3"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add(other)
+
+	paths := m.Import([]string{"2.7.5", "2.8.0"})
+	expected := map[string][]planner.Platform{
+		"2.7.5": {platform("RKE2", "v1.24", "v1.25"), platform("RKE1", "v1.25", "v1.26")},
+		"2.8.0": {platform("RKE2", "v1.25", "v1.27"), platform("RKE1", "v1.25", "v1.27"), platform("K3s", "v1.27", "v1.27")},
+	}
+	for v, platforms := range expected {
+		if got := paths.RancherManager[v].SupportedPlatforms; !reflect.DeepEqual(got, platforms) {
+			t.Errorf("Rancher %s: got %v, expected %v", v, got, platforms)
+		}
+	}
+}
+
+// TestApply checks that imported ranges replace those of the same platforms
+// in place, keeping their notes, that new platforms are appended in import
+// order, and that everything else in the base is kept
+func TestApply(t *testing.T) {
+	imported := loadMetadata(t).Import([]string{"2.8.0", "2.9.1"})
+	base := planner.UpgradePaths{
+		Version: "base",
+		RancherManager: map[string]planner.RancherManagerVersion{
+			"2.6.14": {SupportedPlatforms: []planner.Platform{platform("RKE2", "v1.21", "v1.24")}},
+			"2.8.0": {
+				SupportedPlatforms: []planner.Platform{
+					{Platform: "AKS", MinVersion: "v1.25", MaxVersion: "v1.27", Notes: "hosted"},
+					{Platform: "k3s", MinVersion: "v1.24", MaxVersion: "v1.26", Notes: "k3s note"},
+					{Platform: "RKE2", MinVersion: "v1.24", MaxVersion: "v1.26", Notes: "rke2 note"},
+				},
+				Waypoint: true,
+			},
+		},
+		Releases: map[string][]string{
+			"rke2": {"v1.25.16+rke2r1", "v1.23.17+rke2r1"},
+			"aks":  {"v1.27"},
+		},
+	}
+	baseCopy := planner.UpgradePaths{
+		Version:        base.Version,
+		RancherManager: map[string]planner.RancherManagerVersion{},
+		Releases:       map[string][]string{},
+	}
+	for v, r := range base.RancherManager {
+		r.SupportedPlatforms = append([]planner.Platform(nil), r.SupportedPlatforms...)
+		baseCopy.RancherManager[v] = r
+	}
+	for p, r := range base.Releases {
+		baseCopy.Releases[p] = append([]string(nil), r...)
+	}
+
+	merged := kdm.Apply(base, imported)
+
+	expected := map[string]planner.RancherManagerVersion{
+		"2.6.14": base.RancherManager["2.6.14"],
+		"2.8.0": {
+			SupportedPlatforms: []planner.Platform{
+				{Platform: "AKS", MinVersion: "v1.25", MaxVersion: "v1.27", Notes: "hosted"},
+				{Platform: "k3s", MinVersion: "v1.27", MaxVersion: "v1.27", Notes: "k3s note"},
+				{Platform: "RKE2", MinVersion: "v1.25", MaxVersion: "v1.26", Notes: "rke2 note"},
+				platform("RKE1", "v1.26", "v1.27"),
+			},
+			Waypoint: true,
+		},
+		"2.9.1": imported.RancherManager["2.9.1"],
+	}
+	if !reflect.DeepEqual(merged.RancherManager, expected) {
+		t.Errorf("Rancher versions:\n got %v\n expected %v", merged.RancherManager, expected)
+	}
+	if merged.Version != "base" {
+		t.Errorf("version %q, expected the base's", merged.Version)
+	}
+
+	releases := map[string][]string{
+		"rke1": {"v1.25.9", "v1.26.8", "v1.27.16"},
+		"rke2": {"v1.23.17+rke2r1", "v1.24.17+rke2r1", "v1.25.16+rke2r1", "v1.26.15+rke2r1"},
+		"k3s":  {"v1.27.16+k3s1"},
+		"aks":  {"v1.27"},
+	}
+	if !reflect.DeepEqual(merged.Releases, releases) {
+		t.Errorf("releases %v, expected %v", merged.Releases, releases)
+	}
+
+	if !reflect.DeepEqual(base, baseCopy) {
+		t.Errorf("Apply changed the base data")
+	}
+}
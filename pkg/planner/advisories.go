@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Advisory is a published security advisory listed in the data
+type Advisory struct {
+	ID string `json:"id"` // e.g. a CVE or GHSA identifier
+	// Component is "rancher", "kubernetes" for every platform, or a platform
+	// such as rke2 for advisories of that distribution only
+	Component string `json:"component"`
+	// Affected is the range of affected versions in go-version constraint
+	// syntax, e.g. ">= 2.7.0, < 2.7.14". Advisories fixed on several release
+	// lines list one range per line, separated by "||".
+	Affected string `json:"affected"`
+	Severity string `json:"severity,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// VersionChange is the version of a component before and after a plan
+type VersionChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SecurityDelta lists the advisories the plan's final versions fix, and
+// those affecting the final versions but not the current ones
+type SecurityDelta struct {
+	Rancher    VersionChange `json:"rancher"`
+	Kubernetes VersionChange `json:"kubernetes"`
+	Fixed      []Advisory    `json:"fixed"`
+	Introduced []Advisory    `json:"introduced"`
+}
+
+// securityDelta compares the advisories affecting the current and final
+// versions of the steps
+func securityDelta(advisories []Advisory, platform, currentRancher, currentK8s string, steps []UpgradeStep) *SecurityDelta {
+	d := &SecurityDelta{
+		Rancher:    VersionChange{From: currentRancher, To: currentRancher},
+		Kubernetes: VersionChange{From: currentK8s, To: currentK8s},
+		Fixed:      []Advisory{},
+		Introduced: []Advisory{},
+	}
+	for _, step := range steps {
+		switch step.Type {
+		case "Rancher":
+			d.Rancher.To = step.To
+		case "Kubernetes", "Migration":
+			d.Kubernetes.To = step.To
+		}
+	}
+
+	for _, a := range advisories {
+		var change VersionChange
+		switch component := strings.ToLower(a.Component); {
+		case component == "rancher":
+			change = d.Rancher
+		case component == "kubernetes" || component == platform:
+			change = d.Kubernetes
+		default:
+			continue
+		}
+		before, after := a.affects(change.From), a.affects(change.To)
+		switch {
+		case before && !after:
+			d.Fixed = append(d.Fixed, a)
+		case after && !before:
+			d.Introduced = append(d.Introduced, a)
+		}
+	}
+	sort.SliceStable(d.Fixed, func(i, j int) bool { return d.Fixed[i].ID < d.Fixed[j].ID })
+	sort.SliceStable(d.Introduced, func(i, j int) bool { return d.Introduced[i].ID < d.Introduced[j].ID })
+	return d
+}
+
+// affects reports whether the version lies within any of the advisory's
+// affected ranges
+func (a Advisory) affects(v string) bool {
+	for _, r := range strings.Split(a.Affected, "||") {
+		if satisfies(v, strings.TrimSpace(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that every affected range of the advisory parses
+func (a Advisory) validate() error {
+	for _, r := range strings.Split(a.Affected, "||") {
+		if _, err := version.NewConstraint(strings.TrimSpace(r)); err != nil {
+			return fmt.Errorf("advisory %q has invalid affected range %q: %v", a.ID, a.Affected, err)
+		}
+	}
+	return nil
+}
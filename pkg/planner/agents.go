@@ -0,0 +1,15 @@
+package planner
+
+// annotateAgents adds the downstream agent guidance of Rancher steps: how long
+// agents may lag behind, and a check that they were redeployed before the
+// plan continues
+func annotateAgents(steps []UpgradeStep, pr printer) {
+	for i := range steps {
+		step := &steps[i]
+		if step.Type != "Rancher" {
+			continue
+		}
+		step.Notes = append(step.Notes, pr.sprintf("Downstream cattle-cluster-agents are only supported on the Rancher %s agent while Rancher redeploys them after this step; an agent that does not move to %s failed to redeploy and must be fixed", step.From, step.To))
+		step.Verify = append(step.Verify, pr.sprintf("Verify that the cattle-cluster-agent and fleet-agent of every downstream cluster run the Rancher %s images and are ready before continuing", step.To))
+	}
+}
@@ -0,0 +1,77 @@
+package planner
+
+import (
+	"fmt"
+	"time"
+)
+
+// Blackout is a period no upgrade may be planned in, such as a year-end
+// freeze or an audit
+type Blackout struct {
+	Name  string `json:"name,omitempty"`
+	Start string `json:"start"` // First day, YYYY-MM-DD
+	End   string `json:"end"`   // Last day, YYYY-MM-DD
+}
+
+// Validate checks the dates of the blackout
+func (b Blackout) Validate() error {
+	start, err := time.Parse(dateLayout, b.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start %q of blackout %q, expected YYYY-MM-DD", b.Start, b.Name)
+	}
+	end, err := time.Parse(dateLayout, b.End)
+	if err != nil {
+		return fmt.Errorf("invalid end %q of blackout %q, expected YYYY-MM-DD", b.End, b.Name)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("blackout %q ends before it starts", b.Name)
+	}
+	return nil
+}
+
+// covers reports whether the day is in the blackout
+func (b Blackout) covers(day time.Time) bool {
+	start, err1 := time.Parse(dateLayout, b.Start)
+	end, err2 := time.Parse(dateLayout, b.End)
+	return err1 == nil && err2 == nil && !day.Before(start) && !day.After(end)
+}
+
+// BlackoutError is returned when the planned date falls in a blackout
+type BlackoutError struct {
+	Date     string
+	Blackout Blackout
+	Next     string // First day after the date outside every blackout
+}
+
+// Error implements error
+func (e *BlackoutError) Error() string {
+	return fmt.Sprintf("planned date %s falls in the blackout %q from %s to %s; the next date outside blackouts is %s",
+		e.Date, e.Blackout.Name, e.Blackout.Start, e.Blackout.End, e.Next)
+}
+
+// checkBlackouts rejects a planned date inside a blackout, suggesting the
+// next day outside all of them
+func checkBlackouts(blackouts []Blackout, day time.Time) error {
+	var hit *Blackout
+	for i := range blackouts {
+		if blackouts[i].covers(day) {
+			hit = &blackouts[i]
+			break
+		}
+	}
+	if hit == nil {
+		return nil
+	}
+
+	next := day
+	for moved := true; moved; {
+		moved = false
+		for _, b := range blackouts {
+			if b.covers(next) {
+				end, _ := time.Parse(dateLayout, b.End)
+				next, moved = end.AddDate(0, 0, 1), true
+			}
+		}
+	}
+	return &BlackoutError{Date: day.Format(dateLayout), Blackout: *hit, Next: next.Format(dateLayout)}
+}
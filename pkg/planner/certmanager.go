@@ -0,0 +1,119 @@
+package planner
+
+import (
+	"sort"
+
+	"github.com/hashicorp/go-version"
+)
+
+// RuleCertManager identifies the warnings added when the cert-manager
+// version of the cluster does not meet the requirement of a Rancher step's
+// target and no upgrade can be planned to meet it
+const RuleCertManager = "cert-manager"
+
+// CertManagerFact is the fact holding the installed cert-manager version
+const CertManagerFact = "cert-manager"
+
+// interleaveCertManager inserts a cert-manager step before every Rancher
+// step whose target does not support the installed cert-manager version,
+// upgrading to the newest release both the running and the target Rancher
+// version support. When no release satisfies both, the cert-manager step
+// follows the Rancher step and a warning points out the gap. Without a
+// cert-manager fact, Rancher steps raising the requirement carry a warning
+// instead. Warnings are moved along with their steps.
+func interleaveCertManager(paths UpgradePaths, steps []UpgradeStep, warnings []Warning, installed string, pr printer) ([]UpgradeStep, []Warning) {
+	releases := certManagerReleases(paths)
+
+	var result []UpgradeStep
+	moved := make([]int, len(steps))
+	var added []Warning
+	for i, step := range steps {
+		var after *UpgradeStep
+		from, to := paths.RancherManager[step.From].CertManager, paths.RancherManager[step.To].CertManager
+		switch {
+		case step.Type != "Rancher" || to == "":
+		case installed == "":
+			if to != from {
+				added = append(added, Warning{
+					Rule:    RuleCertManager,
+					Step:    len(result),
+					Message: pr.sprintf("Rancher %s requires cert-manager %s; pass the installed version as the cert-manager fact to plan its upgrade", step.To, to),
+				})
+			}
+		case satisfies(installed, to):
+		default:
+			if next, ok := newestCertManager(releases, installed, from, to); ok {
+				result = append(result, certManagerStep(installed, next, pr.sprintf("Rancher %s does not support cert-manager %s; upgrade cert-manager before this Rancher upgrade", step.To, installed)))
+				installed = next
+			} else if next, ok := newestCertManager(releases, installed, to); ok {
+				s := certManagerStep(installed, next, pr.sprintf("No cert-manager release supports both Rancher %s and %s; upgrade cert-manager right after the Rancher upgrade", step.From, step.To))
+				after = &s
+				added = append(added, Warning{
+					Rule:    RuleCertManager,
+					Step:    len(result),
+					Message: pr.sprintf("Rancher %s runs with cert-manager %s, which it does not support, until cert-manager is upgraded to %s", step.To, installed, next),
+				})
+				installed = next
+			} else {
+				added = append(added, Warning{
+					Rule:    RuleCertManager,
+					Step:    len(result),
+					Message: pr.sprintf("Rancher %s requires cert-manager %s and no newer release in the compatibility data satisfies it", step.To, to),
+				})
+			}
+		}
+		moved[i] = len(result)
+		result = append(result, step)
+		if after != nil {
+			result = append(result, *after)
+		}
+	}
+
+	for i := range warnings {
+		if warnings[i].Step >= 0 {
+			warnings[i].Step = moved[warnings[i].Step]
+		}
+	}
+	return result, append(warnings, added...)
+}
+
+// certManagerReleases returns the cert-manager releases listed in the data,
+// sorted ascending; unparsable entries are skipped
+func certManagerReleases(paths UpgradePaths) []*version.Version {
+	var releases []*version.Version
+	for _, r := range paths.CertManagerReleases {
+		if v, err := version.NewVersion(cleanVersion(r)); err == nil {
+			releases = append(releases, v)
+		}
+	}
+	sort.Stable(version.Collection(releases))
+	return releases
+}
+
+// newestCertManager returns the newest release newer than the installed one
+// satisfying every given requirement
+func newestCertManager(releases []*version.Version, installed string, requirements ...string) (string, bool) {
+	cur, err := version.NewVersion(cleanVersion(installed))
+	if err != nil {
+		return "", false
+	}
+	for i := len(releases) - 1; i >= 0; i-- {
+		r := releases[i]
+		if !r.GreaterThan(cur) {
+			break
+		}
+		supported := true
+		for _, req := range requirements {
+			supported = supported && satisfies(r.Original(), req)
+		}
+		if supported {
+			return "v" + r.Original(), true
+		}
+	}
+	return "", false
+}
+
+// certManagerStep is the step upgrading cert-manager
+func certManagerStep(from, to, note string) UpgradeStep {
+	return UpgradeStep{Type: "cert-manager", From: from, To: to, Notes: []string{note}}
+}
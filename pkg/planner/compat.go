@@ -0,0 +1,80 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RancherCompatibility lists the Rancher versions supporting a Kubernetes
+// version on a platform
+type RancherCompatibility struct {
+	Platform string           `json:"platform"`
+	K8s      string           `json:"k8s"`
+	Rancher  []RancherSupport `json:"rancher_versions"` // Ascending, empty when none supports it
+}
+
+// RancherSupport is a Rancher version with the Kubernetes range it supports
+// on the platform, as written in the data
+type RancherSupport struct {
+	Version    string `json:"version"`
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+}
+
+// RancherFor returns the Rancher versions in the data whose range for the
+// platform covers the Kubernetes version, the reverse of the lookup plans
+// make. A platform no Rancher version lists is an *UnknownPlatformError.
+func (p *Planner) RancherFor(platform, k8s string) (*RancherCompatibility, error) {
+	k8sVer, err := parseInputVersion(k8s)
+	if err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid Kubernetes version: %v", err))
+	}
+	platform = canonicalPlatform(platform, p.aliases)
+	if err := checkPlatform(p.paths, platform, p.versions); err != nil {
+		return nil, err
+	}
+	if err := checkReleaseSuffix(platform, k8sVer); err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid Kubernetes version: %v", err))
+	}
+
+	compat := &RancherCompatibility{Platform: platform, K8s: strings.TrimSpace(k8s), Rancher: []RancherSupport{}}
+	for _, rv := range p.versions {
+		for _, sp := range p.paths.RancherManager[rv].SupportedPlatforms {
+			// The first parsable range of the platform counts, as in plans
+			minVer, maxVer, ok := platformRange(RancherManagerVersion{SupportedPlatforms: []Platform{sp}}, platform)
+			if !ok {
+				continue
+			}
+			if inRange(k8sVer, minVer, maxVer) {
+				compat.Rancher = append(compat.Rancher, RancherSupport{Version: rv, MinVersion: sp.MinVersion, MaxVersion: sp.MaxVersion})
+			}
+			break
+		}
+	}
+	return compat, nil
+}
+
+// RancherVersion returns the data of a Rancher version, matched like the
+// current version of a request, with the key it is listed under. An unknown
+// version is an *UnknownRancherVersionError.
+func (p *Planner) RancherVersion(v string) (string, RancherManagerVersion, error) {
+	if _, err := parseInputVersion(v); err != nil {
+		return "", RancherManagerVersion{}, classify(CodeInvalidVersion, fmt.Errorf("invalid Rancher version: %v", err))
+	}
+	key, err := findRancherVersion(p.versions, normalizeVersion(v))
+	if err != nil {
+		return "", RancherManagerVersion{}, err
+	}
+	return key, p.paths.RancherManager[key], nil
+}
+
+// Graph returns the compatibility graph of the platform over every Rancher
+// version in the data. A platform no Rancher version lists is an
+// *UnknownPlatformError.
+func (p *Planner) Graph(platform string) (*Graph, error) {
+	platform = canonicalPlatform(platform, p.aliases)
+	if err := checkPlatform(p.paths, platform, p.versions); err != nil {
+		return nil, err
+	}
+	return p.graph(platform), nil
+}
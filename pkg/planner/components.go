@@ -0,0 +1,34 @@
+package planner
+
+import "sort"
+
+// annotateComponents sets the embedded component versions of the target on
+// Rancher steps and notes every component whose version the step changes
+func annotateComponents(steps []UpgradeStep, paths UpgradePaths, pr printer) {
+	for i := range steps {
+		step := &steps[i]
+		if step.Type != "Rancher" {
+			continue
+		}
+		to := paths.RancherManager[step.To].Components
+		if len(to) == 0 {
+			continue
+		}
+		step.Components = to
+
+		from := paths.RancherManager[step.From].Components
+		names := make([]string, 0, len(to))
+		for name := range to {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch before, ok := from[name]; {
+			case !ok:
+				step.Notes = append(step.Notes, pr.sprintf("Embedded %s is %s", name, to[name]))
+			case before != to[name]:
+				step.Notes = append(step.Notes, pr.sprintf("Embedded %s changes from %s to %s", name, before, to[name]))
+			}
+		}
+	}
+}
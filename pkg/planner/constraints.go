@@ -0,0 +1,283 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Constraint actions
+const (
+	ActionWarn  = "warn"  // Add a warning to the plan
+	ActionBlock = "block" // Refuse to produce the plan
+)
+
+// Constraint is a declarative rule from the compatibility data that is
+// evaluated against every step of a plan. All conditions that are set must
+// match for the constraint to apply.
+type Constraint struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description,omitempty"`
+	When        Conditions `json:"when"`
+	// Requires lists cluster facts and the version constraint they must
+	// satisfy, e.g. {"docker": ">= 20.10"}. When set, the constraint only
+	// fires if a fact is missing or does not satisfy its requirement.
+	Requires map[string]string `json:"requires,omitempty"`
+	Action   string            `json:"action,omitempty"` // warn (default) or block
+	Message  string            `json:"message"`
+	// Messages holds translations of Message keyed by language, e.g. "de"
+	Messages map[string]string `json:"messages,omitempty"`
+}
+
+// Conditions select the steps a constraint applies to. Version ranges use
+// go-version constraint syntax such as ">= 2.7.0, < 2.8.0".
+type Conditions struct {
+	Platforms      []string `json:"platforms,omitempty"`
+	StepType       string   `json:"step_type,omitempty"`
+	FromRancher    string   `json:"from_rancher,omitempty"`
+	ToRancher      string   `json:"to_rancher,omitempty"`
+	FromK8s        string   `json:"from_k8s,omitempty"`
+	ToK8s          string   `json:"to_k8s,omitempty"`
+	CrossesRancher string   `json:"crosses_rancher,omitempty"` // Step moves from below to at or above this version
+	CrossesK8s     string   `json:"crosses_k8s,omitempty"`     // Step moves from below to at or above this version
+
+	// AuthProviders limits the constraint to requests declaring one of these
+	// Rancher auth providers, e.g. azuread or keycloakoidc
+	AuthProviders []string `json:"auth_providers,omitempty"`
+	// Features limits the constraint to requests declaring that they rely on
+	// one of these Rancher features, e.g. legacy-monitoring
+	Features []string `json:"features,omitempty"`
+}
+
+// Warning is a constraint that fired on a step of the plan, a note about the
+// plan as a whole attached to its last step, or a note about the request
+// rather than any step, such as a version the data does not list
+type Warning struct {
+	Rule    string `json:"rule"`
+	Step    int    `json:"step"` // Index into the plan steps, -1 for notes about the request and plans without steps
+	Message string `json:"message"`
+}
+
+// ConstraintError is returned when a blocking constraint matches a step
+type ConstraintError struct {
+	Rule    string
+	Step    UpgradeStep
+	Message string
+}
+
+// Error implements error
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s %s -> %s blocked by %s: %s", e.Step.Type, e.Step.From, e.Step.To, e.Rule, e.Message)
+}
+
+// Validate checks that the constraint's action is known and that its
+// version ranges, boundaries, and requirements parse, so a typo in the data
+// cannot silently disable the constraint
+func (c Constraint) Validate() error {
+	switch c.Action {
+	case "", ActionWarn, ActionBlock:
+	default:
+		return fmt.Errorf("constraint %q has unknown action %q, expected %s or %s", c.ID, c.Action, ActionWarn, ActionBlock)
+	}
+	ranges := []struct{ field, value string }{
+		{"from_rancher", c.When.FromRancher},
+		{"to_rancher", c.When.ToRancher},
+		{"from_k8s", c.When.FromK8s},
+		{"to_k8s", c.When.ToK8s},
+	}
+	for _, r := range ranges {
+		if r.value == "" {
+			continue
+		}
+		if _, err := version.NewConstraint(r.value); err != nil {
+			return fmt.Errorf("constraint %q has invalid %s %q: %v", c.ID, r.field, r.value, err)
+		}
+	}
+	boundaries := []struct{ field, value string }{
+		{"crosses_rancher", c.When.CrossesRancher},
+		{"crosses_k8s", c.When.CrossesK8s},
+	}
+	for _, b := range boundaries {
+		if b.value == "" {
+			continue
+		}
+		if _, err := version.NewVersion(cleanVersion(b.value)); err != nil {
+			return fmt.Errorf("constraint %q has invalid %s %q: %v", c.ID, b.field, b.value, err)
+		}
+	}
+	for fact, constraint := range c.Requires {
+		if _, err := version.NewConstraint(constraint); err != nil {
+			return fmt.Errorf("constraint %q has invalid requirement %q on %s: %v", c.ID, constraint, fact, err)
+		}
+	}
+	return nil
+}
+
+// stepState is the Rancher and Kubernetes version before and after a step
+type stepState struct {
+	fromRancher, toRancher string
+	fromK8s, toK8s         string
+}
+
+// evaluateConstraints checks every step against the constraints, returning
+// warnings for matching warn constraints and an error for the first
+// matching block constraint. Docker steps change the docker fact of the
+// steps after them.
+func evaluateConstraints(constraints []Constraint, platform, currentRancher, currentK8s string, steps []UpgradeStep, req Request, pr printer) ([]Warning, error) {
+	var warnings []Warning
+	rancher, k8s := currentRancher, currentK8s
+	facts := req.Facts
+
+	for i, step := range steps {
+		state := stepState{fromRancher: rancher, toRancher: rancher, fromK8s: k8s, toK8s: k8s}
+		switch step.Type {
+		case "Rancher":
+			state.toRancher = step.To
+		case "Kubernetes":
+			state.toK8s = step.To
+		case "Docker":
+			facts = withFact(facts, DockerFact, step.To)
+			continue
+		}
+
+		for _, c := range constraints {
+			if !c.When.matches(platform, step.Type, state, req) {
+				continue
+			}
+			unmet := c.unmetRequirements(facts, pr)
+			if len(c.Requires) > 0 && len(unmet) == 0 {
+				continue
+			}
+
+			message := c.message(pr.lang)
+			if len(unmet) > 0 {
+				message += " (" + strings.Join(unmet, "; ") + ")"
+			}
+			if c.Action == ActionBlock {
+				return nil, &ConstraintError{Rule: c.ID, Step: step, Message: message}
+			}
+			warnings = append(warnings, Warning{Rule: c.ID, Step: i, Message: message})
+		}
+
+		rancher, k8s = state.toRancher, state.toK8s
+	}
+
+	return warnings, nil
+}
+
+// matches reports whether the conditions hold for a step of the request
+func (w Conditions) matches(platform, stepType string, s stepState, req Request) bool {
+	if len(w.Platforms) > 0 && !containsFold(w.Platforms, platform) {
+		return false
+	}
+	if len(w.AuthProviders) > 0 && !containsFold(w.AuthProviders, strings.TrimSpace(req.AuthProvider)) {
+		return false
+	}
+	if len(w.Features) > 0 && !reliesOn(req.Features, w.Features) {
+		return false
+	}
+	if w.StepType != "" && !strings.EqualFold(w.StepType, stepType) {
+		return false
+	}
+	return satisfies(s.fromRancher, w.FromRancher) &&
+		satisfies(s.toRancher, w.ToRancher) &&
+		satisfies(s.fromK8s, w.FromK8s) &&
+		satisfies(s.toK8s, w.ToK8s) &&
+		crosses(s.fromRancher, s.toRancher, w.CrossesRancher) &&
+		crosses(s.fromK8s, s.toK8s, w.CrossesK8s)
+}
+
+// message returns the constraint's message in the language, falling back to
+// Message when the data has no translation
+func (c Constraint) message(lang string) string {
+	if m, ok := c.Messages[lang]; ok && m != "" {
+		return m
+	}
+	return c.Message
+}
+
+// unmetRequirements lists the requirements the facts do not satisfy
+func (c Constraint) unmetRequirements(facts map[string]string, pr printer) []string {
+	var unmet []string
+	for fact, constraint := range c.Requires {
+		value, ok := facts[fact]
+		switch {
+		case !ok || value == "":
+			unmet = append(unmet, pr.sprintf("%s %s required, %s version not provided", fact, constraint, fact))
+		case !satisfies(value, constraint):
+			unmet = append(unmet, pr.sprintf("%s %s required, found %s", fact, constraint, value))
+		}
+	}
+	sort.Strings(unmet)
+	return unmet
+}
+
+// withFact returns a copy of the facts with the fact set to the version
+func withFact(facts map[string]string, name, v string) map[string]string {
+	updated := make(map[string]string, len(facts)+1)
+	for n, fv := range facts {
+		updated[n] = fv
+	}
+	updated[name] = v
+	return updated
+}
+
+// satisfies reports whether the version meets the constraint; an empty
+// constraint always matches and unparsable input never does
+func satisfies(v, constraint string) bool {
+	if constraint == "" {
+		return true
+	}
+	c, err := version.NewConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	ver, err := version.NewVersion(cleanVersion(v))
+	if err != nil {
+		return false
+	}
+	return c.Check(ver)
+}
+
+// crosses reports whether moving from one version to another passes the
+// boundary; an empty boundary always matches
+func crosses(from, to, boundary string) bool {
+	if boundary == "" {
+		return true
+	}
+	b, err := version.NewVersion(cleanVersion(boundary))
+	if err != nil {
+		return false
+	}
+	f, err := version.NewVersion(cleanVersion(from))
+	if err != nil {
+		return false
+	}
+	t, err := version.NewVersion(cleanVersion(to))
+	if err != nil {
+		return false
+	}
+	return f.LessThan(b) && t.GreaterThanOrEqual(b)
+}
+
+// reliesOn reports whether any of the declared features is in the list
+func reliesOn(declared, features []string) bool {
+	for _, f := range declared {
+		if containsFold(features, strings.TrimSpace(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether the list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
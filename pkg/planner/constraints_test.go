@@ -0,0 +1,40 @@
+package planner_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// TestValidateConstraints checks that data typos in constraints fail loading
+// instead of silently disabling the constraint
+func TestValidateConstraints(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint planner.Constraint
+		err        string // Substring of the expected error, empty when valid
+	}{
+		{name: "default action", constraint: planner.Constraint{ID: "ok", When: planner.Conditions{ToK8s: ">= 1.24"}}},
+		{name: "warn", constraint: planner.Constraint{ID: "ok", Action: planner.ActionWarn}},
+		{name: "block", constraint: planner.Constraint{ID: "ok", Action: planner.ActionBlock, When: planner.Conditions{CrossesK8s: "v1.24"}}},
+		{name: "requirement", constraint: planner.Constraint{ID: "ok", Requires: map[string]string{"docker": ">= 20.10"}}},
+		{name: "unknown action", constraint: planner.Constraint{ID: "typo", Action: "blocks"}, err: `unknown action "blocks"`},
+		{name: "invalid range", constraint: planner.Constraint{ID: "typo", When: planner.Conditions{FromRancher: "=> 2.7"}}, err: "invalid from_rancher"},
+		{name: "invalid boundary", constraint: planner.Constraint{ID: "typo", When: planner.Conditions{CrossesRancher: "2.x"}}, err: "invalid crosses_rancher"},
+		{name: "invalid requirement", constraint: planner.Constraint{ID: "typo", Requires: map[string]string{"docker": "20.10+"}}, err: "invalid requirement"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := planner.UpgradePaths{Constraints: []planner.Constraint{tt.constraint}}.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expected an error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("error %q does not contain %q", err, tt.err)
+			}
+		})
+	}
+}
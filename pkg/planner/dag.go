@@ -0,0 +1,83 @@
+package planner
+
+import (
+	"strings"
+)
+
+// linkSteps gives every step an ID and lists the earlier steps it must wait
+// for, turning the ordered plan into a dependency graph. Steps of the same
+// type always run in order. A Kubernetes step waits for the last Rancher step
+// whose starting version does not support its target, and a Rancher step
+// waits for the last Kubernetes step starting from a version its target does
+// not support. OS and Docker steps wait for the steps before them and block
+// the Kubernetes steps after them, and cert-manager steps block the Rancher
+// steps after them. Everything else may run concurrently.
+func linkSteps(g *Graph, steps []UpgradeStep) {
+	for i := range steps {
+		step := &steps[i]
+		step.ID = stepID(*step)
+		step.DependsOn = nil
+
+		prevSameType, prevOther := -1, -1
+		for j := i - 1; j >= 0; j-- {
+			earlier := steps[j]
+			if earlier.Type == step.Type {
+				if prevSameType < 0 {
+					prevSameType = j
+				}
+				continue
+			}
+			if prevOther < 0 && blocks(g, earlier, *step) {
+				prevOther = j
+			}
+		}
+
+		deps := []int{prevOther, prevSameType}
+		if prevOther > prevSameType {
+			deps[0], deps[1] = prevSameType, prevOther
+		}
+		for _, j := range deps {
+			if j >= 0 {
+				step.DependsOn = append(step.DependsOn, steps[j].ID)
+			}
+		}
+	}
+}
+
+// blocks reports whether the later step of the other type cannot start before
+// the earlier one completes
+func blocks(g *Graph, earlier, later UpgradeStep) bool {
+	switch later.Type {
+	case "Kubernetes":
+		if earlier.Type == "cert-manager" {
+			return false
+		}
+		return earlier.Type != "Rancher" || !supportsVersion(g, earlier.From, later.To)
+	case "Rancher":
+		if earlier.Type == "OS" || earlier.Type == "Docker" {
+			return false
+		}
+		return earlier.Type != "Kubernetes" || !supportsVersion(g, later.To, earlier.From)
+	}
+	return true
+}
+
+// supportsVersion reports whether the Rancher version supports the Kubernetes
+// version on the graph's platform; unparsable versions are never supported
+func supportsVersion(g *Graph, rancher, k8s string) bool {
+	v, err := parseK8sVersion(k8s)
+	if err != nil {
+		return false
+	}
+	return g.Supports(rancher, v)
+}
+
+// stepID identifies a step by its type and target, which are unique within a
+// plan since no version is upgraded to twice
+func stepID(step UpgradeStep) string {
+	prefix := strings.ToLower(step.Type)
+	if step.Type == "Kubernetes" {
+		prefix = "k8s"
+	}
+	return prefix + "-" + step.To
+}
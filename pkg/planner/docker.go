@@ -0,0 +1,113 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// DockerFact is the fact holding the Docker version of the nodes, checked
+// against the requirements of constraints
+const DockerFact = "docker"
+
+// RuleDockerUnsupported identifies the warnings added when no Docker release
+// in the data supports a Kubernetes version of an RKE1 plan
+const RuleDockerUnsupported = "docker-unsupported"
+
+// DockerRelease is a Docker release line RKE1 nodes can run and the
+// Kubernetes versions RKE1 supports on it
+type DockerRelease struct {
+	Version string `json:"version"` // Release upgraded to, e.g. 20.10.24
+	// Kubernetes is the range of supported Kubernetes versions in go-version
+	// constraint syntax, e.g. ">= 1.19.0"
+	Kubernetes string `json:"kubernetes"`
+}
+
+// dockerPlatform is the only platform whose nodes run Docker
+const dockerPlatform = "rke1"
+
+// dockerReleases returns the Docker releases listed in the data, sorted
+// ascending; unparsable entries are skipped
+func dockerReleases(paths UpgradePaths) []DockerRelease {
+	type parsed struct {
+		release DockerRelease
+		version *version.Version
+	}
+	var list []parsed
+	for _, r := range paths.DockerReleases {
+		if v, err := version.NewVersion(r.Version); err == nil {
+			list = append(list, parsed{r, v})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].version.LessThan(list[j].version) })
+
+	releases := make([]DockerRelease, len(list))
+	for i, p := range list {
+		releases[i] = p.release
+	}
+	return releases
+}
+
+// interleaveDocker inserts a Docker step before every Kubernetes step of an
+// RKE1 plan whose target the installed Docker release line does not
+// support, upgrading to the oldest release that supports both the running
+// and the target Kubernetes version. When no release supports both, the
+// Docker step follows the Kubernetes step and a warning points out the gap.
+func interleaveDocker(paths UpgradePaths, platform string, steps []UpgradeStep, warnings []Warning, installed string, pr printer) ([]UpgradeStep, []Warning) {
+	releases := dockerReleases(paths)
+	if platform != dockerPlatform || installed == "" || len(releases) == 0 {
+		return steps, warnings
+	}
+	current, ok := findDockerRelease(releases, installed)
+	if !ok {
+		warnings = append(warnings, Warning{
+			Rule:    RuleDockerUnsupported,
+			Step:    -1,
+			Message: pr.sprintf("Docker %s is not in the compatibility data, so Docker upgrades are not planned", installed),
+		})
+		return steps, warnings
+	}
+
+	sw := nodeSoftware{
+		name: "Docker",
+		rule: RuleDockerUnsupported,
+		step: func(from, to, note string) UpgradeStep {
+			return UpgradeStep{Type: "Docker", From: from, To: to, Notes: []string{note}}
+		},
+		before: func(running, k8s string) string {
+			return pr.sprintf("Docker %s does not support Kubernetes %s; upgrade Docker on every node before this Kubernetes upgrade", running, k8s)
+		},
+	}
+	for _, r := range releases {
+		sw.releases = append(sw.releases, nodeRelease(r))
+	}
+	return interleaveNodeSteps(steps, warnings, sw, nodeRelease(current), installed, pr)
+}
+
+// findDockerRelease returns the release of the installed version's release
+// line, e.g. 20.10.24 for 20.10.21
+func findDockerRelease(releases []DockerRelease, installed string) (DockerRelease, bool) {
+	want, err := version.NewVersion(installed)
+	if err != nil {
+		return DockerRelease{}, false
+	}
+	for _, r := range releases {
+		if rv, err := version.NewVersion(r.Version); err == nil && sameMinor(rv, want) {
+			return r, true
+		}
+	}
+	return DockerRelease{}, false
+}
+
+// checkDockerVersion rejects a Docker version that does not parse
+func checkDockerVersion(v string) error {
+	if v == "" {
+		return nil
+	}
+	if _, err := version.NewVersion(strings.TrimSpace(v)); err != nil {
+		return classify(CodeInvalidVersion, fmt.Errorf("invalid Docker version %q: %v", v, err))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package planner
+
+import "math"
+
+// EffortModel estimates the engineer-hours of a plan from per-step weights.
+// The zero value disables estimates.
+type EffortModel struct {
+	RancherHop float64 `json:"rancher_hop,omitempty"` // Hours per Rancher upgrade step
+	// K8sHop is the hours per Kubernetes upgrade step for every NodesPerUnit
+	// nodes of the cluster, rounded up; clusters of unknown size count as one unit
+	K8sHop       float64 `json:"k8s_hop,omitempty"`
+	NodesPerUnit int     `json:"nodes_per_unit,omitempty"` // Defaults to 10
+	Migration    float64 `json:"migration,omitempty"`      // Hours per migration step
+}
+
+// enabled reports whether the model has any weight set
+func (m EffortModel) enabled() bool {
+	return m.RancherHop > 0 || m.K8sHop > 0 || m.Migration > 0
+}
+
+// Effort is the estimated engineer-hours of a plan
+type Effort struct {
+	Hours      float64 `json:"hours"` // Total of the steps below
+	Rancher    float64 `json:"rancher_hours"`
+	Kubernetes float64 `json:"kubernetes_hours"`
+	Migration  float64 `json:"migration_hours,omitempty"`
+}
+
+// estimate returns the effort of the steps for a cluster of the given size,
+// or nil when the model is disabled
+func (m EffortModel) estimate(steps []UpgradeStep, nodes int) *Effort {
+	if !m.enabled() {
+		return nil
+	}
+	perUnit := m.NodesPerUnit
+	if perUnit <= 0 {
+		perUnit = 10
+	}
+	units := 1.0
+	if nodes > 0 {
+		units = math.Ceil(float64(nodes) / float64(perUnit))
+	}
+
+	e := &Effort{}
+	for _, step := range steps {
+		switch step.Type {
+		case "Rancher":
+			e.Rancher += m.RancherHop
+		case "Kubernetes":
+			e.Kubernetes += m.K8sHop * units
+		case "Migration":
+			e.Migration += m.Migration
+		}
+	}
+	e.Hours = e.Rancher + e.Kubernetes + e.Migration
+	return e
+}
@@ -0,0 +1,73 @@
+package planner
+
+import "github.com/hashicorp/go-version"
+
+// RuleEndOfLife identifies the warning added when a plan stops early because
+// later Rancher versions no longer support the platform
+const RuleEndOfLife = "platform-end-of-life"
+
+// endOfLifeGuidance is appended to the end-of-life warning of platforms with a
+// known migration path
+var endOfLifeGuidance = map[string]string{
+	"rke1": "RKE1 is end of life; migrate the cluster to RKE2 to continue upgrading Rancher",
+}
+
+// supportedCheckpoints returns the checkpoints newer than the current version
+// up to the first one that drops the platform. When a checkpoint drops it, the
+// newest candidate version that still supports the platform becomes the last
+// checkpoint and the returned warning explains why the plan stops there.
+func supportedCheckpoints(paths UpgradePaths, platform, current string, checkpoints, candidates []string, pr printer) ([]string, *Warning) {
+	cur, err := version.NewVersion(current)
+	if err != nil {
+		return checkpoints, nil
+	}
+
+	var kept []string
+	dropped := ""
+	for _, c := range checkpoints {
+		v, err := version.NewVersion(c)
+		if err != nil || !v.GreaterThan(cur) {
+			continue
+		}
+		if !listsPlatform(paths.RancherManager[c], platform) {
+			dropped = c
+			break
+		}
+		kept = append(kept, c)
+	}
+	if dropped == "" {
+		return kept, nil
+	}
+
+	// Stop at the newest version before the cut-off that still supports the platform
+	last := current
+	if len(kept) > 0 {
+		last = kept[len(kept)-1]
+	}
+	lastVer, _ := version.NewVersion(last)
+	droppedVer, _ := version.NewVersion(dropped)
+	for _, c := range candidates {
+		v, err := version.NewVersion(c)
+		if err != nil || !v.GreaterThan(lastVer) || !v.LessThan(droppedVer) {
+			continue
+		}
+		if listsPlatform(paths.RancherManager[c], platform) {
+			last = c
+		}
+	}
+	if last != current && (len(kept) == 0 || kept[len(kept)-1] != last) {
+		kept = append(kept, last)
+	}
+
+	message := pr.sprintf("Rancher %s and later do not support %s, so the plan stops at Rancher %s", dropped, platform, last)
+	if guidance, ok := endOfLifeGuidance[platform]; ok {
+		message = pr.sprintf("%s. %s", message, pr.text(guidance))
+	}
+	return kept, &Warning{Rule: RuleEndOfLife, Step: -1, Message: message}
+}
+
+// listsPlatform reports whether the Rancher version has a range for the platform
+func listsPlatform(r RancherManagerVersion, platform string) bool {
+	_, _, ok := platformRange(r, platform)
+	return ok
+}
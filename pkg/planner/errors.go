@@ -0,0 +1,263 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Codes classifying the errors planning fails with, see ErrorCode
+const (
+	// CodeInvalidRequest is a request field with an invalid or unknown value
+	CodeInvalidRequest = "invalid_request"
+	// CodeInvalidVersion is a version that cannot be parsed or is not in the
+	// compatibility data
+	CodeInvalidVersion = "invalid_version"
+	// CodeUnknownPlatform is a platform the compatibility data does not list
+	CodeUnknownPlatform = "unknown_platform"
+	// CodeUnsupportedCombination is a valid request for versions, platforms,
+	// or policies that do not go together, such as a target that does not
+	// support the platform
+	CodeUnsupportedCombination = "unsupported_combination"
+	// CodeNoPathFound is a valid request no plan satisfies, such as one
+	// blocked by a constraint
+	CodeNoPathFound = "no_path_found"
+	// CodeBlackout is a planned date within a blackout period
+	CodeBlackout = "blackout"
+)
+
+// RequestError is a planning error classified by one of the error codes
+type RequestError struct {
+	Code string
+	Err  error
+}
+
+// Error implements error
+func (e *RequestError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the classified error
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// classify wraps the error with the code
+func classify(code string, err error) error {
+	return &RequestError{Code: code, Err: err}
+}
+
+// ErrorCode returns the code classifying an error returned by Plan, or ""
+// for errors the request did not cause, such as cancellation
+func ErrorCode(err error) string {
+	var (
+		classified  *RequestError
+		platform    *UnknownPlatformError
+		unknown     *UnknownRancherVersionError
+		ahead       *VersionAheadError
+		hop         *IncompatibleHopError
+		unsupported *UnsupportedStateError
+		constraint  *ConstraintError
+		blackout    *BlackoutError
+	)
+	switch {
+	case errors.As(err, &classified):
+		return classified.Code
+	case errors.As(err, &platform):
+		return CodeUnknownPlatform
+	case errors.As(err, &unknown), errors.As(err, &ahead):
+		return CodeInvalidVersion
+	case errors.As(err, &hop), errors.As(err, &unsupported), errors.As(err, &constraint):
+		return CodeNoPathFound
+	case errors.As(err, &blackout):
+		return CodeBlackout
+	}
+	return ""
+}
+
+// UnknownRancherVersionError is returned when the current Rancher version is
+// not in the compatibility data
+type UnknownRancherVersionError struct {
+	Version    string
+	Suggestion string // Closest known version, empty when there is none
+}
+
+// Error implements error
+func (e *UnknownRancherVersionError) Error() string {
+	msg := fmt.Sprintf("Rancher version %s is not in the compatibility data", e.Version)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %s?", e.Suggestion)
+	}
+	return msg
+}
+
+// findRancherVersion returns the version from the sorted list that equals v,
+// or an *UnknownRancherVersionError suggesting the closest known version
+func findRancherVersion(versions []string, v string) (string, error) {
+	target, err := version.NewVersion(v)
+	if err != nil {
+		return "", classify(CodeInvalidVersion, fmt.Errorf("invalid current Rancher version: %v", err))
+	}
+
+	var below, above string
+	for _, known := range versions {
+		kv, err := version.NewVersion(known)
+		if err != nil {
+			continue
+		}
+		switch {
+		case kv.Equal(target):
+			return known, nil
+		case kv.LessThan(target):
+			if sameMinor(kv, target) {
+				below = known
+			}
+		case above == "" && sameMinor(kv, target):
+			above = known
+		}
+	}
+
+	if newest := latestVersion(versions); newest != nil && target.GreaterThan(newest) {
+		return "", &VersionAheadError{Component: "Rancher", Version: v, Newest: newest.Original()}
+	}
+
+	// Prefer the newest release of the same minor that is older than v
+	suggestion := below
+	if suggestion == "" {
+		suggestion = above
+	}
+	return "", &UnknownRancherVersionError{Version: v, Suggestion: suggestion}
+}
+
+// latestVersion returns the newest parsable version of the sorted list
+func latestVersion(versions []string) *version.Version {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v, err := version.NewVersion(versions[i]); err == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// sameMinor reports whether both versions share their major and minor
+func sameMinor(a, b *version.Version) bool {
+	as, bs := a.Segments(), b.Segments()
+	return as[0] == bs[0] && as[1] == bs[1]
+}
+
+// VersionAheadError is returned when a current version is newer than anything
+// in the compatibility data, which usually means the data is out of date
+type VersionAheadError struct {
+	Component string // Rancher or Kubernetes
+	Version   string
+	Newest    string // Newest version of the component in the data
+}
+
+// Error implements error
+func (e *VersionAheadError) Error() string {
+	return fmt.Sprintf("%s %s is newer than the newest version in the compatibility data (%s)", e.Component, e.Version, e.Newest)
+}
+
+// checkK8sAhead returns a *VersionAheadError when the Kubernetes version is
+// above every range the data lists for the platform
+func checkK8sAhead(g *Graph, k8s *version.Version) error {
+	newest := g.Newest()
+	if newest == nil || !k8s.GreaterThan(newest) || sameMinorBound(k8s, newest) {
+		return nil
+	}
+	return &VersionAheadError{Component: "Kubernetes", Version: "v" + k8s.Original(), Newest: "v" + newest.Original()}
+}
+
+// UnknownPlatformError is returned when no Rancher version the plan passes
+// through lists Kubernetes ranges for the platform
+type UnknownPlatformError struct {
+	Platform string
+	Known    []string // Platforms the data lists for those Rancher versions, sorted
+}
+
+// Error implements error
+func (e *UnknownPlatformError) Error() string {
+	return fmt.Sprintf("platform %q is not in the compatibility data, expected one of: %s", e.Platform, strings.Join(e.Known, ", "))
+}
+
+// checkPlatform returns an *UnknownPlatformError unless at least one of the
+// Rancher versions lists ranges for the platform
+func checkPlatform(paths UpgradePaths, platform string, rancherVersions []string) error {
+	known := make(map[string]bool)
+	for _, rv := range rancherVersions {
+		for _, p := range paths.RancherManager[rv].SupportedPlatforms {
+			if strings.EqualFold(p.Platform, platform) {
+				return nil
+			}
+			known[strings.ToLower(p.Platform)] = true
+		}
+	}
+
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &UnknownPlatformError{Platform: platform, Known: names}
+}
+
+// relevantVersions returns the current Rancher version followed by every
+// newer checkpoint
+func relevantVersions(current string, checkpoints []string) []string {
+	versions := []string{current}
+	cur, err := version.NewVersion(current)
+	if err != nil {
+		return versions
+	}
+	for _, c := range checkpoints {
+		if v, err := version.NewVersion(c); err == nil && v.GreaterThan(cur) {
+			versions = append(versions, c)
+		}
+	}
+	return versions
+}
+
+// IncompatibleHopError is returned when a plan would upgrade Rancher to a
+// version that does not support the cluster's Kubernetes version at that point
+type IncompatibleHopError struct {
+	Platform string
+	Step     UpgradeStep // The Rancher step
+	K8s      string      // Kubernetes version when the step runs
+	Min, Max string      // Kubernetes range the target Rancher version supports
+}
+
+// Error implements error
+func (e *IncompatibleHopError) Error() string {
+	if e.Min == "" {
+		return fmt.Sprintf("Rancher %s -> %s: Rancher %s does not support %s", e.Step.From, e.Step.To, e.Step.To, e.Platform)
+	}
+	return fmt.Sprintf("Rancher %s -> %s: Rancher %s does not support Kubernetes %s on %s (supported %s to %s)",
+		e.Step.From, e.Step.To, e.Step.To, e.K8s, e.Platform, e.Min, e.Max)
+}
+
+// checkHops verifies that every Rancher step targets a version supporting the
+// Kubernetes version the cluster runs when the step is executed
+func checkHops(g *Graph, currentK8s string, steps []UpgradeStep) error {
+	k8s := currentK8s
+	for _, step := range steps {
+		if step.Type == "Kubernetes" {
+			k8s = step.To
+			continue
+		}
+		if step.Type != "Rancher" {
+			continue
+		}
+		v, err := parseK8sVersion(k8s)
+		if err != nil {
+			return fmt.Errorf("invalid Kubernetes version %s in plan: %v", k8s, err)
+		}
+		if g.Supports(step.To, v) {
+			continue
+		}
+		hopErr := &IncompatibleHopError{Platform: g.Platform, Step: step, K8s: k8s}
+		if minVer, maxVer, ok := g.Range(step.To); ok {
+			hopErr.Min, hopErr.Max = "v"+minVer.Original(), "v"+maxVer.Original()
+		}
+		return hopErr
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package planner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// errorPaths is a minimal dataset: two Rancher versions supporting RKE2, and
+// one RKE2 release
+var errorPaths = planner.UpgradePaths{
+	RancherManager: map[string]planner.RancherManagerVersion{
+		"2.7.10": {SupportedPlatforms: []planner.Platform{{Platform: "rke2", MinVersion: "1.23", MaxVersion: "1.27"}}},
+		"2.8.5":  {SupportedPlatforms: []planner.Platform{{Platform: "rke2", MinVersion: "1.25", MaxVersion: "1.28"}}},
+	},
+	Releases: map[string][]string{"rke2": {"v1.27.16+rke2r1"}},
+}
+
+// TestErrorCode checks the code of every typed planning error and of the
+// errors of the lookups that share the plan API's error responses
+func TestErrorCode(t *testing.T) {
+	p := planner.New(errorPaths, planner.Options{})
+	versions := []string{"2.7.10", "2.8.5"}
+
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{name: "classified", err: &planner.RequestError{Code: planner.CodeBlackout, Err: errors.New("blackout")}, code: planner.CodeBlackout},
+		{name: "wrapped classified", err: fmt.Errorf("batch: %w", &planner.RequestError{Code: planner.CodeInvalidRequest, Err: errors.New("bad")}), code: planner.CodeInvalidRequest},
+		{name: "unknown platform", err: &planner.UnknownPlatformError{Platform: "rke3"}, code: planner.CodeUnknownPlatform},
+		{name: "unknown Rancher version", err: &planner.UnknownRancherVersionError{Version: "2.7.99"}, code: planner.CodeInvalidVersion},
+		{name: "version ahead", err: &planner.VersionAheadError{Component: "Rancher", Version: "3.0.0"}, code: planner.CodeInvalidVersion},
+		{name: "incompatible hop", err: &planner.IncompatibleHopError{Platform: "rke2"}, code: planner.CodeNoPathFound},
+		{name: "unsupported state", err: &planner.UnsupportedStateError{}, code: planner.CodeNoPathFound},
+		{name: "constraint", err: &planner.ConstraintError{}, code: planner.CodeNoPathFound},
+		{name: "blackout", err: &planner.BlackoutError{}, code: planner.CodeBlackout},
+		{name: "canceled", err: context.Canceled, code: ""},
+		{name: "unclassified", err: errors.New("data error"), code: ""},
+
+		{name: "remediation invalid Kubernetes", err: func() error { _, err := p.PatchRemediation("rke2", "2.7.10", "1.x"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "remediation invalid Rancher", err: func() error { _, err := p.PatchRemediation("rke2", "two", "v1.27.1"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "remediation unknown Rancher", err: func() error { _, err := p.PatchRemediation("rke2", "2.7.9", "v1.27.1"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "remediation wrong suffix", err: func() error { _, err := p.PatchRemediation("rke2", "2.7.10", "v1.27.1+k3s1"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "remediation unsupported platform", err: func() error { _, err := p.PatchRemediation("k3s", "2.7.10", "v1.27.1"); return err }(), code: planner.CodeUnsupportedCombination},
+		{name: "remediation out of range", err: func() error { _, err := p.PatchRemediation("rke2", "2.7.10", "v1.28.1"); return err }(), code: planner.CodeUnsupportedCombination},
+		{name: "rancher-for invalid Kubernetes", err: func() error { _, err := p.RancherFor("rke2", "latest-ish"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "rancher-for unknown platform", err: func() error { _, err := p.RancherFor("rke3", "v1.27.1"); return err }(), code: planner.CodeUnknownPlatform},
+		{name: "rancher version invalid", err: func() error { _, _, err := p.RancherVersion("two"); return err }(), code: planner.CodeInvalidVersion},
+		{name: "legacy invalid Kubernetes", err: func() error { _, err := planner.PlanUpgrade("2.7.10", "1.x", "rke2", versions, errorPaths); return err }(), code: planner.CodeInvalidVersion},
+		{name: "legacy unknown Rancher", err: func() error {
+			_, err := planner.PlanUpgrade("2.7.9", "v1.27.1", "rke2", versions, errorPaths)
+			return err
+		}(), code: planner.CodeInvalidVersion},
+		{name: "legacy unknown platform", err: func() error {
+			_, err := planner.PlanUpgrade("2.7.10", "v1.27.1", "rke3", versions, errorPaths)
+			return err
+		}(), code: planner.CodeUnknownPlatform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("expected an error")
+			}
+			if code := planner.ErrorCode(tt.err); code != tt.code {
+				t.Fatalf("ErrorCode(%q) = %q, expected %q", tt.err, code, tt.code)
+			}
+		})
+	}
+}
+
+// TestRemediationWithoutReleases checks that a platform without releases to
+// recommend from is a no_path_found error
+func TestRemediationWithoutReleases(t *testing.T) {
+	paths := errorPaths
+	paths.Releases = nil
+	_, err := planner.New(paths, planner.Options{}).PatchRemediation("rke2", "2.7.10", "v1.27.1")
+	if code := planner.ErrorCode(err); code != planner.CodeNoPathFound {
+		t.Fatalf("ErrorCode(%v) = %q, expected %q", err, code, planner.CodeNoPathFound)
+	}
+}
@@ -0,0 +1,112 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Rules of the warnings added for the UI extensions of a request
+const (
+	RuleExtensionIncompatible = "ui-extension-incompatible"
+	RuleExtensionUnknown      = "ui-extension-api-unknown"
+)
+
+// extensionTermSeparator matches the spaces between the terms of a
+// space-separated range such as ">= 1.1.0 < 3.0.0"
+var extensionTermSeparator = regexp.MustCompile(`([0-9A-Za-z])\s+([<>=!~])`)
+
+// extensionConstraint parses the range of extensions API versions an extension
+// supports. Extensions declare it in their catalog.cattle.io/ui-extensions-version
+// annotation, with or without commas between the terms.
+func extensionConstraint(s string) (version.Constraints, error) {
+	return version.NewConstraint(extensionTermSeparator.ReplaceAllString(strings.TrimSpace(s), "$1, $2"))
+}
+
+// checkExtensions rejects extensions whose supported range cannot be parsed
+func checkExtensions(extensions map[string]string) error {
+	for _, name := range extensionNames(extensions) {
+		if _, err := extensionConstraint(extensions[name]); err != nil {
+			return classify(CodeInvalidRequest, fmt.Errorf("invalid extensions API range %q for UI extension %s: %v", extensions[name], name, err))
+		}
+	}
+	return nil
+}
+
+// extensionNames returns the names of the extensions, sorted
+func extensionNames(extensions map[string]string) []string {
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extensionWarnings warns on the first Rancher step whose extensions API no
+// longer supports each extension. Rancher steps to versions whose extensions
+// API the data does not record share a single warning, on the first of them,
+// listing the versions and the extensions still unchecked at them.
+func extensionWarnings(paths UpgradePaths, steps []UpgradeStep, extensions map[string]string, pr printer) []Warning {
+	if len(extensions) == 0 {
+		return nil
+	}
+	names := extensionNames(extensions)
+
+	var warnings []Warning
+	broken := make(map[string]bool)
+	unknownStep := -1
+	var unknownVersions []string
+	unchecked := make(map[string]string)
+	for i, step := range steps {
+		if step.Type != "Rancher" {
+			continue
+		}
+		api := paths.RancherManager[step.To].UIExtensionsAPI
+		apiVer, err := version.NewVersion(cleanVersion(api))
+		if api == "" || err != nil {
+			checked := true
+			for _, name := range names {
+				if !broken[name] {
+					unchecked[name] = extensions[name]
+					checked = false
+				}
+			}
+			if !checked {
+				if unknownStep < 0 {
+					unknownStep = i
+				}
+				unknownVersions = append(unknownVersions, step.To)
+			}
+			continue
+		}
+		for _, name := range names {
+			if broken[name] {
+				continue
+			}
+			c, _ := extensionConstraint(extensions[name])
+			if !c.Check(apiVer) {
+				broken[name] = true
+				warnings = append(warnings, Warning{
+					Rule: RuleExtensionIncompatible,
+					Step: i,
+					Message: pr.sprintf("UI extension %s supports extensions API %s, but Rancher %s provides %s; upgrade or remove the extension before this step",
+						name, extensions[name], step.To, api),
+				})
+			}
+		}
+	}
+
+	if unknownStep >= 0 {
+		warnings = append(warnings, Warning{
+			Rule: RuleExtensionUnknown,
+			Step: unknownStep,
+			Message: pr.sprintf("The compatibility data does not record the UI extensions API of Rancher %s; check these UI extensions against it before upgrading: %s",
+				strings.Join(unknownVersions, ", "), strings.Join(extensionNames(unchecked), ", ")),
+		})
+	}
+	return warnings
+}
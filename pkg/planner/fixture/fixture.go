@@ -0,0 +1,210 @@
+// Package fixture loads and runs golden planner fixtures. A fixture is a JSON
+// file holding a compatibility dataset, a planning request, and the plan (or
+// error) the request is expected to produce, so that data and code changes
+// can be checked against previously verified plans.
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// Fixture is a single golden planner test case
+type Fixture struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Dataset is the compatibility data to plan against. DatasetFile may be
+	// used instead to reference a data file relative to the fixture.
+	Dataset     *planner.UpgradePaths `json:"dataset,omitempty"`
+	DatasetFile string                `json:"dataset_file,omitempty"`
+
+	// Policy and Blackouts are the organization policy and blackout periods
+	// to plan with, if any
+	Policy    *planner.Policy    `json:"policy,omitempty"`
+	Blackouts []planner.Blackout `json:"blackouts,omitempty"`
+
+	Request       planner.Request `json:"request"`
+	Expected      *planner.Plan   `json:"expected,omitempty"`
+	ExpectedError string          `json:"expected_error,omitempty"`
+
+	path string
+}
+
+// Result is the outcome of running a fixture
+type Result struct {
+	Fixture *Fixture
+	Plan    *planner.Plan
+	Err     error
+	Diff    string // Empty when the fixture passed
+}
+
+// Passed reports whether the fixture produced the expected plan or error
+func (r Result) Passed() bool {
+	return r.Diff == ""
+}
+
+// Load reads every *.json fixture in dir, sorted by file name
+func Load(dir string) ([]*Fixture, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	fixtures := make([]*Fixture, 0, len(files))
+	for _, file := range files {
+		f, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// LoadFile reads a single fixture
+func LoadFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %v", path, err)
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %v", path, err)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	f.path = path
+	return &f, nil
+}
+
+// Path returns the file the fixture was loaded from
+func (f *Fixture) Path() string {
+	return f.path
+}
+
+// LoadDataset returns the fixture's own dataset, inline or from DatasetFile
+func (f *Fixture) LoadDataset() (planner.UpgradePaths, error) {
+	paths, err := f.readDataset()
+	if err != nil {
+		return planner.UpgradePaths{}, err
+	}
+	if err := paths.Validate(); err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("invalid dataset for fixture %s: %v", f.Name, err)
+	}
+	return paths, nil
+}
+
+// readDataset reads the fixture's own dataset without validating it
+func (f *Fixture) readDataset() (planner.UpgradePaths, error) {
+	if f.Dataset != nil {
+		return *f.Dataset, nil
+	}
+	if f.DatasetFile == "" {
+		return planner.UpgradePaths{}, fmt.Errorf("fixture %s has no dataset", f.Name)
+	}
+
+	path := f.DatasetFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(f.path), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to read dataset for fixture %s: %v", f.Name, err)
+	}
+	var paths planner.UpgradePaths
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return planner.UpgradePaths{}, fmt.Errorf("failed to parse dataset for fixture %s: %v", f.Name, err)
+	}
+	return paths, nil
+}
+
+// Run plans the fixture's request against its own dataset
+func (f *Fixture) Run() Result {
+	paths, err := f.LoadDataset()
+	if err != nil {
+		return Result{Fixture: f, Err: err, Diff: err.Error()}
+	}
+	return f.RunWith(paths)
+}
+
+// RunWith plans the fixture's request against the given dataset instead of
+// its own, e.g. to check previously verified plans against live data
+func (f *Fixture) RunWith(paths planner.UpgradePaths) Result {
+	plan, err := planner.New(paths, planner.Options{Policy: f.Policy, Blackouts: f.Blackouts}).Plan(f.Request)
+	r := Result{Fixture: f, Plan: plan, Err: err}
+
+	switch {
+	case err != nil && f.ExpectedError == "":
+		r.Diff = fmt.Sprintf("unexpected error: %v", err)
+	case err != nil && err.Error() != f.ExpectedError:
+		r.Diff = fmt.Sprintf("error mismatch:\n- %s\n+ %s", f.ExpectedError, err.Error())
+	case err == nil && f.ExpectedError != "":
+		r.Diff = fmt.Sprintf("expected error %q, got a plan", f.ExpectedError)
+	case err == nil:
+		r.Diff = Diff(render(f.Expected), render(withoutMeta(plan)))
+	}
+	return r
+}
+
+// withoutMeta returns the plan without its metadata, which changes with every
+// run and every data edit and is therefore not part of the expectation
+func withoutMeta(plan *planner.Plan) *planner.Plan {
+	if plan == nil || plan.Meta == nil {
+		return plan
+	}
+	stripped := *plan
+	stripped.Meta = nil
+	return &stripped
+}
+
+// Update stores the result as the fixture's expectation and rewrites the file
+func (f *Fixture) Update(r Result) error {
+	f.Expected, f.ExpectedError = nil, ""
+	if r.Err != nil {
+		f.ExpectedError = r.Err.Error()
+	} else {
+		f.Expected = withoutMeta(r.Plan)
+	}
+
+	data, err := json.MarshalIndent(f, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, append(data, '\n'), 0o644)
+}
+
+// render formats a plan as indented JSON for comparison
+func render(plan *planner.Plan) string {
+	if plan == nil {
+		return ""
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// Compare plans the fixture's request against two datasets and returns a line
+// diff of the plans or errors they produce, empty when both are the same
+func (f *Fixture) Compare(before, after planner.UpgradePaths) string {
+	return Diff(outcome(f.RunWith(before)), outcome(f.RunWith(after)))
+}
+
+// outcome renders the plan of a result, or its error
+func outcome(r Result) string {
+	if r.Err != nil {
+		return "error: " + r.Err.Error()
+	}
+	return render(withoutMeta(r.Plan))
+}
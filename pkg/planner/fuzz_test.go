@@ -0,0 +1,62 @@
+package planner
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// fuzzSeeds are inputs seen in real requests and known edge cases
+var fuzzSeeds = []string{
+	"v1.24.9", "1.24", "v1.26.8+rke2r1", "v1.27.6+k3s1", "v1.20.8-gke.900",
+	"v1.21.5-eks-bc4871b", "2.7.5", "v2.8.0-rc3", "1", "v", "", ".", "1..2",
+	"v1.99999999999999999999.0", "1.2.3.4.5.6", "１.２４", "v1.24.9\x00", "-1.2",
+}
+
+// FuzzParseK8sVersion checks that arbitrary version strings never panic
+func FuzzParseK8sVersion(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		v, err := parseK8sVersion(s)
+		if err != nil {
+			return
+		}
+		if len(v.Segments()) == 0 {
+			t.Fatalf("parsed %q without segments", s)
+		}
+	})
+}
+
+// FuzzPlan checks that arbitrary request input never panics or hangs the
+// planner, using the compatibility data shipped with the service
+func FuzzPlan(f *testing.F) {
+	data, err := os.ReadFile("../../data/upgrade-paths.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	var paths UpgradePaths
+	if err := json.Unmarshal(data, &paths); err != nil {
+		f.Fatal(err)
+	}
+	p := New(paths, Options{})
+
+	for _, platform := range []string{"rke1", "RKE2", "k3s", "eks", "harvester", ""} {
+		for _, s := range fuzzSeeds {
+			f.Add(platform, "2.7.5", s, "")
+			f.Add(platform, s, "v1.24.9", "shortest-path")
+		}
+	}
+	f.Fuzz(func(t *testing.T, platform, rancher, k8s, strategy string) {
+		plan, err := p.Plan(Request{Platform: platform, CurrentRancher: rancher, CurrentK8s: k8s, Strategy: strategy})
+		if err != nil {
+			return
+		}
+		for i, step := range plan.Steps {
+			if step.From == "" || step.To == "" || step.From == step.To {
+				t.Fatalf("step %d of plan for %q/%q/%q is invalid: %+v", i, platform, rancher, k8s, step)
+			}
+		}
+	})
+}
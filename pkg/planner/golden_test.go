@@ -0,0 +1,78 @@
+package planner_test
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner/fixture"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected plans of the golden fixtures")
+
+// TestGoldenFixtures plans every fixture in testdata/fixtures and compares the
+// result with the stored expectation. Run with -update to accept new output.
+func TestGoldenFixtures(t *testing.T) {
+	fixtures, err := fixture.Load("testdata/fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata/fixtures")
+	}
+
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			r := f.Run()
+			if *update {
+				if err := f.Update(r); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !r.Passed() {
+				t.Errorf("%s: plan differs from fixture:\n%s", f.Path(), r.Diff)
+			}
+		})
+	}
+}
+
+// TestGoldenFixturesDeterministic plans every fixture repeatedly and requires
+// identical output each time, so map iteration order cannot leak into plans.
+func TestGoldenFixturesDeterministic(t *testing.T) {
+	fixtures, err := fixture.Load("testdata/fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			paths, err := f.LoadDataset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := f.RunWith(paths)
+			for i := 0; i < 20; i++ {
+				// A fresh planner rebuilds every version list from the maps
+				if r := f.RunWith(paths); !reflect.DeepEqual(stripGeneratedAt(r.Plan), stripGeneratedAt(first.Plan)) || fmt.Sprint(r.Err) != fmt.Sprint(first.Err) {
+					t.Fatalf("%s: run %d differs from the first run", f.Path(), i+2)
+				}
+			}
+		})
+	}
+}
+
+// stripGeneratedAt returns a copy of the plan without the generation time,
+// the only part of a plan expected to differ between runs
+func stripGeneratedAt(plan *planner.Plan) *planner.Plan {
+	if plan == nil || plan.Meta == nil {
+		return plan
+	}
+	stripped, meta := *plan, *plan.Meta
+	meta.GeneratedAt = time.Time{}
+	stripped.Meta = &meta
+	return &stripped
+}
@@ -0,0 +1,241 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Graph is the precomputed compatibility graph of a single platform: every
+// known Kubernetes version and the range each Rancher version supports.
+type Graph struct {
+	Platform    string
+	K8sVersions []*version.Version // Ascending, one entry per distinct version
+	ranges      map[string]k8sRange
+}
+
+// k8sRange is the inclusive Kubernetes range a Rancher version supports
+type k8sRange struct {
+	min, max *version.Version
+}
+
+// NewGraph builds the graph for the platform from the Rancher versions, which
+// must be sorted ascending.
+func NewGraph(platform string, rancherVersions []string, paths UpgradePaths) *Graph {
+	g := &Graph{
+		Platform: strings.ToLower(platform),
+		ranges:   make(map[string]k8sRange),
+	}
+
+	releases := platformReleases(paths, platform)
+	seen := make(map[string]bool)
+	for _, rv := range rancherVersions {
+		for _, p := range paths.RancherManager[rv].SupportedPlatforms {
+			if !strings.EqualFold(p.Platform, platform) {
+				continue
+			}
+			minVer, err := version.NewVersion(cleanVersion(p.MinVersion))
+			if err != nil {
+				continue
+			}
+			maxVer, err := version.NewVersion(cleanVersion(p.MaxVersion))
+			if err != nil {
+				continue
+			}
+			g.ranges[rv] = k8sRange{min: minVer, max: maxVer}
+
+			// Keep the first spelling of each version in Rancher order
+			for _, v := range getMinorVersionsBetween(minVer, maxVer, p, releases) {
+				if key := versionKey(v); !seen[key] {
+					seen[key] = true
+					g.K8sVersions = append(g.K8sVersions, v)
+				}
+			}
+		}
+	}
+	sort.Stable(version.Collection(g.K8sVersions))
+	return g
+}
+
+// Supports reports whether the Rancher version supports the Kubernetes version.
+// A bound written as major.minor, such as "v1.24", covers every patch of that minor.
+func (g *Graph) Supports(rancher string, k8s *version.Version) bool {
+	r, ok := g.ranges[rancher]
+	if !ok {
+		return false
+	}
+	return inRange(k8s, r.min, r.max)
+}
+
+// Newest returns the highest Kubernetes range bound any Rancher version supports
+func (g *Graph) Newest() *version.Version {
+	var newest *version.Version
+	for _, r := range g.ranges {
+		if newest == nil || r.max.GreaterThan(newest) || (r.max.Equal(newest) && r.max.Original() < newest.Original()) {
+			newest = r.max
+		}
+	}
+	return newest
+}
+
+// Range returns the Kubernetes range supported by the Rancher version
+func (g *Graph) Range(rancher string) (min, max *version.Version, ok bool) {
+	r, ok := g.ranges[rancher]
+	return r.min, r.max, ok
+}
+
+// shortestPathStrategy searches the compatibility graph for the plan with the
+// fewest steps that reaches the newest checkpoint on the newest Kubernetes
+// version it supports. Unlike greedy it only hops to Rancher versions that
+// support the cluster's Kubernetes version at that point, and it avoids hops
+// rejected by blocking constraints.
+type shortestPathStrategy struct{}
+
+func (shortestPathStrategy) Name() string { return "shortest-path" }
+
+// pathNode is a (Rancher, Kubernetes) state in the search
+type pathNode struct {
+	rancher, k8s int
+}
+
+func (shortestPathStrategy) Steps(in PlanInput) ([]UpgradeStep, error) {
+	current, err := version.NewVersion(in.CurrentRancher)
+	if err != nil {
+		return nil, fmt.Errorf("invalid current Rancher version: %v", err)
+	}
+	currentK8s, err := parseK8sVersion(in.CurrentK8s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid current Kubernetes version: %v", err)
+	}
+
+	// Rancher states: the current version followed by every newer checkpoint.
+	// Without a newer one, only Kubernetes is upgraded.
+	rancherSeq := []string{in.CurrentRancher}
+	for _, v := range in.Checkpoints {
+		if ver, err := version.NewVersion(v); err == nil && ver.GreaterThan(current) {
+			rancherSeq = append(rancherSeq, v)
+		}
+	}
+
+	// Kubernetes states: every known version, with the current one spelled as submitted
+	k8sSeq := make([]*version.Version, 0, len(in.Graph.K8sVersions)+1)
+	for _, v := range in.Graph.K8sVersions {
+		if !v.Equal(currentK8s) {
+			k8sSeq = append(k8sSeq, v)
+		}
+	}
+	k8sSeq = append(k8sSeq, currentK8s)
+	sort.Stable(version.Collection(k8sSeq))
+	startK8s := 0
+	for i, v := range k8sSeq {
+		if v == currentK8s {
+			startK8s = i
+			break
+		}
+	}
+
+	// The goal is the newest Kubernetes version the last checkpoint supports
+	last := len(rancherSeq) - 1
+	goalK8s := -1
+	for i, v := range k8sSeq {
+		if in.Graph.Supports(rancherSeq[last], v) {
+			goalK8s = i
+		}
+	}
+	if last == 0 && goalK8s <= startK8s {
+		return nil, nil
+	}
+	if goalK8s < 0 {
+		return nil, classify(CodeNoPathFound, fmt.Errorf("no supported Kubernetes versions for platform %s on Rancher %s", in.Platform, rancherSeq[last]))
+	}
+
+	policy := in.Rules.SkipPolicy()
+	start := pathNode{0, startK8s}
+	goal := pathNode{last, goalK8s}
+	parent := map[pathNode]pathNode{start: start}
+	queue := []pathNode{start}
+
+	for len(queue) > 0 && !hasNode(parent, goal) {
+		if err := in.canceled(); err != nil {
+			return nil, err
+		}
+		node := queue[0]
+		queue = queue[1:]
+
+		var next []pathNode
+		// Kubernetes upgrades on the current Rancher version, largest jump first
+		for k := len(k8sSeq) - 1; k > node.k8s; k-- {
+			if minorDistance(k8sSeq[node.k8s], k8sSeq[k]) <= policy.MaxMinors &&
+				in.Graph.Supports(rancherSeq[node.rancher], k8sSeq[k]) {
+				next = append(next, pathNode{node.rancher, k})
+			}
+		}
+		// Rancher upgrades that keep the Kubernetes version supported, furthest first
+		for r := last; r > node.rancher; r-- {
+			if in.Graph.Supports(rancherSeq[r], k8sSeq[node.k8s]) &&
+				!hopBlocked(in, rancherSeq[node.rancher], rancherSeq[r], k8sSeq[node.k8s]) {
+				next = append(next, pathNode{r, node.k8s})
+			}
+		}
+
+		for _, n := range next {
+			if !hasNode(parent, n) {
+				parent[n] = node
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	if !hasNode(parent, goal) {
+		return nil, classify(CodeNoPathFound, fmt.Errorf("no supported upgrade path found from Rancher %s with Kubernetes %s on %s", in.CurrentRancher, in.CurrentK8s, in.Platform))
+	}
+
+	// Walk back from the goal and emit the steps in order
+	var path []pathNode
+	for n := goal; n != start; n = parent[n] {
+		path = append(path, n)
+	}
+	steps := make([]UpgradeStep, 0, len(path))
+	prev := start
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		if n.rancher != prev.rancher {
+			steps = append(steps, UpgradeStep{Type: "Rancher", From: rancherSeq[prev.rancher], To: rancherSeq[n.rancher]})
+		} else {
+			step := UpgradeStep{
+				Type:     "Kubernetes",
+				Platform: in.Platform,
+				From:     in.Rules.FormatVersion(k8sSeq[prev.k8s]),
+				To:       in.Rules.FormatVersion(k8sSeq[n.k8s]),
+			}
+			in.Rules.Annotate(&step)
+			steps = append(steps, step)
+		}
+		prev = n
+	}
+	return steps, nil
+}
+
+// hopBlocked reports whether a blocking constraint rejects the Rancher hop
+func hopBlocked(in PlanInput, from, to string, k8s *version.Version) bool {
+	step := UpgradeStep{Type: "Rancher", From: from, To: to}
+	_, err := evaluateConstraints(in.Paths.Constraints, in.Platform, from, "v"+k8s.String(), []UpgradeStep{step}, Request{}, printer{})
+	return err != nil
+}
+
+// minorDistance returns how many minor versions separate two versions
+func minorDistance(from, to *version.Version) int {
+	f, t := from.Segments(), to.Segments()
+	if len(f) < 2 || len(t) < 2 || f[0] != t[0] {
+		return int(^uint(0) >> 1)
+	}
+	return t[1] - f[1]
+}
+
+// hasNode reports whether the node has been visited
+func hasNode(visited map[pathNode]pathNode, n pathNode) bool {
+	_, ok := visited[n]
+	return ok
+}
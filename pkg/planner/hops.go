@@ -0,0 +1,43 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Rancher hop modes, selected with Request.RancherHops
+const (
+	// HopsLatestPatch lands every Rancher hop on the newest patch of its
+	// minor, starting with the newest patch of the current minor
+	HopsLatestPatch = "latest-patch"
+	// HopsMinorOnly skips the patch upgrade within the current minor and hops
+	// straight to the next minor, still landing on its newest patch
+	HopsMinorOnly = "minor-only"
+)
+
+// checkRancherHops returns an error for an unknown hop mode
+func checkRancherHops(mode string) error {
+	switch mode {
+	case "", HopsLatestPatch, HopsMinorOnly:
+		return nil
+	}
+	return classify(CodeInvalidRequest, fmt.Errorf("unknown rancher_hops %q, expected %s or %s", mode, HopsLatestPatch, HopsMinorOnly))
+}
+
+// minorOnlyCheckpoints drops the checkpoints of the current version's minor,
+// keeping waypoints, which every upgrade must pass, and the target
+func minorOnlyCheckpoints(paths UpgradePaths, current, target string, checkpoints []string) []string {
+	cur, err := version.NewVersion(current)
+	if err != nil {
+		return checkpoints
+	}
+	var kept []string
+	for _, c := range checkpoints {
+		v, err := version.NewVersion(c)
+		if err != nil || !sameMinor(v, cur) || paths.RancherManager[c].Waypoint || c == target {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,261 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when a request names no supported language
+const DefaultLanguage = "en"
+
+// catalog translates the notes and warnings the planner writes. Entries are
+// keyed by the English text, which doubles as the format for DefaultLanguage;
+// text without an entry is shown in English.
+var catalog = map[string]map[string]string{
+	"de": {
+		"The control plane is upgraded by AKS; upgrade node pools after the control plane completes": "Die Control Plane wird von AKS aktualisiert; aktualisieren Sie die Node-Pools, nachdem die Aktualisierung der Control Plane abgeschlossen ist",
+		"The control plane is upgraded by EKS; upgrade node pools after the control plane completes": "Die Control Plane wird von EKS aktualisiert; aktualisieren Sie die Node-Pools, nachdem die Aktualisierung der Control Plane abgeschlossen ist",
+		"The control plane is upgraded by GKE; upgrade node pools after the control plane completes": "Die Control Plane wird von GKE aktualisiert; aktualisieren Sie die Node-Pools, nachdem die Aktualisierung der Control Plane abgeschlossen ist",
+		"Upgrading from prerelease version %s (prerelease policy: %s)":                               "Upgrade von der Vorabversion %s (Richtlinie für Vorabversionen: %s)",
+		"Upgrading to prerelease version %s (prerelease policy: %s)":                                 "Upgrade auf die Vorabversion %s (Richtlinie für Vorabversionen: %s)",
+		"Upgrading from hotfix version %s (hotfix policy: %s)":                                       "Upgrade von der Hotfix-Version %s (Hotfix-Richtlinie: %s)",
+		"Upgrading to hotfix version %s (hotfix policy: %s)":                                         "Upgrade auf die Hotfix-Version %s (Hotfix-Richtlinie: %s)",
+		"Rancher %s and later do not support %s, so the plan stops at Rancher %s":                    "Rancher %s und neuer unterstützen %s nicht, daher endet der Plan bei Rancher %s",
+		"RKE1 is end of life; migrate the cluster to RKE2 to continue upgrading Rancher":             "RKE1 hat das Ende seines Lebenszyklus erreicht; migrieren Sie den Cluster zu RKE2, um Rancher weiter zu aktualisieren",
+		"%s. %s": "%s. %s",
+		"%s %s required, %s version not provided": "%s %s erforderlich, %s-Version nicht angegeben",
+		"%s %s required, found %s":                "%s %s erforderlich, gefunden: %s",
+		"The compatibility data does not record the UI extensions API of Rancher %s; check these UI extensions against it before upgrading: %s": "Die Kompatibilitätsdaten enthalten die UI-Extensions-API von Rancher %s nicht; prüfen Sie vor dem Upgrade diese UI-Extensions darauf: %s",
+		"UI extension %s supports extensions API %s, but Rancher %s provides %s; upgrade or remove the extension before this step":              "Die UI-Extension %s unterstützt die Extensions-API %s, Rancher %s stellt jedoch %s bereit; aktualisieren oder entfernen Sie die Extension vor diesem Schritt",
+		"Embedded %s is %s":                 "Eingebettetes %s ist %s",
+		"Embedded %s changes from %s to %s": "Eingebettetes %s ändert sich von %s auf %s",
+		"Downstream cattle-cluster-agents are only supported on the Rancher %s agent while Rancher redeploys them after this step; an agent that does not move to %s failed to redeploy and must be fixed": "Downstream-cattle-cluster-agents werden mit dem Agent von Rancher %s nur unterstützt, während Rancher sie nach diesem Schritt neu ausrollt; ein Agent, der nicht auf %s wechselt, wurde nicht neu ausgerollt und muss repariert werden",
+		"Verify that the cattle-cluster-agent and fleet-agent of every downstream cluster run the Rancher %s images and are ready before continuing":                                                       "Prüfen Sie vor dem Fortfahren, ob cattle-cluster-agent und fleet-agent jedes Downstream-Clusters die Images von Rancher %s ausführen und bereit sind",
+		"%s %s is not in the compatibility data, so OS upgrades are not planned":                                                                                                                           "%s %s ist nicht in den Kompatibilitätsdaten enthalten, daher werden keine Betriebssystem-Upgrades geplant",
+		"Docker %s is not in the compatibility data, so Docker upgrades are not planned":                                                                                                                   "Docker %s ist nicht in den Kompatibilitätsdaten enthalten, daher werden keine Docker-Upgrades geplant",
+		"Docker %s does not support Kubernetes %s; upgrade Docker on every node before this Kubernetes upgrade":                                                                                            "Docker %s unterstützt Kubernetes %s nicht; aktualisieren Sie Docker auf allen Nodes vor diesem Kubernetes-Upgrade",
+		"%s %s does not support Kubernetes %s; upgrade the nodes before this Kubernetes upgrade":                                                                                                           "%s %s unterstützt Kubernetes %s nicht; aktualisieren Sie die Nodes vor diesem Kubernetes-Upgrade",
+		"No %s release supports both Kubernetes %s and %s; upgrade the nodes right after the Kubernetes upgrade":                                                                                           "Kein Release von %s unterstützt sowohl Kubernetes %s als auch %s; aktualisieren Sie die Nodes direkt nach dem Kubernetes-Upgrade",
+		"The nodes run %s %s on Kubernetes %s, which it does not support, until they are upgraded to %s":                                                                                                   "Die Nodes führen %s %s auf Kubernetes %s aus, was nicht unterstützt wird, bis sie auf %s aktualisiert sind",
+		"No %s release in the compatibility data supports Kubernetes %s":                                                                                                                                   "Kein Release von %s in den Kompatibilitätsdaten unterstützt Kubernetes %s",
+		"Rancher %s requires cert-manager %s; pass the installed version as the cert-manager fact to plan its upgrade":                                                                                     "Rancher %s erfordert cert-manager %s; geben Sie die installierte Version als Fakt cert-manager an, um sein Upgrade zu planen",
+		"Rancher %s does not support cert-manager %s; upgrade cert-manager before this Rancher upgrade":                                                                                                    "Rancher %s unterstützt cert-manager %s nicht; aktualisieren Sie cert-manager vor diesem Rancher-Upgrade",
+		"No cert-manager release supports both Rancher %s and %s; upgrade cert-manager right after the Rancher upgrade":                                                                                    "Kein Release von cert-manager unterstützt sowohl Rancher %s als auch %s; aktualisieren Sie cert-manager direkt nach dem Rancher-Upgrade",
+		"Rancher %s runs with cert-manager %s, which it does not support, until cert-manager is upgraded to %s":                                                                                            "Rancher %s läuft mit cert-manager %s, das es nicht unterstützt, bis cert-manager auf %s aktualisiert ist",
+		"Rancher %s requires cert-manager %s and no newer release in the compatibility data satisfies it":                                                                                                  "Rancher %s erfordert cert-manager %s, und kein neueres Release in den Kompatibilitätsdaten erfüllt diese Anforderung",
+		"The plan ends on Rancher %s, which is out of support on %s":                                                                                                                                       "Der Plan endet bei Rancher %s, das am %s nicht mehr unterstützt wird",
+		"%s is not supported for the local cluster":                                                                                                                                                        "%s wird für den lokalen Cluster nicht unterstützt",
+		"Kubernetes %s is outside the supported range v%s to v%s":                                                                                                                                          "Kubernetes %s liegt außerhalb des unterstützten Bereichs v%s bis v%s",
+		"%d nodes required, found %d":                                        "%d Nodes erforderlich, gefunden: %d",
+		"%d CPUs per node required, found %d":                                "%d CPUs pro Node erforderlich, gefunden: %d",
+		"%d GB of memory per node required, found %d":                        "%d GB Arbeitsspeicher pro Node erforderlich, gefunden: %d",
+		"The local cluster does not meet the requirements of Rancher %s: %s": "Der lokale Cluster erfüllt die Anforderungen von Rancher %s nicht: %s",
+		"Check the expiry of the certificate Rancher serves; renew it, or let cert-manager renew it, before upgrading if it expires soon":                                                                 "Prüfen Sie das Ablaufdatum des Zertifikats, mit dem Rancher ausliefert; erneuern Sie es vor dem Upgrade oder lassen Sie es von cert-manager erneuern, wenn es bald abläuft",
+		"Check the expiry of the RKE cluster certificates and rotate them with RKE before upgrading if they expire soon":                                                                                  "Prüfen Sie das Ablaufdatum der RKE-Clusterzertifikate und rotieren Sie sie vor dem Upgrade mit RKE, wenn sie bald ablaufen",
+		"Check the expiry of the RKE2 certificates on every server node and rotate them before upgrading if they expire soon; restarting rke2-server also renews certificates that expire within 90 days": "Prüfen Sie das Ablaufdatum der RKE2-Zertifikate auf jedem Server-Node und rotieren Sie sie vor dem Upgrade, wenn sie bald ablaufen; ein Neustart von rke2-server erneuert außerdem Zertifikate, die innerhalb von 90 Tagen ablaufen",
+		"Check the expiry of the K3s certificates on every server node and rotate them before upgrading if they expire soon; restarting k3s also renews certificates that expire within 90 days":          "Prüfen Sie das Ablaufdatum der K3s-Zertifikate auf jedem Server-Node und rotieren Sie sie vor dem Upgrade, wenn sie bald ablaufen; ein Neustart von k3s erneuert außerdem Zertifikate, die innerhalb von 90 Tagen ablaufen",
+		"The cluster certificates expire on %s, within 90 days of the planned date %s; rotate them before the first step":                                                                                 "Die Clusterzertifikate laufen am %s ab, innerhalb von 90 Tagen nach dem geplanten Datum %s; rotieren Sie sie vor dem ersten Schritt",
+		"By the planned date %s, Rancher %s are projected to be released; they are not in the compatibility data yet, so the plan cannot target them":                                                     "Bis zum geplanten Datum %s werden voraussichtlich Rancher %s veröffentlicht; sie sind noch nicht in den Kompatibilitätsdaten, daher kann der Plan sie nicht als Ziel verwenden",
+		"By the planned date %s, Rancher %s is projected to be released; it is not in the compatibility data yet, so the plan cannot target it":                                                           "Bis zum geplanten Datum %s wird voraussichtlich Rancher %s veröffentlicht; es ist noch nicht in den Kompatibilitätsdaten, daher kann der Plan es nicht als Ziel verwenden",
+		"The %s strategy would run Kubernetes %s on Rancher %s, which is not supported, so the plan uses the %s strategy to keep the cluster supported at every step":                                     "Die Strategie %s würde Kubernetes %s auf Rancher %s betreiben, was nicht unterstützt wird, daher verwendet der Plan die Strategie %s, damit der Cluster bei jedem Schritt unterstützt bleibt",
+		"Provision a new %s cluster on Kubernetes %s from Rancher %s and move the workloads of the %s cluster to it":                                                                                      "Stellen Sie mit Rancher %[3]s einen %[1]s-Cluster mit Kubernetes %[2]s bereit und verschieben Sie die Workloads des %[4]s-Clusters dorthin",
+		"Verify that every workload runs on the %s cluster and Rancher reports it active before removing the %s cluster":                                                                                  "Überprüfen Sie, dass alle Workloads auf dem %[1]s-Cluster laufen und Rancher ihn als aktiv meldet, bevor Sie den %[2]s-Cluster entfernen",
+	},
+	"ja": {
+		"The control plane is upgraded by AKS; upgrade node pools after the control plane completes": "コントロールプレーンは AKS によってアップグレードされます。コントロールプレーンのアップグレード完了後にノードプールをアップグレードしてください",
+		"The control plane is upgraded by EKS; upgrade node pools after the control plane completes": "コントロールプレーンは EKS によってアップグレードされます。コントロールプレーンのアップグレード完了後にノードプールをアップグレードしてください",
+		"The control plane is upgraded by GKE; upgrade node pools after the control plane completes": "コントロールプレーンは GKE によってアップグレードされます。コントロールプレーンのアップグレード完了後にノードプールをアップグレードしてください",
+		"Upgrading from prerelease version %s (prerelease policy: %s)":                               "プレリリース版 %s からのアップグレード (プレリリースポリシー: %s)",
+		"Upgrading to prerelease version %s (prerelease policy: %s)":                                 "プレリリース版 %s へのアップグレード (プレリリースポリシー: %s)",
+		"Upgrading from hotfix version %s (hotfix policy: %s)":                                       "ホットフィックス版 %s からのアップグレード (ホットフィックスポリシー: %s)",
+		"Upgrading to hotfix version %s (hotfix policy: %s)":                                         "ホットフィックス版 %s へのアップグレード (ホットフィックスポリシー: %s)",
+		"Rancher %s and later do not support %s, so the plan stops at Rancher %s":                    "Rancher %[1]s 以降は %[2]s をサポートしていないため、プランは Rancher %[3]s で終了します",
+		"RKE1 is end of life; migrate the cluster to RKE2 to continue upgrading Rancher":             "RKE1 はサポートが終了しています。Rancher のアップグレードを続けるには、クラスターを RKE2 に移行してください",
+		"%s. %s": "%s。%s",
+		"%s %s required, %s version not provided": "%[1]s %[2]s が必要ですが、%[3]s のバージョンが指定されていません",
+		"%s %s required, found %s":                "%[1]s %[2]s が必要ですが、%[3]s が検出されました",
+		"The compatibility data does not record the UI extensions API of Rancher %s; check these UI extensions against it before upgrading: %s": "互換性データには Rancher %s の UI 拡張機能 API が記録されていません。アップグレード前に次の UI 拡張機能が対応していることを確認してください: %s",
+		"UI extension %s supports extensions API %s, but Rancher %s provides %s; upgrade or remove the extension before this step":              "UI 拡張機能 %[1]s は拡張機能 API %[2]s に対応していますが、Rancher %[3]s は %[4]s を提供します。このステップの前に拡張機能をアップグレードまたは削除してください",
+		"Embedded %s is %s":                 "組み込みの %s は %s です",
+		"Embedded %s changes from %s to %s": "組み込みの %s は %s から %s に変わります",
+		"Downstream cattle-cluster-agents are only supported on the Rancher %s agent while Rancher redeploys them after this step; an agent that does not move to %s failed to redeploy and must be fixed": "ダウンストリームの cattle-cluster-agent が Rancher %s のエージェントでサポートされるのは、このステップの後に Rancher が再デプロイするまでの間だけです。%s に移行しないエージェントは再デプロイに失敗しているため、修正が必要です",
+		"Verify that the cattle-cluster-agent and fleet-agent of every downstream cluster run the Rancher %s images and are ready before continuing":                                                       "続行する前に、すべてのダウンストリームクラスターの cattle-cluster-agent と fleet-agent が Rancher %s のイメージを実行し、Ready であることを確認してください",
+		"%s %s is not in the compatibility data, so OS upgrades are not planned":                                                                                                                           "%s %s は互換性データに含まれていないため、OS のアップグレードは計画されません",
+		"Docker %s is not in the compatibility data, so Docker upgrades are not planned":                                                                                                                   "Docker %s は互換性データに含まれていないため、Docker のアップグレードは計画されません",
+		"Docker %s does not support Kubernetes %s; upgrade Docker on every node before this Kubernetes upgrade":                                                                                            "Docker %[1]s は Kubernetes %[2]s をサポートしていません。この Kubernetes アップグレードの前にすべてのノードの Docker をアップグレードしてください",
+		"%s %s does not support Kubernetes %s; upgrade the nodes before this Kubernetes upgrade":                                                                                                           "%[1]s %[2]s は Kubernetes %[3]s をサポートしていません。この Kubernetes アップグレードの前にノードをアップグレードしてください",
+		"No %s release supports both Kubernetes %s and %s; upgrade the nodes right after the Kubernetes upgrade":                                                                                           "Kubernetes %[2]s と %[3]s の両方をサポートする %[1]s のリリースはありません。Kubernetes のアップグレード直後にノードをアップグレードしてください",
+		"The nodes run %s %s on Kubernetes %s, which it does not support, until they are upgraded to %s":                                                                                                   "ノードは %[4]s にアップグレードされるまで、サポートされていない Kubernetes %[3]s 上で %[1]s %[2]s を実行します",
+		"No %s release in the compatibility data supports Kubernetes %s":                                                                                                                                   "互換性データには Kubernetes %[2]s をサポートする %[1]s のリリースがありません",
+		"Rancher %s requires cert-manager %s; pass the installed version as the cert-manager fact to plan its upgrade":                                                                                     "Rancher %[1]s には cert-manager %[2]s が必要です。アップグレードを計画するには、インストール済みのバージョンを cert-manager ファクトとして指定してください",
+		"Rancher %s does not support cert-manager %s; upgrade cert-manager before this Rancher upgrade":                                                                                                    "Rancher %[1]s は cert-manager %[2]s をサポートしていません。この Rancher アップグレードの前に cert-manager をアップグレードしてください",
+		"No cert-manager release supports both Rancher %s and %s; upgrade cert-manager right after the Rancher upgrade":                                                                                    "Rancher %[1]s と %[2]s の両方をサポートする cert-manager のリリースはありません。Rancher のアップグレード直後に cert-manager をアップグレードしてください",
+		"Rancher %s runs with cert-manager %s, which it does not support, until cert-manager is upgraded to %s":                                                                                            "cert-manager が %[3]s にアップグレードされるまで、Rancher %[1]s はサポートされていない cert-manager %[2]s で動作します",
+		"Rancher %s requires cert-manager %s and no newer release in the compatibility data satisfies it":                                                                                                  "Rancher %[1]s には cert-manager %[2]s が必要ですが、互換性データにはこれを満たす新しいリリースがありません",
+		"The plan ends on Rancher %s, which is out of support on %s":                                                                                                                                       "プランは Rancher %[1]s で終了しますが、%[2]s の時点でサポートが終了しています",
+		"%s is not supported for the local cluster":                                                                                                                                                        "%s はローカルクラスターでサポートされていません",
+		"Kubernetes %s is outside the supported range v%s to v%s":                                                                                                                                          "Kubernetes %[1]s はサポート範囲 v%[2]s から v%[3]s の外にあります",
+		"%d nodes required, found %d":                                        "%[1]d ノードが必要ですが、%[2]d ノードです",
+		"%d CPUs per node required, found %d":                                "ノードあたり %[1]d CPU が必要ですが、%[2]d CPU です",
+		"%d GB of memory per node required, found %d":                        "ノードあたり %[1]d GB のメモリが必要ですが、%[2]d GB です",
+		"The local cluster does not meet the requirements of Rancher %s: %s": "ローカルクラスターは Rancher %[1]s の要件を満たしていません: %[2]s",
+		"Check the expiry of the certificate Rancher serves; renew it, or let cert-manager renew it, before upgrading if it expires soon":                                                                 "Rancher が提供する証明書の有効期限を確認してください。まもなく期限切れになる場合は、アップグレード前に更新するか cert-manager に更新させてください",
+		"Check the expiry of the RKE cluster certificates and rotate them with RKE before upgrading if they expire soon":                                                                                  "RKE クラスター証明書の有効期限を確認し、まもなく期限切れになる場合はアップグレード前に RKE でローテーションしてください",
+		"Check the expiry of the RKE2 certificates on every server node and rotate them before upgrading if they expire soon; restarting rke2-server also renews certificates that expire within 90 days": "すべてのサーバーノードで RKE2 証明書の有効期限を確認し、まもなく期限切れになる場合はアップグレード前にローテーションしてください。rke2-server を再起動すると、90 日以内に期限切れになる証明書も更新されます",
+		"Check the expiry of the K3s certificates on every server node and rotate them before upgrading if they expire soon; restarting k3s also renews certificates that expire within 90 days":          "すべてのサーバーノードで K3s 証明書の有効期限を確認し、まもなく期限切れになる場合はアップグレード前にローテーションしてください。k3s を再起動すると、90 日以内に期限切れになる証明書も更新されます",
+		"The cluster certificates expire on %s, within 90 days of the planned date %s; rotate them before the first step":                                                                                 "クラスター証明書は %[1]s に期限切れになり、予定日 %[2]s から 90 日以内です。最初のステップの前にローテーションしてください",
+		"By the planned date %s, Rancher %s are projected to be released; they are not in the compatibility data yet, so the plan cannot target them":                                                     "予定日 %[1]s までに Rancher %[2]s のリリースが見込まれていますが、互換性データにまだ含まれていないため、プランの対象にできません",
+		"By the planned date %s, Rancher %s is projected to be released; it is not in the compatibility data yet, so the plan cannot target it":                                                           "予定日 %[1]s までに Rancher %[2]s のリリースが見込まれていますが、互換性データにまだ含まれていないため、プランの対象にできません",
+		"The %s strategy would run Kubernetes %s on Rancher %s, which is not supported, so the plan uses the %s strategy to keep the cluster supported at every step":                                     "%[1]s 戦略ではサポートされていない Rancher %[3]s 上の Kubernetes %[2]s を経由するため、クラスターが各ステップでサポート対象であり続けるようにプランは %[4]s 戦略を使用します",
+		"Provision a new %s cluster on Kubernetes %s from Rancher %s and move the workloads of the %s cluster to it":                                                                                      "Rancher %[3]s から Kubernetes %[2]s の %[1]s クラスターをプロビジョニングし、%[4]s クラスターのワークロードを移動してください",
+		"Verify that every workload runs on the %s cluster and Rancher reports it active before removing the %s cluster":                                                                                  "%[2]s クラスターを削除する前に、すべてのワークロードが %[1]s クラスターで実行され、Rancher がそのクラスターをアクティブと報告していることを確認してください",
+	},
+	"zh": {
+		"The control plane is upgraded by AKS; upgrade node pools after the control plane completes": "控制平面由 AKS 升级；请在控制平面升级完成后再升级节点池",
+		"The control plane is upgraded by EKS; upgrade node pools after the control plane completes": "控制平面由 EKS 升级；请在控制平面升级完成后再升级节点池",
+		"The control plane is upgraded by GKE; upgrade node pools after the control plane completes": "控制平面由 GKE 升级；请在控制平面升级完成后再升级节点池",
+		"Upgrading from prerelease version %s (prerelease policy: %s)":                               "从预发布版本 %s 升级（预发布策略：%s）",
+		"Upgrading to prerelease version %s (prerelease policy: %s)":                                 "升级到预发布版本 %s（预发布策略：%s）",
+		"Upgrading from hotfix version %s (hotfix policy: %s)":                                       "从热修复版本 %s 升级（热修复策略：%s）",
+		"Upgrading to hotfix version %s (hotfix policy: %s)":                                         "升级到热修复版本 %s（热修复策略：%s）",
+		"Rancher %s and later do not support %s, so the plan stops at Rancher %s":                    "Rancher %[1]s 及更高版本不支持 %[2]s，因此计划在 Rancher %[3]s 处结束",
+		"RKE1 is end of life; migrate the cluster to RKE2 to continue upgrading Rancher":             "RKE1 已终止生命周期；请将集群迁移到 RKE2 以继续升级 Rancher",
+		"%s. %s": "%s。%s",
+		"%s %s required, %s version not provided": "需要 %[1]s %[2]s，但未提供 %[3]s 版本",
+		"%s %s required, found %s":                "需要 %[1]s %[2]s，当前为 %[3]s",
+		"The compatibility data does not record the UI extensions API of Rancher %s; check these UI extensions against it before upgrading: %s": "兼容性数据未记录 Rancher %s 的 UI 扩展 API；升级前请确认以下 UI 扩展是否支持该版本：%s",
+		"UI extension %s supports extensions API %s, but Rancher %s provides %s; upgrade or remove the extension before this step":              "UI 扩展 %[1]s 支持扩展 API %[2]s，但 Rancher %[3]s 提供的是 %[4]s；请在此步骤之前升级或移除该扩展",
+		"Embedded %s is %s":                 "内置 %s 为 %s",
+		"Embedded %s changes from %s to %s": "内置 %s 从 %s 变更为 %s",
+		"Downstream cattle-cluster-agents are only supported on the Rancher %s agent while Rancher redeploys them after this step; an agent that does not move to %s failed to redeploy and must be fixed": "下游 cattle-cluster-agent 仅在此步骤后 Rancher 重新部署期间支持运行 Rancher %s 的 agent；未切换到 %s 的 agent 表示重新部署失败，必须修复",
+		"Verify that the cattle-cluster-agent and fleet-agent of every downstream cluster run the Rancher %s images and are ready before continuing":                                                       "继续之前，请确认每个下游集群的 cattle-cluster-agent 和 fleet-agent 都运行 Rancher %s 镜像并处于就绪状态",
+		"%s %s is not in the compatibility data, so OS upgrades are not planned":                                                                                                                           "兼容性数据中没有 %s %s，因此不会规划操作系统升级",
+		"Docker %s is not in the compatibility data, so Docker upgrades are not planned":                                                                                                                   "兼容性数据中没有 Docker %s，因此不会规划 Docker 升级",
+		"Docker %s does not support Kubernetes %s; upgrade Docker on every node before this Kubernetes upgrade":                                                                                            "Docker %[1]s 不支持 Kubernetes %[2]s；请在此次 Kubernetes 升级之前升级所有节点上的 Docker",
+		"%s %s does not support Kubernetes %s; upgrade the nodes before this Kubernetes upgrade":                                                                                                           "%[1]s %[2]s 不支持 Kubernetes %[3]s；请在此次 Kubernetes 升级之前升级节点",
+		"No %s release supports both Kubernetes %s and %s; upgrade the nodes right after the Kubernetes upgrade":                                                                                           "没有同时支持 Kubernetes %[2]s 和 %[3]s 的 %[1]s 版本；请在 Kubernetes 升级后立即升级节点",
+		"The nodes run %s %s on Kubernetes %s, which it does not support, until they are upgraded to %s":                                                                                                   "在升级到 %[4]s 之前，节点将在其不支持的 Kubernetes %[3]s 上运行 %[1]s %[2]s",
+		"No %s release in the compatibility data supports Kubernetes %s":                                                                                                                                   "兼容性数据中没有支持 Kubernetes %[2]s 的 %[1]s 版本",
+		"Rancher %s requires cert-manager %s; pass the installed version as the cert-manager fact to plan its upgrade":                                                                                     "Rancher %[1]s 需要 cert-manager %[2]s；请将已安装的版本作为 cert-manager 事实传入，以规划其升级",
+		"Rancher %s does not support cert-manager %s; upgrade cert-manager before this Rancher upgrade":                                                                                                    "Rancher %[1]s 不支持 cert-manager %[2]s；请在此次 Rancher 升级之前升级 cert-manager",
+		"No cert-manager release supports both Rancher %s and %s; upgrade cert-manager right after the Rancher upgrade":                                                                                    "没有同时支持 Rancher %[1]s 和 %[2]s 的 cert-manager 版本；请在 Rancher 升级后立即升级 cert-manager",
+		"Rancher %s runs with cert-manager %s, which it does not support, until cert-manager is upgraded to %s":                                                                                            "在 cert-manager 升级到 %[3]s 之前，Rancher %[1]s 将使用其不支持的 cert-manager %[2]s 运行",
+		"Rancher %s requires cert-manager %s and no newer release in the compatibility data satisfies it":                                                                                                  "Rancher %[1]s 需要 cert-manager %[2]s，但兼容性数据中没有满足该要求的更新版本",
+		"The plan ends on Rancher %s, which is out of support on %s":                                                                                                                                       "计划结束于 Rancher %[1]s，该版本在 %[2]s 已不再受支持",
+		"%s is not supported for the local cluster":                                                                                                                                                        "本地集群不支持 %s",
+		"Kubernetes %s is outside the supported range v%s to v%s":                                                                                                                                          "Kubernetes %[1]s 超出支持范围 v%[2]s 至 v%[3]s",
+		"%d nodes required, found %d":                                        "需要 %[1]d 个节点，当前为 %[2]d 个",
+		"%d CPUs per node required, found %d":                                "每个节点需要 %[1]d 个 CPU，当前为 %[2]d 个",
+		"%d GB of memory per node required, found %d":                        "每个节点需要 %[1]d GB 内存，当前为 %[2]d GB",
+		"The local cluster does not meet the requirements of Rancher %s: %s": "本地集群不满足 Rancher %[1]s 的要求：%[2]s",
+		"Check the expiry of the certificate Rancher serves; renew it, or let cert-manager renew it, before upgrading if it expires soon":                                                                 "检查 Rancher 所用证书的到期时间；如果即将到期，请在升级前续订，或由 cert-manager 续订",
+		"Check the expiry of the RKE cluster certificates and rotate them with RKE before upgrading if they expire soon":                                                                                  "检查 RKE 集群证书的到期时间；如果即将到期，请在升级前使用 RKE 轮换证书",
+		"Check the expiry of the RKE2 certificates on every server node and rotate them before upgrading if they expire soon; restarting rke2-server also renews certificates that expire within 90 days": "在每个 server 节点上检查 RKE2 证书的到期时间；如果即将到期，请在升级前轮换；重启 rke2-server 也会续订 90 天内到期的证书",
+		"Check the expiry of the K3s certificates on every server node and rotate them before upgrading if they expire soon; restarting k3s also renews certificates that expire within 90 days":          "在每个 server 节点上检查 K3s 证书的到期时间；如果即将到期，请在升级前轮换；重启 k3s 也会续订 90 天内到期的证书",
+		"The cluster certificates expire on %s, within 90 days of the planned date %s; rotate them before the first step":                                                                                 "集群证书将于 %[1]s 到期，距计划日期 %[2]s 不足 90 天；请在第一步之前轮换证书",
+		"By the planned date %s, Rancher %s are projected to be released; they are not in the compatibility data yet, so the plan cannot target them":                                                     "预计在计划日期 %[1]s 之前将发布 Rancher %[2]s；它们尚未包含在兼容性数据中，因此计划无法以其为目标",
+		"By the planned date %s, Rancher %s is projected to be released; it is not in the compatibility data yet, so the plan cannot target it":                                                           "预计在计划日期 %[1]s 之前将发布 Rancher %[2]s；它尚未包含在兼容性数据中，因此计划无法以其为目标",
+		"The %s strategy would run Kubernetes %s on Rancher %s, which is not supported, so the plan uses the %s strategy to keep the cluster supported at every step":                                     "%[1]s 策略会在 Rancher %[3]s 上运行不受支持的 Kubernetes %[2]s，因此计划改用 %[4]s 策略，使集群在每个步骤都保持受支持",
+		"Provision a new %s cluster on Kubernetes %s from Rancher %s and move the workloads of the %s cluster to it":                                                                                      "通过 Rancher %[3]s 预配 Kubernetes %[2]s 的 %[1]s 集群，并将 %[4]s 集群的工作负载迁移到该集群",
+		"Verify that every workload runs on the %s cluster and Rancher reports it active before removing the %s cluster":                                                                                  "在删除 %[2]s 集群之前，确认所有工作负载都在 %[1]s 集群上运行，并且 Rancher 报告该集群处于活动状态",
+	},
+}
+
+// Languages returns the languages notes and warnings can be written in,
+// starting with DefaultLanguage
+func Languages() []string {
+	langs := make([]string, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return append([]string{DefaultLanguage}, langs...)
+}
+
+// NegotiateLanguage returns the supported language that best matches an
+// Accept-Language style list such as "de-CH, de;q=0.9, en;q=0.5". Regional
+// variants match their base language; DefaultLanguage is returned when
+// nothing matches.
+func NegotiateLanguage(accept string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.lang == "*" {
+			return DefaultLanguage
+		}
+		base, region, _ := strings.Cut(c.lang, "-")
+		if base == "zh" && traditionalChinese[region] {
+			continue // The zh catalog is Simplified Chinese
+		}
+		if _, ok := catalog[base]; ok || base == DefaultLanguage {
+			return base
+		}
+	}
+	return DefaultLanguage
+}
+
+// traditionalChinese lists the zh subtags that select Traditional Chinese
+var traditionalChinese = map[string]bool{"hant": true, "tw": true, "hk": true, "mo": true}
+
+// printer writes notes and warnings in a single language
+type printer struct {
+	lang string
+}
+
+// sprintf formats the translation of the English format
+func (p printer) sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(p.text(format), args...)
+}
+
+// text returns the translation of the English text, or the text itself when
+// the catalog has none
+func (p printer) text(s string) string {
+	if translated, ok := catalog[p.lang][s]; ok {
+		return translated
+	}
+	return s
+}
+
+// localizedRules translates the fixed notes the wrapped rules add to a step
+type localizedRules struct {
+	PlatformRules
+	printer printer
+}
+
+// localized wraps the rules unless the language is DefaultLanguage
+func localized(rules PlatformRules, pr printer) PlatformRules {
+	if pr.lang == DefaultLanguage || pr.lang == "" {
+		return rules
+	}
+	return localizedRules{PlatformRules: rules, printer: pr}
+}
+
+// Annotate adds the wrapped rules' notes in the printer's language
+func (r localizedRules) Annotate(step *UpgradeStep) {
+	n := len(step.Notes)
+	r.PlatformRules.Annotate(step)
+	for i := n; i < len(step.Notes); i++ {
+		step.Notes[i] = r.printer.text(step.Notes[i])
+	}
+}
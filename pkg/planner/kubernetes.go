@@ -0,0 +1,207 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// GetAllowedK8sUpgrades determines the Kubernetes upgrade path based on platform rules
+func GetAllowedK8sUpgrades(currentK8s, platform string, r1, r2 RancherManagerVersion) []UpgradeStep {
+	return allowedK8sUpgrades(currentK8s, platform, r1, r2, rulesFor(platform), nil)
+}
+
+// allowedK8sUpgrades determines the Kubernetes upgrade path using the given rules
+// and the platform's published releases
+func allowedK8sUpgrades(currentK8s, platform string, r1, r2 RancherManagerVersion, rules PlatformRules, releases []*version.Version) []UpgradeStep {
+	return upgradeK8s(currentK8s, platform, getSortedK8sVersions(platform, r1, r2, releases), rules, nil)
+}
+
+// upgradeK8s steps through the Kubernetes versions as far as the rules allow,
+// stopping early once done reports true for the version reached
+func upgradeK8s(currentK8s, platform string, k8sVersions []*version.Version, rules PlatformRules, done func(*version.Version) bool) []UpgradeStep {
+	var upgrades []UpgradeStep
+
+	currentVer, err := parseK8sVersion(currentK8s)
+	if err != nil {
+		return upgrades
+	}
+
+	// Ensure current version is in the list
+	if !versionInList(currentVer, k8sVersions) {
+		k8sVersions = append(k8sVersions, currentVer)
+		sort.Stable(version.Collection(k8sVersions))
+	}
+
+	for done == nil || !done(currentVer) {
+		nextVer := findNextAcceptableK8sVersion(currentVer, k8sVersions, rules.SkipPolicy())
+		if nextVer == nil {
+			break
+		}
+
+		step := UpgradeStep{
+			Type:     "Kubernetes",
+			Platform: platform,
+			From:     rules.FormatVersion(currentVer),
+			To:       rules.FormatVersion(nextVer),
+		}
+		rules.Annotate(&step)
+		upgrades = append(upgrades, step)
+		currentVer = nextVer
+	}
+
+	return upgrades
+}
+
+// platformRange returns the Kubernetes range the Rancher version supports for the platform
+func platformRange(r RancherManagerVersion, platform string) (min, max *version.Version, ok bool) {
+	for _, p := range r.SupportedPlatforms {
+		if !strings.EqualFold(p.Platform, platform) {
+			continue
+		}
+		minVer, err := version.NewVersion(cleanVersion(p.MinVersion))
+		if err != nil {
+			continue
+		}
+		maxVer, err := version.NewVersion(cleanVersion(p.MaxVersion))
+		if err != nil {
+			continue
+		}
+		return minVer, maxVer, true
+	}
+	return nil, nil, false
+}
+
+// findNextAcceptableK8sVersion finds the next acceptable Kubernetes version
+func findNextAcceptableK8sVersion(currentVer *version.Version, k8sVersions []*version.Version, policy SkipPolicy) *version.Version {
+	currentSegments := currentVer.Segments()
+	if len(currentSegments) < 2 {
+		return nil
+	}
+	currentMinor := currentSegments[1]
+	maxAllowedMinor := currentMinor + policy.MaxMinors
+
+	var candidate *version.Version
+	for _, v := range k8sVersions {
+		if v.LessThanOrEqual(currentVer) {
+			continue
+		}
+		nextSegments := v.Segments()
+		if len(nextSegments) < 2 {
+			continue
+		}
+		nextMinor := nextSegments[1]
+		if nextMinor > maxAllowedMinor {
+			break // No further versions are acceptable
+		}
+		candidate = v // Update candidate to the current acceptable version
+
+		if policy.Stepwise {
+			// For platforms that do not allow skipping, return the first acceptable version immediately
+			break
+		}
+	}
+	return candidate
+}
+
+// Checks if a version is in the list
+func versionInList(ver *version.Version, list []*version.Version) bool {
+	for _, v := range list {
+		if v.Equal(ver) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSortedK8sVersions retrieves and sorts the Kubernetes versions for the given platform.
+// Versions that compare equal are listed once, using the first spelling found in
+// the data, so the result does not depend on map iteration order.
+func getSortedK8sVersions(platform string, r1, r2 RancherManagerVersion, releases []*version.Version) []*version.Version {
+	var versionList []*version.Version
+	seen := make(map[string]bool)
+	platforms := append(append([]Platform(nil), r1.SupportedPlatforms...), r2.SupportedPlatforms...)
+	platformLower := strings.ToLower(platform)
+
+	for _, p := range platforms {
+		pPlatformLower := strings.ToLower(p.Platform)
+		if pPlatformLower == platformLower {
+			minVerStr := cleanVersion(p.MinVersion)
+			maxVerStr := cleanVersion(p.MaxVersion)
+			minVer, err := version.NewVersion(minVerStr)
+			if err != nil {
+				continue
+			}
+			maxVer, err := version.NewVersion(maxVerStr)
+			if err != nil {
+				continue
+			}
+			// Generate all minor versions between minVer and maxVer
+			versionsBetween := getMinorVersionsBetween(minVer, maxVer, p, releases)
+			for _, v := range versionsBetween {
+				if key := versionKey(v); !seen[key] {
+					seen[key] = true
+					versionList = append(versionList, v)
+				}
+			}
+		}
+	}
+
+	// Sort the versions, keeping data order for ties
+	sort.Stable(version.Collection(versionList))
+
+	return versionList
+}
+
+// getMinorVersionsBetween returns all minor versions between min and max versions, including exact versions from data.
+// Versions without build metadata are replaced by the newest published release of their minor when the data lists one.
+func getMinorVersionsBetween(minVer, maxVer *version.Version, platformData Platform, releases []*version.Version) []*version.Version {
+	var versions []*version.Version
+	add := func(v *version.Version) {
+		if v.Metadata() == "" {
+			s := v.Segments()
+			if r := newestRelease(releases, s[0], s[1], minVer, maxVer); r != nil {
+				v = r
+			}
+		}
+		versions = append(versions, v)
+	}
+
+	// Include exact min and max versions with their metadata
+	minVerWithMeta, err := version.NewVersion(cleanVersion(platformData.MinVersion))
+	if err == nil {
+		add(minVerWithMeta)
+	}
+
+	maxVerWithMeta, err := version.NewVersion(cleanVersion(platformData.MaxVersion))
+	if err == nil && !maxVerWithMeta.Equal(minVerWithMeta) {
+		add(maxVerWithMeta)
+	}
+
+	// Generate intermediate minor versions
+	currentVer := minVer
+	for {
+		// Increment minor version
+		segments := currentVer.Segments()
+		if len(segments) < 2 {
+			break
+		}
+		major := segments[0]
+		minor := segments[1]
+		newMinor := minor + 1
+		newVerStr := fmt.Sprintf("%d.%d.0", major, newMinor)
+		newVer, err := version.NewVersion(newVerStr)
+		if err != nil {
+			break
+		}
+		if newVer.GreaterThan(maxVer) {
+			break
+		}
+		add(newVer)
+		currentVer = newVer
+	}
+
+	return versions
+}
@@ -0,0 +1,185 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// RuleEndOfSupport identifies the warning added when a plan ends on a Rancher
+// version that is out of support at the planned date
+const RuleEndOfSupport = "rancher-end-of-support"
+
+// RuleProjectedReleases identifies the warning added when Rancher minors not
+// in the data are projected to be released by a future planned date
+const RuleProjectedReleases = "projected-releases"
+
+// dateLayout is the format of lifecycle and planned dates
+const dateLayout = "2006-01-02"
+
+// Support phases of a Rancher minor
+const (
+	PhaseGeneral     = "general"     // Full support with feature and security updates
+	PhaseMaintenance = "maintenance" // Critical fixes and security updates only
+	PhaseLTSS        = "ltss"        // Long-term service pack support, for customers with an LTSS contract
+	PhaseEndOfLife   = "end-of-life"
+)
+
+// Lifecycle holds the end dates of the support phases of a Rancher minor, as
+// YYYY-MM-DD. A phase without a date has not been announced to end.
+type Lifecycle struct {
+	Released         string `json:"released,omitempty"` // General availability of the minor
+	EndOfGeneral     string `json:"end_of_general,omitempty"`
+	EndOfMaintenance string `json:"end_of_maintenance,omitempty"`
+	EndOfLTSS        string `json:"end_of_ltss,omitempty"`
+}
+
+// phase returns the support phase on the date; past the maintenance phase,
+// customers without LTSS are at end of life
+func (l Lifecycle) phase(on time.Time, ltss bool) string {
+	ended := func(date string) bool {
+		end, err := time.Parse(dateLayout, date)
+		return err == nil && !on.Before(end)
+	}
+	switch {
+	case !ended(l.EndOfGeneral):
+		return PhaseGeneral
+	case !ended(l.EndOfMaintenance):
+		return PhaseMaintenance
+	case ltss && !ended(l.EndOfLTSS):
+		return PhaseLTSS
+	}
+	return PhaseEndOfLife
+}
+
+// plannedDate parses the date a plan is executed on, defaulting to today,
+// or to the given day when it is set
+func plannedDate(date string, today time.Time) (time.Time, error) {
+	if date == "" {
+		if today.IsZero() {
+			today = time.Now()
+		}
+		return today.UTC().Truncate(24 * time.Hour), nil
+	}
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, classify(CodeInvalidRequest, fmt.Errorf("invalid planned date %q, expected YYYY-MM-DD", date))
+	}
+	return t, nil
+}
+
+// annotateSupportPhases sets the support phase of the target of every Rancher
+// step whose minor has lifecycle data, warning when the last one is at end of
+// life. Passing through versions at end of life is expected.
+func annotateSupportPhases(steps []UpgradeStep, paths UpgradePaths, on time.Time, ltss bool, pr printer) *Warning {
+	last := -1
+	for i := range steps {
+		step := &steps[i]
+		if step.Type != "Rancher" {
+			continue
+		}
+		last = i
+		v, err := version.NewVersion(step.To)
+		if err != nil {
+			continue
+		}
+		minor := fmt.Sprintf("%d.%d", v.Segments()[0], v.Segments()[1])
+		lifecycle, ok := paths.Lifecycle[minor]
+		if !ok {
+			continue
+		}
+		step.SupportPhase = lifecycle.phase(on, ltss)
+	}
+	if last < 0 || steps[last].SupportPhase != PhaseEndOfLife {
+		return nil
+	}
+	return &Warning{
+		Rule:    RuleEndOfSupport,
+		Step:    last,
+		Message: pr.sprintf("The plan ends on Rancher %s, which is out of support on %s", steps[last].To, on.Format(dateLayout)),
+	}
+}
+
+// ReleaseCadence holds the assumptions on how often new minors are released,
+// used to project the releases a future planned date will see
+type ReleaseCadence struct {
+	RancherMinorDays int `json:"rancher_minor_days,omitempty"` // Days between Rancher minors
+}
+
+// ProjectedRelease is a Rancher minor that is not in the data but is expected
+// to be released by the planned date, according to the release cadence
+type ProjectedRelease struct {
+	Version  string `json:"version"`  // Minor, e.g. 2.11
+	Expected string `json:"expected"` // Projected release date, YYYY-MM-DD
+}
+
+// projectReleases projects the Rancher minors released after the newest minor
+// with a release date in the lifecycle data, up to the planned date. Minors
+// the data already has versions of are not projected.
+func projectReleases(paths UpgradePaths, on time.Time) []ProjectedRelease {
+	if paths.Cadence == nil || paths.Cadence.RancherMinorDays <= 0 {
+		return nil
+	}
+	var newest *version.Version
+	var released time.Time
+	for minor, lifecycle := range paths.Lifecycle {
+		v, err := version.NewVersion(minor)
+		if err != nil || lifecycle.Released == "" {
+			continue
+		}
+		date, err := time.Parse(dateLayout, lifecycle.Released)
+		if err != nil {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest, released = v, date
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for v := range paths.RancherManager {
+		if parsed, err := version.NewVersion(v); err == nil {
+			known[fmt.Sprintf("%d.%d", parsed.Segments()[0], parsed.Segments()[1])] = true
+		}
+	}
+
+	var projected []ProjectedRelease
+	major, minor := newest.Segments()[0], newest.Segments()[1]
+	for k := 1; ; k++ {
+		expected := released.AddDate(0, 0, k*paths.Cadence.RancherMinorDays)
+		if expected.After(on) {
+			return projected
+		}
+		if v := fmt.Sprintf("%d.%d", major, minor+k); !known[v] {
+			projected = append(projected, ProjectedRelease{Version: v, Expected: expected.Format(dateLayout)})
+		}
+	}
+}
+
+// projectionWarning points out the projected releases the plan cannot target
+func projectionWarning(projected []ProjectedRelease, on time.Time, pr printer) *Warning {
+	if len(projected) == 0 {
+		return nil
+	}
+	versions := make([]string, len(projected))
+	for i, r := range projected {
+		versions[i] = r.Version
+	}
+	if len(versions) == 1 {
+		return &Warning{
+			Rule:    RuleProjectedReleases,
+			Step:    -1,
+			Message: pr.sprintf("By the planned date %s, Rancher %s is projected to be released; it is not in the compatibility data yet, so the plan cannot target it", on.Format(dateLayout), versions[0]),
+		}
+	}
+	return &Warning{
+		Rule:    RuleProjectedReleases,
+		Step:    -1,
+		Message: pr.sprintf("By the planned date %s, Rancher %s are projected to be released; they are not in the compatibility data yet, so the plan cannot target them", on.Format(dateLayout), strings.Join(versions, ", ")),
+	}
+}
@@ -0,0 +1,76 @@
+package planner
+
+import "strings"
+
+// RuleManagementCluster identifies the warnings added when the Rancher
+// management cluster does not meet the requirements of a Rancher step's target
+const RuleManagementCluster = "management-cluster-requirements"
+
+// ManagementRequirements are the requirements a Rancher version places on the
+// local cluster it is installed on
+type ManagementRequirements struct {
+	// Platforms are the distributions and Kubernetes ranges supported for the
+	// local cluster. Empty uses the version's supported_platforms.
+	Platforms  []Platform `json:"platforms,omitempty"`
+	MinNodes   int        `json:"min_nodes,omitempty"`
+	MinCPUs    int        `json:"min_cpus,omitempty"`      // Per node
+	MinMemoryG int        `json:"min_memory_gb,omitempty"` // Per node
+}
+
+// ManagementCluster describes the local cluster Rancher is installed on
+type ManagementCluster struct {
+	Platform string `json:"platform"` // e.g. rke2
+	K8s      string `json:"k8s"`      // e.g. v1.27.10+rke2r1
+	Nodes    int    `json:"nodes,omitempty"`
+	CPUs     int    `json:"cpus,omitempty"`      // Per node
+	MemoryG  int    `json:"memory_gb,omitempty"` // Per node
+}
+
+// managementWarnings checks the management cluster against the requirements
+// of the target of every Rancher step
+func managementWarnings(paths UpgradePaths, steps []UpgradeStep, local *ManagementCluster, aliases map[string]string, pr printer) []Warning {
+	if local == nil || local.Platform == "" {
+		return nil
+	}
+	platform := canonicalPlatform(local.Platform, aliases)
+
+	var warnings []Warning
+	for i, step := range steps {
+		if step.Type != "Rancher" {
+			continue
+		}
+		target := paths.RancherManager[step.To]
+		var req ManagementRequirements
+		if target.Management != nil {
+			req = *target.Management
+		}
+		supported := RancherManagerVersion{SupportedPlatforms: req.Platforms}
+		if len(req.Platforms) == 0 {
+			supported = target
+		}
+
+		var problems []string
+		if minVer, maxVer, ok := platformRange(supported, platform); !ok {
+			problems = append(problems, pr.sprintf("%s is not supported for the local cluster", platform))
+		} else if k8s, err := parseK8sVersion(local.K8s); err == nil && !inRange(k8s, minVer, maxVer) {
+			problems = append(problems, pr.sprintf("Kubernetes %s is outside the supported range v%s to v%s", local.K8s, minVer.Original(), maxVer.Original()))
+		}
+		if req.MinNodes > 0 && local.Nodes > 0 && local.Nodes < req.MinNodes {
+			problems = append(problems, pr.sprintf("%d nodes required, found %d", req.MinNodes, local.Nodes))
+		}
+		if req.MinCPUs > 0 && local.CPUs > 0 && local.CPUs < req.MinCPUs {
+			problems = append(problems, pr.sprintf("%d CPUs per node required, found %d", req.MinCPUs, local.CPUs))
+		}
+		if req.MinMemoryG > 0 && local.MemoryG > 0 && local.MemoryG < req.MinMemoryG {
+			problems = append(problems, pr.sprintf("%d GB of memory per node required, found %d", req.MinMemoryG, local.MemoryG))
+		}
+		if len(problems) > 0 {
+			warnings = append(warnings, Warning{
+				Rule:    RuleManagementCluster,
+				Step:    i,
+				Message: pr.sprintf("The local cluster does not meet the requirements of Rancher %s: %s", step.To, strings.Join(problems, "; ")),
+			})
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,116 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// MatrixChange is how the Kubernetes minors a platform supports change
+// between two Rancher versions
+type MatrixChange struct {
+	Gained    []string `json:"gained"`    // Supported by the target only
+	Lost      []string `json:"lost"`      // Supported by the source only
+	Unchanged []string `json:"unchanged"` // Supported by both
+}
+
+// MatrixDiff compares the support matrices of two Rancher versions in the
+// data, returning for every platform either supports the Kubernetes minors
+// gained, lost, and kept. An unknown version is an *UnknownRancherVersionError.
+func (p *Planner) MatrixDiff(from, to string) (map[string]MatrixChange, error) {
+	fromVersion, err := findRancherVersion(p.versions, normalizeVersion(from))
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := findRancherVersion(p.versions, normalizeVersion(to))
+	if err != nil {
+		return nil, err
+	}
+	before := supportedMinors(p.paths.RancherManager[fromVersion])
+	after := supportedMinors(p.paths.RancherManager[toVersion])
+
+	diff := make(map[string]MatrixChange)
+	for _, platform := range unionKeys(before, after) {
+		change := MatrixChange{Gained: []string{}, Lost: []string{}, Unchanged: []string{}}
+		for _, minor := range unionMinors(before[platform], after[platform]) {
+			switch {
+			case !before[platform][minor]:
+				change.Gained = append(change.Gained, minor)
+			case !after[platform][minor]:
+				change.Lost = append(change.Lost, minor)
+			default:
+				change.Unchanged = append(change.Unchanged, minor)
+			}
+		}
+		diff[platform] = change
+	}
+	return diff, nil
+}
+
+// supportedMinors returns the Kubernetes minors each platform supports, keyed
+// by lowercase platform name
+func supportedMinors(r RancherManagerVersion) map[string]map[string]bool {
+	minors := make(map[string]map[string]bool)
+	for _, p := range r.SupportedPlatforms {
+		minVer, err := version.NewVersion(cleanVersion(p.MinVersion))
+		if err != nil {
+			continue
+		}
+		maxVer, err := version.NewVersion(cleanVersion(p.MaxVersion))
+		if err != nil {
+			continue
+		}
+		platform := strings.ToLower(p.Platform)
+		if minors[platform] == nil {
+			minors[platform] = make(map[string]bool)
+		}
+		lo, hi := minVer.Segments(), maxVer.Segments()
+		if lo[0] != hi[0] {
+			continue
+		}
+		for minor := lo[1]; minor <= hi[1]; minor++ {
+			minors[platform][fmt.Sprintf("v%d.%d", lo[0], minor)] = true
+		}
+	}
+	return minors
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys(a, b map[string]map[string]bool) []string {
+	set := make(map[string]bool)
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unionMinors returns the minors of both sets, sorted by version
+func unionMinors(a, b map[string]bool) []string {
+	set := make(map[string]bool)
+	for m := range a {
+		set[m] = true
+	}
+	for m := range b {
+		set[m] = true
+	}
+	minors := make([]string, 0, len(set))
+	for m := range set {
+		minors = append(minors, m)
+	}
+	sort.Slice(minors, func(i, j int) bool {
+		vi, _ := version.NewVersion(minors[i])
+		vj, _ := version.NewVersion(minors[j])
+		return vi.LessThan(vj)
+	})
+	return minors
+}
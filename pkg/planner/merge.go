@@ -0,0 +1,60 @@
+package planner
+
+// Merge returns the data with the overrides applied, leaving both unchanged.
+// Rancher versions, lifecycle entries, and operating systems in the overrides
+// replace those of the same key; constraints and advisories are appended, and
+// releases are added to the platform's list. A non-empty version replaces the
+// data's version, and a release cadence or list of Docker releases replaces
+// the data's.
+func Merge(base, overrides UpgradePaths) UpgradePaths {
+	merged := base
+	if overrides.Version != "" {
+		merged.Version = overrides.Version
+	}
+	if overrides.Cadence != nil {
+		merged.Cadence = overrides.Cadence
+	}
+	if len(overrides.DockerReleases) > 0 {
+		merged.DockerReleases = overrides.DockerReleases
+	}
+
+	merged.RancherManager = make(map[string]RancherManagerVersion, len(base.RancherManager)+len(overrides.RancherManager))
+	for v, r := range base.RancherManager {
+		merged.RancherManager[v] = r
+	}
+	for v, r := range overrides.RancherManager {
+		merged.RancherManager[v] = r
+	}
+
+	merged.Constraints = append(append([]Constraint(nil), base.Constraints...), overrides.Constraints...)
+	merged.Advisories = append(append([]Advisory(nil), base.Advisories...), overrides.Advisories...)
+
+	if len(overrides.Releases) > 0 {
+		merged.Releases = make(map[string][]string, len(base.Releases)+len(overrides.Releases))
+		for platform, releases := range base.Releases {
+			merged.Releases[platform] = releases
+		}
+		for platform, releases := range overrides.Releases {
+			merged.Releases[platform] = append(append([]string(nil), merged.Releases[platform]...), releases...)
+		}
+	}
+	if len(overrides.OperatingSystems) > 0 {
+		merged.OperatingSystems = make(map[string][]OSRelease, len(base.OperatingSystems)+len(overrides.OperatingSystems))
+		for os, releases := range base.OperatingSystems {
+			merged.OperatingSystems[os] = releases
+		}
+		for os, releases := range overrides.OperatingSystems {
+			merged.OperatingSystems[os] = releases
+		}
+	}
+	if len(overrides.Lifecycle) > 0 {
+		merged.Lifecycle = make(map[string]Lifecycle, len(base.Lifecycle)+len(overrides.Lifecycle))
+		for minor, l := range base.Lifecycle {
+			merged.Lifecycle[minor] = l
+		}
+		for minor, l := range overrides.Lifecycle {
+			merged.Lifecycle[minor] = l
+		}
+	}
+	return merged
+}
@@ -0,0 +1,74 @@
+package planner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Version and Commit identify the planning logic in plan metadata. Builds set
+// them with -ldflags "-X github.com/supporttools/rancher-upgrade-tool/pkg/planner.Version=..."
+// and the same for Commit, the git SHA.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Meta describes the data and logic that produced a plan
+type Meta struct {
+	DatasetHash    string      `json:"dataset_hash"`              // sha256 of the compatibility data
+	DatasetVersion string      `json:"dataset_version,omitempty"` // Version declared by the data, if any
+	GeneratedAt    time.Time   `json:"generated_at"`
+	PlannerVersion string      `json:"planner_version"`
+	PlannerCommit  string      `json:"planner_commit,omitempty"` // Git SHA of the build, when known
+	Strategy       string      `json:"strategy"`
+	Language       string      `json:"language"` // Language of notes and warnings
+	Options        MetaOptions `json:"options"`
+	// Hypothetical marks plans against data changed by the caller, such as
+	// what-if plans, rather than the published data
+	Hypothetical bool `json:"hypothetical,omitempty"`
+}
+
+// MetaOptions are the planner options applied to a plan
+type MetaOptions struct {
+	Prereleases VersionPolicy `json:"prerelease_policy"`
+	Hotfixes    VersionPolicy `json:"hotfix_policy"`
+	Policy      *Policy       `json:"policy,omitempty"` // Organization policy, when one is configured
+}
+
+// datasetHash returns the sha256 of the data in its canonical JSON encoding,
+// so equal data hashes the same regardless of file formatting
+func datasetHash(paths UpgradePaths) string {
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// DatasetHash returns the hash of the planner's compatibility data as reported
+// in plan metadata
+func (p *Planner) DatasetHash() string {
+	return p.hash
+}
+
+// meta returns the metadata for a plan generated now with the strategy in the
+// language, and the options it was planned with
+func (p *Planner) meta(strategy, lang string, opts Options) *Meta {
+	return &Meta{
+		DatasetHash:    p.hash,
+		DatasetVersion: p.paths.Version,
+		GeneratedAt:    time.Now().UTC(),
+		PlannerVersion: Version,
+		PlannerCommit:  Commit,
+		Strategy:       strategy,
+		Language:       lang,
+		Options: MetaOptions{
+			Prereleases: opts.policyFor(kindPrerelease),
+			Hotfixes:    opts.policyFor(kindHotfix),
+			Policy:      opts.Policy,
+		},
+	}
+}
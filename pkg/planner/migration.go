@@ -0,0 +1,189 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// migrations maps the platforms clusters can be migrated from to the
+// platform replacing them
+var migrations = map[string]string{
+	"rke1": "rke2",
+}
+
+// planMigration plans an upgrade that replaces the cluster's platform with
+// req.MigrateTo. The cluster is first upgraded on its platform as far as it
+// is supported, or up to the target when the target still supports it. A
+// Migration step then moves it to the new platform on the same Kubernetes
+// minor, or the oldest newer one the Rancher version supports there, and the
+// plan continues on the new platform up to the target.
+func (p *Planner) planMigration(ctx context.Context, req Request) (*Plan, error) {
+	from := canonicalPlatform(req.Platform, p.aliases)
+	to := canonicalPlatform(req.MigrateTo, p.aliases)
+	if migrations[from] != to {
+		var known []string
+		for source, dest := range migrations {
+			known = append(known, source+" to "+dest)
+		}
+		sort.Strings(known)
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("cannot plan a migration from %s to %s, expected one of: %s", from, to, strings.Join(known, ", ")))
+	}
+
+	before := req
+	before.MigrateTo = ""
+	if req.TargetRancher != "" {
+		if target, err := findRancherVersion(p.versions, normalizeVersion(req.TargetRancher)); err == nil && !listsPlatform(p.paths.RancherManager[target], from) {
+			before.TargetRancher = ""
+		}
+	}
+	plan, err := p.PlanContext(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	// The state the cluster is migrated in
+	rancher, err := findRancherVersion(p.versions, normalizeVersion(req.CurrentRancher))
+	if err != nil {
+		return nil, err
+	}
+	k8s, osVersion, certManager := strings.TrimSpace(req.CurrentK8s), req.NodeOSVersion, req.Facts[CertManagerFact]
+	docker := req.DockerVersion
+	for _, step := range plan.Steps {
+		switch step.Type {
+		case "Rancher":
+			rancher = step.To
+		case "Kubernetes":
+			k8s = step.To
+		case "OS":
+			osVersion = step.To
+		case "Docker":
+			docker = step.To
+		case "cert-manager":
+			certManager = step.To
+		}
+	}
+
+	pr := printer{lang: NegotiateLanguage(req.Language)}
+	migrated, err := migrationTarget(p.paths, rancher, k8s, to)
+	if err != nil {
+		return nil, err
+	}
+	migration := UpgradeStep{
+		Type:     "Migration",
+		Platform: to,
+		From:     k8s,
+		To:       migrated,
+		Notes: []string{
+			pr.sprintf("Provision a new %s cluster on Kubernetes %s from Rancher %s and move the workloads of the %s cluster to it", to, migrated, rancher, from),
+		},
+		Verify: []string{
+			pr.sprintf("Verify that every workload runs on the %s cluster and Rancher reports it active before removing the %s cluster", to, from),
+		},
+	}
+	localized(rulesFor(to), pr).Annotate(&migration)
+	if err := checkPublished(p.paths, []UpgradeStep{migration}); err != nil {
+		return nil, err
+	}
+	migration.ID = stepID(migration)
+	migration.DependsOn = lastOfEachType(plan.Steps)
+
+	// The end-of-life warning asks for the migration the plan now contains
+	warnings := plan.Warnings[:0]
+	for _, w := range plan.Warnings {
+		if w.Rule != RuleEndOfLife {
+			warnings = append(warnings, w)
+		}
+	}
+	plan.Warnings = warnings
+	plan.Steps = append(plan.Steps, migration)
+
+	if req.TargetRancher == "" || before.TargetRancher == "" {
+		after := req
+		after.Platform = to
+		after.MigrateTo = ""
+		after.CurrentRancher = rancher
+		after.CurrentK8s = migrated
+		after.NodeOSVersion = osVersion
+		after.DockerVersion = docker
+		if certManager != "" {
+			after.Facts = make(map[string]string, len(req.Facts))
+			for name, v := range req.Facts {
+				after.Facts[name] = v
+			}
+			after.Facts[CertManagerFact] = certManager
+		}
+		rest, err := p.PlanContext(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		offset := len(plan.Steps)
+		for _, step := range rest.Steps {
+			if len(step.DependsOn) == 0 {
+				step.DependsOn = []string{migration.ID}
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+		for _, w := range rest.Warnings {
+			if w.Step >= 0 {
+				w.Step += offset
+			}
+			plan.Warnings = append(plan.Warnings, w)
+		}
+	}
+
+	plan.Effort = p.opts.Effort.estimate(plan.Steps, req.Nodes)
+	if len(p.paths.Advisories) > 0 {
+		plan.Security = securityDelta(p.paths.Advisories, to, rancher, strings.TrimSpace(req.CurrentK8s), plan.Steps)
+	}
+	plan.canonicalize()
+	return plan, nil
+}
+
+// migrationTarget returns the Kubernetes version of the platform the cluster
+// is migrated to on the Rancher version: the newest one of the running minor,
+// or of the oldest newer minor when the platform does not support that one.
+// Migrations never move the workloads to an older minor.
+func migrationTarget(paths UpgradePaths, rancher, k8s, platform string) (string, error) {
+	current, err := parseK8sVersion(k8s)
+	if err != nil {
+		return "", classify(CodeInvalidVersion, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+	r := paths.RancherManager[rancher]
+	if !listsPlatform(r, platform) {
+		return "", classify(CodeNoPathFound, fmt.Errorf("Rancher %s does not support %s, so the cluster cannot be migrated to it", rancher, platform))
+	}
+	rules := rulesFor(platform)
+	var target *version.Version
+	for _, v := range getSortedK8sVersions(platform, r, r, platformReleases(paths, platform)) {
+		switch {
+		case sameMinor(v, current):
+			target = v
+		case v.GreaterThan(current) && target == nil:
+			return rules.FormatVersion(v), nil
+		}
+	}
+	if target == nil {
+		return "", classify(CodeNoPathFound, fmt.Errorf("Rancher %s only supports %s versions older than Kubernetes %s, so the cluster cannot be migrated to it", rancher, platform, k8s))
+	}
+	return rules.FormatVersion(target), nil
+}
+
+// lastOfEachType returns the IDs of the last step of every type, in plan
+// order, which together complete every step of the plan
+func lastOfEachType(steps []UpgradeStep) []string {
+	last := make(map[string]int)
+	for i, step := range steps {
+		last[step.Type] = i
+	}
+	var ids []string
+	for i, step := range steps {
+		if last[step.Type] == i {
+			ids = append(ids, step.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,91 @@
+package planner
+
+import "github.com/hashicorp/go-version"
+
+// nodeRelease is a release of software installed on the nodes and the
+// Kubernetes versions it supports, in go-version constraint syntax
+type nodeRelease struct {
+	Version    string
+	Kubernetes string
+}
+
+// nodeSoftware is software installed on the nodes, such as the operating
+// system or Docker, whose upgrades interleaveNodeSteps plans
+type nodeSoftware struct {
+	name     string        // in notes and warnings, e.g. "SLES" or "Docker"
+	rule     string        // of the warnings added
+	releases []nodeRelease // sorted ascending
+
+	// step returns the step upgrading the nodes with the note
+	step func(from, to, note string) UpgradeStep
+	// before returns the note of an upgrade made before a Kubernetes upgrade
+	// the running release does not support
+	before func(running, k8s string) string
+}
+
+// interleaveNodeSteps inserts a step upgrading the node software before
+// every Kubernetes step its running release does not support, upgrading to
+// the oldest release that supports both the running and the target
+// Kubernetes version. When no release supports both, the step follows the
+// Kubernetes step and a warning points out the gap. Warnings are moved along
+// with their steps.
+func interleaveNodeSteps(steps []UpgradeStep, warnings []Warning, sw nodeSoftware, current nodeRelease, installed string, pr printer) ([]UpgradeStep, []Warning) {
+	var result []UpgradeStep
+	moved := make([]int, len(steps))
+	var added []Warning
+	for i, step := range steps {
+		var after *UpgradeStep
+		if step.Type == "Kubernetes" && !satisfies(step.To, current.Kubernetes) {
+			if next, ok := nextNodeRelease(sw.releases, current, step.From, step.To); ok {
+				result = append(result, sw.step(installed, next.Version, sw.before(installed, step.To)))
+				current, installed = next, next.Version
+			} else if next, ok := nextNodeRelease(sw.releases, current, step.To); ok {
+				s := sw.step(installed, next.Version, pr.sprintf("No %s release supports both Kubernetes %s and %s; upgrade the nodes right after the Kubernetes upgrade", sw.name, step.From, step.To))
+				after = &s
+				added = append(added, Warning{
+					Rule:    sw.rule,
+					Step:    len(result),
+					Message: pr.sprintf("The nodes run %s %s on Kubernetes %s, which it does not support, until they are upgraded to %s", sw.name, installed, step.To, next.Version),
+				})
+				current, installed = next, next.Version
+			} else {
+				added = append(added, Warning{
+					Rule:    sw.rule,
+					Step:    len(result),
+					Message: pr.sprintf("No %s release in the compatibility data supports Kubernetes %s", sw.name, step.To),
+				})
+			}
+		}
+		moved[i] = len(result)
+		result = append(result, step)
+		if after != nil {
+			result = append(result, *after)
+		}
+	}
+
+	for i := range warnings {
+		if warnings[i].Step >= 0 {
+			warnings[i].Step = moved[warnings[i].Step]
+		}
+	}
+	return result, append(warnings, added...)
+}
+
+// nextNodeRelease returns the oldest release newer than current supporting
+// every given Kubernetes version
+func nextNodeRelease(releases []nodeRelease, current nodeRelease, k8s ...string) (nodeRelease, bool) {
+	cur, _ := version.NewVersion(current.Version)
+	for _, r := range releases {
+		if rv, _ := version.NewVersion(r.Version); !rv.GreaterThan(cur) {
+			continue
+		}
+		supported := true
+		for _, v := range k8s {
+			supported = supported && satisfies(v, r.Kubernetes)
+		}
+		if supported {
+			return r, true
+		}
+	}
+	return nodeRelease{}, false
+}
@@ -0,0 +1,114 @@
+package planner
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Kinds of normalized versions
+const (
+	KindRancher    = "rancher"
+	KindKubernetes = "kubernetes"
+	KindChannel    = "channel"
+)
+
+// channelNames are the named release channels of Rancher and the Kubernetes
+// distributions, which resolve to a release only at install time
+var channelNames = []string{"stable", "latest", "testing"}
+
+// platformHints match the version suffixes of distributions and hosted
+// providers, e.g. v1.26.8-rancher1-1 for RKE1 or v1.27.9-eks-5e0fdde for EKS
+var platformHints = map[string]*regexp.Regexp{
+	"rke1": regexp.MustCompile(`-rancher\d+-\d+$`),
+	"rke2": regexp.MustCompile(`\+rke2r\d+$`),
+	"k3s":  regexp.MustCompile(`\+k3s\d+$`),
+	"eks":  regexp.MustCompile(`-eks-[0-9a-f]+$`),
+	"gke":  regexp.MustCompile(`-gke\.\d+$`),
+}
+
+// NormalizedVersion is a version string as the planner understands it
+type NormalizedVersion struct {
+	Input string `json:"input"`
+	// Kind is rancher for 2.x versions, kubernetes for 1.x versions, or
+	// channel for named release channels such as stable
+	Kind      string   `json:"kind,omitempty"`
+	Canonical string   `json:"canonical,omitempty"` // e.g. 2.8.5, or v1.27.10+rke2r1
+	Platforms []string `json:"platforms,omitempty"` // Platforms the version's suffix belongs to
+	// Known reports whether the data lists the Rancher version, or lists the
+	// Kubernetes version as a release or within a supported range
+	Known bool   `json:"known"`
+	Error string `json:"error,omitempty"`
+}
+
+// Normalize parses a user supplied version string, with or without a v
+// prefix and distribution suffix, into its canonical form
+func (p *Planner) Normalize(input string) NormalizedVersion {
+	n := NormalizedVersion{Input: input}
+	trimmed := strings.TrimSpace(input)
+	for _, name := range channelNames {
+		if strings.EqualFold(trimmed, name) {
+			n.Kind, n.Canonical = KindChannel, name
+			return n
+		}
+	}
+
+	v, err := parseInputVersion(trimmed)
+	if err != nil {
+		n.Error = err.Error()
+		return n
+	}
+	for name, re := range platformHints {
+		if re.MatchString(trimmed) {
+			n.Platforms = append(n.Platforms, name)
+		}
+	}
+	sort.Strings(n.Platforms)
+
+	switch v.Segments()[0] {
+	case 2:
+		n.Kind = KindRancher
+		n.Canonical = v.String()
+		if known, err := findRancherVersion(p.versions, normalizeVersion(trimmed)); err == nil {
+			n.Canonical, n.Known = known, true
+		}
+	case 1:
+		n.Kind = KindKubernetes
+		n.Canonical = "v" + v.String()
+		n.Known = p.knownK8s(v, n.Platforms)
+	default:
+		n.Error = "not a Rancher (2.x) or Kubernetes (1.x) version"
+	}
+	return n
+}
+
+// knownK8s reports whether the data lists the Kubernetes version as a release
+// or within a supported range, of the given platforms when there are any
+func (p *Planner) knownK8s(v *version.Version, platforms []string) bool {
+	relevant := func(platform string) bool {
+		return len(platforms) == 0 || containsFold(platforms, platform)
+	}
+	for name := range p.paths.Releases {
+		if !relevant(strings.ToLower(name)) {
+			continue
+		}
+		for _, r := range platformReleases(p.paths, name) {
+			if r.Equal(v) {
+				return true
+			}
+		}
+	}
+	for _, r := range p.paths.RancherManager {
+		for _, sp := range r.SupportedPlatforms {
+			if !relevant(strings.ToLower(sp.Platform)) {
+				continue
+			}
+			if minVer, maxVer, ok := platformRange(r, sp.Platform); ok && inRange(v, minVer, maxVer) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package planner
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// RuleOSUnsupported identifies the warnings added when no release of the node
+// operating system in the data supports a Kubernetes version of the plan
+const RuleOSUnsupported = "node-os-unsupported"
+
+// OSRelease is a release of a node operating system and the Kubernetes
+// versions it supports
+type OSRelease struct {
+	Version string `json:"version"` // e.g. 15.5 for SLES 15 SP5
+	// Kubernetes is the range of supported Kubernetes versions in go-version
+	// constraint syntax, e.g. ">= 1.25.0, < 1.30.0"
+	Kubernetes string   `json:"kubernetes"`
+	Platforms  []string `json:"platforms,omitempty"` // Limits the release to these platforms
+}
+
+// osReleases returns the releases of the operating system listed for the
+// platform, sorted ascending
+func osReleases(paths UpgradePaths, os, platform string) []OSRelease {
+	type parsed struct {
+		release OSRelease
+		version *version.Version
+	}
+	var list []parsed
+	for name, releases := range paths.OperatingSystems {
+		if !strings.EqualFold(name, os) {
+			continue
+		}
+		for _, r := range releases {
+			if len(r.Platforms) > 0 && !containsFold(r.Platforms, platform) {
+				continue
+			}
+			if v, err := version.NewVersion(r.Version); err == nil {
+				list = append(list, parsed{r, v})
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].version.LessThan(list[j].version) })
+
+	releases := make([]OSRelease, len(list))
+	for i, p := range list {
+		releases[i] = p.release
+	}
+	return releases
+}
+
+// interleaveOS inserts an OS step before every Kubernetes step the node
+// operating system does not support, upgrading to the oldest release that
+// supports both the running and the target Kubernetes version. When no
+// release supports both, the OS step follows the Kubernetes step and a
+// warning points out the gap.
+func interleaveOS(paths UpgradePaths, platform string, steps []UpgradeStep, warnings []Warning, os, osVersion string, pr printer) ([]UpgradeStep, []Warning) {
+	releases := osReleases(paths, os, platform)
+	if os == "" || osVersion == "" || len(releases) == 0 {
+		return steps, warnings
+	}
+	current, ok := findOSRelease(releases, osVersion)
+	if !ok {
+		warnings = append(warnings, Warning{
+			Rule:    RuleOSUnsupported,
+			Step:    -1,
+			Message: pr.sprintf("%s %s is not in the compatibility data, so OS upgrades are not planned", os, osVersion),
+		})
+		return steps, warnings
+	}
+
+	sw := nodeSoftware{
+		name: os,
+		rule: RuleOSUnsupported,
+		step: func(from, to, note string) UpgradeStep {
+			return UpgradeStep{Type: "OS", Platform: os, From: from, To: to, Notes: []string{note}}
+		},
+		before: func(running, k8s string) string {
+			return pr.sprintf("%s %s does not support Kubernetes %s; upgrade the nodes before this Kubernetes upgrade", os, running, k8s)
+		},
+	}
+	for _, r := range releases {
+		sw.releases = append(sw.releases, nodeRelease{Version: r.Version, Kubernetes: r.Kubernetes})
+	}
+	return interleaveNodeSteps(steps, warnings, sw, nodeRelease{Version: current.Version, Kubernetes: current.Kubernetes}, current.Version, pr)
+}
+
+// findOSRelease returns the release of the given version
+func findOSRelease(releases []OSRelease, v string) (OSRelease, bool) {
+	want, err := version.NewVersion(v)
+	if err != nil {
+		return OSRelease{}, false
+	}
+	for _, r := range releases {
+		if rv, err := version.NewVersion(r.Version); err == nil && rv.Equal(want) {
+			return r, true
+		}
+	}
+	return OSRelease{}, false
+}
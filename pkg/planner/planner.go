@@ -0,0 +1,598 @@
+// Package planner builds Rancher and Kubernetes upgrade plans from the
+// compatibility data in UpgradePaths. It is used by the upgrade tool's HTTP
+// service and can be embedded by other Go tools that need the same plans.
+//
+//	p := planner.New(paths, planner.Options{})
+//	plan, err := p.Plan(planner.Request{
+//		Platform:       "rke2",
+//		CurrentRancher: "2.7.5",
+//		CurrentK8s:     "v1.24.9",
+//	})
+package planner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Options configures a Planner.
+type Options struct {
+	// Logger receives diagnostics such as unparsable versions in the data.
+	// Nil disables logging.
+	Logger *log.Logger
+
+	// Prereleases and Hotfixes control how prerelease (2.9.0-rc1) and hotfix
+	// (2.7.5-hotfix-1a2b.1) Rancher versions in the data are planned with.
+	// Empty selects PolicyOnlyIfCurrent.
+	Prereleases VersionPolicy
+	Hotfixes    VersionPolicy
+
+	// Aliases map additional platform names to platforms, e.g. "custom-rke"
+	// to rke2. They take precedence over aliases registered with RegisterAlias.
+	Aliases map[string]string
+
+	// Effort weights the steps of a plan to estimate its engineer-hours.
+	// The zero value leaves plans without an estimate.
+	Effort EffortModel
+
+	// Policy limits the versions plans upgrade to and may force a strategy.
+	// Nil follows upstream support only.
+	Policy *Policy
+
+	// Blackouts are the periods requests with a planned date may not fall in
+	Blackouts []Blackout
+
+	// Today is the date requests without a planned date are planned on, such
+	// as the date a replayed request was recorded. Zero is the current date.
+	Today time.Time
+}
+
+// Request describes the cluster an upgrade plan is generated for.
+type Request struct {
+	Platform       string `json:"platform"`        // Kubernetes distribution, e.g. rke1, rke2, k3s
+	CurrentRancher string `json:"current_rancher"` // Running Rancher version, e.g. 2.7.5
+	CurrentK8s     string `json:"current_k8s"`     // Running Kubernetes version, e.g. v1.24.9
+
+	// Facts are versions of other cluster components, such as "docker",
+	// checked against the requirements of constraints in the data.
+	Facts map[string]string `json:"facts,omitempty"`
+
+	// PlannedDate is the date the plan is executed on, as YYYY-MM-DD, used to
+	// find the support phase of Rancher versions. Empty means today.
+	PlannedDate string `json:"planned_date,omitempty"`
+
+	// LTSS is set for customers with a long-term service pack support
+	// contract, whose Rancher versions stay supported after maintenance ends
+	LTSS bool `json:"ltss,omitempty"`
+
+	// CertificateExpiry is the date the cluster's certificates expire, as
+	// YYYY-MM-DD. Plans warn to rotate certificates that expire soon first.
+	CertificateExpiry string `json:"certificate_expiry,omitempty"`
+
+	// ManagementCluster describes the local cluster Rancher runs on, checked
+	// against the requirements of every Rancher step's target
+	ManagementCluster *ManagementCluster `json:"management_cluster,omitempty"`
+
+	// NodeOS and NodeOSVersion are the operating system of the cluster's
+	// nodes, e.g. sles and 15.4, used to interleave OS upgrades with the plan
+	NodeOS        string `json:"node_os,omitempty"`
+	NodeOSVersion string `json:"node_os_version,omitempty"`
+
+	// DockerVersion is the Docker version of the nodes of an RKE1 cluster,
+	// e.g. 20.10.21, used to plan Docker upgrades before the Kubernetes steps
+	// that need them. It is the docker fact unless the facts give one.
+	DockerVersion string `json:"docker_version,omitempty"`
+
+	// AuthProvider is the Rancher auth provider the cluster's users log in
+	// with, e.g. azuread, matched against the auth_providers of constraints
+	AuthProvider string `json:"auth_provider,omitempty"`
+
+	// Features lists the Rancher features the cluster relies on, such as
+	// legacy-monitoring, matched against the features of constraints
+	Features []string `json:"features,omitempty"`
+
+	// Extensions maps the UI extensions installed in Rancher to the range of
+	// extensions API versions each supports, as declared in its
+	// catalog.cattle.io/ui-extensions-version annotation, e.g. ">= 1.1.0 < 3.0.0"
+	Extensions map[string]string `json:"extensions,omitempty"`
+
+	// TargetRancher is the Rancher version the plan stops at, e.g. 2.8.5.
+	// Empty plans up to the newest version supporting the platform.
+	TargetRancher string `json:"target_rancher,omitempty"`
+
+	// AllowPrerelease plans with prerelease Rancher versions such as
+	// 2.9.0-rc1 like any other release, so they may be checkpoints and the
+	// target, unless the planner's prerelease policy is PolicyExclude
+	AllowPrerelease bool `json:"allow_prerelease,omitempty"`
+
+	// RancherHops selects the Rancher version each hop lands on: HopsLatestPatch,
+	// the default when empty, or HopsMinorOnly.
+	RancherHops string `json:"rancher_hops,omitempty"`
+
+	// MigrateTo names the platform the cluster is migrated to, e.g. rke2 for
+	// an rke1 cluster. The plan upgrades the cluster as far as its platform
+	// allows, migrates it with a Migration step, and continues on the new
+	// platform. Empty plans on the cluster's platform only.
+	MigrateTo string `json:"migrate_to,omitempty"`
+
+	// Strategy names the registered Strategy used to select steps.
+	// Empty uses DefaultStrategy.
+	Strategy string `json:"strategy,omitempty"`
+
+	// AlwaysSupported requires the cluster to stay within a supported
+	// Rancher and Kubernetes combination at every step. When the strategy's
+	// path leaves it, the shortest-path strategy is used instead, and the
+	// plan fails when no such path exists.
+	AlwaysSupported bool `json:"always_supported,omitempty"`
+
+	// Language selects the language of notes and warnings. It accepts a
+	// language such as "de" or an Accept-Language list and falls back to
+	// DefaultLanguage; see NegotiateLanguage.
+	Language string `json:"language,omitempty"`
+
+	// Nodes is the number of nodes in the cluster, used to scale the effort
+	// estimate of Kubernetes steps. Zero means unknown.
+	Nodes int `json:"nodes,omitempty"`
+}
+
+// Planner generates upgrade plans against a fixed set of compatibility data.
+// It is safe for concurrent use.
+type Planner struct {
+	paths       UpgradePaths
+	versions    []string
+	candidates  []string // Versions the prerelease and hotfix policies allow as checkpoints
+	checkpoints []string
+	opts        Options
+	aliases     map[string]string
+	hash        string
+
+	graphMu sync.Mutex
+	graphs  map[string]*Graph
+}
+
+// New creates a Planner for the given compatibility data.
+func New(paths UpgradePaths, opts Options) *Planner {
+	p := &Planner{
+		paths:  paths,
+		opts:   opts,
+		graphs: make(map[string]*Graph),
+	}
+	if len(opts.Aliases) > 0 {
+		p.aliases = make(map[string]string, len(opts.Aliases))
+		for alias, platform := range opts.Aliases {
+			p.aliases[aliasKey(alias)] = strings.ToLower(strings.TrimSpace(platform))
+		}
+	}
+	// The hash identifies the published data, not the data as the policy limits it
+	p.hash = datasetHash(paths)
+	paths = opts.Policy.apply(paths)
+	p.paths = paths
+	p.versions = sortedVersions(paths, p.logf)
+	p.candidates = checkpointCandidates(p.versions, opts)
+	p.checkpoints = selectCheckpoints(p.candidates, paths)
+	return p
+}
+
+// Versions returns every Rancher version in the data, sorted ascending.
+func (p *Planner) Versions() []string {
+	return append([]string(nil), p.versions...)
+}
+
+// Plan generates the upgrade plan for the cluster described by req and
+// checks it against the constraints in the data. A matching blocking
+// constraint is returned as a *ConstraintError.
+func (p *Planner) Plan(req Request) (*Plan, error) {
+	return p.PlanContext(context.Background(), req)
+}
+
+// PlanContext is Plan, stopping early with an error wrapping the context's
+// error once ctx is done
+func (p *Planner) PlanContext(ctx context.Context, req Request) (*Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("planning canceled: %w", err)
+	}
+	if req.MigrateTo != "" {
+		return p.planMigration(ctx, req)
+	}
+
+	strategyName := req.Strategy
+	if p.opts.Policy != nil && p.opts.Policy.Strategy != "" {
+		strategyName = p.opts.Policy.Strategy
+	}
+	if strategyName == "" {
+		strategyName = DefaultStrategy
+	}
+	strategy, ok := LookupStrategy(strategyName)
+	if !ok {
+		return nil, classify(CodeInvalidRequest, fmt.Errorf("unknown strategy %q, expected one of: %s", req.Strategy, strings.Join(RegisteredStrategies(), ", ")))
+	}
+	opts, candidates, keyVersions, err := p.releaseKinds(req.AllowPrerelease)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := parseInputVersion(req.CurrentRancher); err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Rancher version: %v", err))
+	}
+	k8sVer, err := parseInputVersion(req.CurrentK8s)
+	if err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+
+	// Rancher versions are keys in the data, so plan from the matching key
+	currentRancher, err := findRancherVersion(p.versions, normalizeVersion(req.CurrentRancher))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCurrentPolicy(currentRancher, opts); err != nil {
+		return nil, err
+	}
+	var targetRancher string
+	if req.TargetRancher != "" {
+		if _, err := parseInputVersion(req.TargetRancher); err != nil {
+			return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid target Rancher version: %v", err))
+		}
+		if targetRancher, err = findRancherVersion(p.versions, normalizeVersion(req.TargetRancher)); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkRancherHops(req.RancherHops); err != nil {
+		return nil, err
+	}
+	currentK8s := strings.TrimSpace(req.CurrentK8s)
+	if err := checkExtensions(req.Extensions); err != nil {
+		return nil, err
+	}
+	dockerVersion := strings.TrimSpace(req.DockerVersion)
+	if err := checkDockerVersion(dockerVersion); err != nil {
+		return nil, err
+	}
+	if dockerVersion != "" && req.Facts[DockerFact] == "" {
+		req.Facts = withFact(req.Facts, DockerFact, dockerVersion)
+	}
+	if local := req.ManagementCluster; local != nil {
+		if _, err := parseInputVersion(local.K8s); err != nil {
+			return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid management cluster Kubernetes version: %v", err))
+		}
+	}
+	planned, err := plannedDate(req.PlannedDate, p.opts.Today)
+	if err != nil {
+		return nil, err
+	}
+	if req.PlannedDate != "" {
+		if err := checkBlackouts(p.opts.Blackouts, planned); err != nil {
+			return nil, err
+		}
+	}
+	var certExpiry time.Time
+	if req.CertificateExpiry != "" {
+		if certExpiry, err = time.Parse(dateLayout, req.CertificateExpiry); err != nil {
+			return nil, classify(CodeInvalidRequest, fmt.Errorf("invalid certificate expiry %q, expected YYYY-MM-DD", req.CertificateExpiry))
+		}
+	}
+
+	platform := canonicalPlatform(req.Platform, p.aliases)
+	if err := checkPlatform(p.paths, platform, relevantVersions(currentRancher, keyVersions)); err != nil {
+		return nil, err
+	}
+	if err := checkReleaseSuffix(platform, k8sVer); err != nil {
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+
+	pr := printer{lang: NegotiateLanguage(req.Language)}
+	checkpoints, eolWarning := supportedCheckpoints(p.paths, platform, currentRancher, keyVersions, candidates, pr)
+	if targetRancher != "" {
+		if checkpoints, err = targetCheckpoints(p.paths, platform, currentRancher, targetRancher, checkpoints, candidates); err != nil {
+			return nil, err
+		}
+		// The plan stops at the target, not where support for the platform ends
+		eolWarning = nil
+	}
+	if req.RancherHops == HopsMinorOnly {
+		checkpoints = minorOnlyCheckpoints(p.paths, currentRancher, targetRancher, checkpoints)
+	}
+
+	graph := p.graph(platform)
+	if err := checkK8sAhead(graph, k8sVer); err != nil {
+		return nil, err
+	}
+	input := PlanInput{
+		Platform:       platform,
+		Rules:          localized(rulesFor(platform), pr),
+		CurrentRancher: currentRancher,
+		CurrentK8s:     currentK8s,
+		Checkpoints:    checkpoints,
+		Paths:          p.paths,
+		Graph:          graph,
+		Context:        ctx,
+	}
+	steps, err := strategy.Steps(input)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("planning canceled: %w", ctxErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var supportedWarning *Warning
+	if req.AlwaysSupported {
+		if steps, strategy, supportedWarning, err = alwaysSupported(strategy, input, steps, pr); err != nil {
+			return nil, err
+		}
+	}
+	// The warning points at a step, so it moves along with the Docker steps
+	var warnings []Warning
+	if supportedWarning != nil {
+		warnings = append(warnings, *supportedWarning)
+	}
+	steps, warnings = interleaveDocker(p.paths, platform, steps, warnings, dockerVersion, pr)
+	constraintWarnings, err := evaluateConstraints(p.paths.Constraints, platform, currentRancher, currentK8s, steps, req, pr)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, constraintWarnings...)
+	if err := checkHops(graph, currentK8s, steps); err != nil {
+		return nil, err
+	}
+	if err := checkPublished(p.paths, steps); err != nil {
+		return nil, err
+	}
+
+	if eolWarning != nil {
+		eolWarning.Step = len(steps) - 1
+		warnings = append(warnings, *eolWarning)
+	}
+	warnings = append(warnings, extensionWarnings(p.paths, steps, req.Extensions, pr)...)
+	warnings = append(warnings, managementWarnings(p.paths, steps, req.ManagementCluster, p.aliases, pr)...)
+	annotateReleaseKinds(steps, opts, pr)
+	annotateComponents(steps, p.paths, pr)
+	annotateAgents(steps, pr)
+	if w := annotateSupportPhases(steps, p.paths, planned, req.LTSS, pr); w != nil {
+		warnings = append(warnings, *w)
+	}
+	steps, warnings = interleaveOS(p.paths, platform, steps, warnings, req.NodeOS, req.NodeOSVersion, pr)
+	steps, warnings = interleaveCertManager(p.paths, steps, warnings, req.Facts[CertManagerFact], pr)
+	if w := certificateWarning(certExpiry, planned, pr); w != nil && len(steps) > 0 {
+		warnings = append(warnings, *w)
+	}
+	linkSteps(graph, steps)
+
+	plan := &Plan{
+		Platform:  platform,
+		Steps:     steps,
+		Warnings:  warnings,
+		Preflight: preflightChecks(platform, pr),
+		Effort:    p.opts.Effort.estimate(steps, req.Nodes),
+		Meta:      p.meta(strategy.Name(), pr.lang, opts),
+	}
+	if req.PlannedDate != "" {
+		plan.Projected = projectReleases(p.paths, planned)
+		if w := projectionWarning(plan.Projected, planned, pr); w != nil {
+			plan.Warnings = append(plan.Warnings, *w)
+		}
+	}
+	if len(p.paths.Advisories) > 0 {
+		plan.Security = securityDelta(p.paths.Advisories, platform, currentRancher, currentK8s, steps)
+	}
+	plan.canonicalize()
+	return plan, nil
+}
+
+// graph returns the compatibility graph for the platform, building it on first use
+func (p *Planner) graph(platform string) *Graph {
+	p.graphMu.Lock()
+	defer p.graphMu.Unlock()
+
+	g, ok := p.graphs[platform]
+	if !ok {
+		g = NewGraph(platform, p.versions, p.paths)
+		p.graphs[platform] = g
+	}
+	return g
+}
+
+// logf writes to the configured logger, if any
+func (p *Planner) logf(format string, args ...interface{}) {
+	if p.opts.Logger != nil {
+		p.opts.Logger.Printf(format, args...)
+	}
+}
+
+// PlanUpgrade generates the Rancher + Kubernetes upgrade plan. Its errors are
+// classified like those of Plan, see ErrorCode.
+func PlanUpgrade(currentRancher, currentK8s, platform string, versions []string, paths UpgradePaths) ([]UpgradeStep, error) {
+	currentRancher, err := findRancherVersion(versions, currentRancher)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCurrentPolicy(currentRancher, Options{}); err != nil {
+		return nil, err
+	}
+	platform = CanonicalPlatform(platform)
+	keyVersions := selectCheckpoints(checkpointCandidates(versions, Options{}), paths)
+	if err := checkPlatform(paths, platform, relevantVersions(currentRancher, keyVersions)); err != nil {
+		return nil, err
+	}
+	graph := NewGraph(platform, versions, paths)
+	k8s, err := parseK8sVersion(currentK8s)
+	if err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+	if err := checkK8sAhead(graph, k8s); err != nil {
+		return nil, err
+	}
+
+	steps, err := planThroughCheckpoints(currentRancher, currentK8s, strings.ToLower(platform), keyVersions, paths, rulesFor(platform))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPublished(paths, steps); err != nil {
+		return nil, err
+	}
+	if err := checkHops(graph, currentK8s, steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// planThroughCheckpoints hops through every checkpoint newer than the current
+// Rancher version, upgrading Kubernetes as far as the rules allow after each hop.
+// When a checkpoint does not support the running Kubernetes version, Kubernetes
+// is upgraded on the current Rancher version before the hop.
+func planThroughCheckpoints(currentRancher, currentK8s, platform string, keyVersions []string, paths UpgradePaths, rules PlatformRules) ([]UpgradeStep, error) {
+	var upgradeSteps []UpgradeStep
+
+	currentRancherVersion, err := version.NewVersion(currentRancher)
+	if err != nil {
+		return nil, fmt.Errorf("invalid current Rancher version: %v", err)
+	}
+	releases := platformReleases(paths, platform)
+
+	for _, v := range keyVersions {
+		nextVersion, err := version.NewVersion(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in key versions: %v", err)
+		}
+
+		if nextVersion.GreaterThan(currentRancherVersion) {
+			r1 := paths.RancherManager[currentRancher]
+			r2 := paths.RancherManager[v]
+
+			// Upgrade Kubernetes on the current Rancher version first when the
+			// next one no longer supports the running Kubernetes version
+			if minVer, maxVer, ok := platformRange(r2, platform); ok {
+				supported := func(k8s *version.Version) bool { return inRange(k8s, minVer, maxVer) }
+				if k8s, err := parseK8sVersion(currentK8s); err == nil && !supported(k8s) {
+					candidates := getSortedK8sVersions(platform, r1, r1, releases)
+					for _, upgrade := range upgradeK8s(currentK8s, platform, candidates, rules, supported) {
+						upgradeSteps = append(upgradeSteps, upgrade)
+						currentK8s = upgrade.To
+					}
+				}
+			}
+
+			// Add Rancher upgrade step
+			upgradeSteps = append(upgradeSteps, UpgradeStep{
+				Type: "Rancher", From: currentRancher, To: v,
+			})
+
+			// Get Kubernetes upgrades for this Rancher version
+			k8sUpgrades := allowedK8sUpgrades(currentK8s, platform, r1, r2, rules, releases)
+
+			// Add Kubernetes upgrade steps
+			for _, upgrade := range k8sUpgrades {
+				upgradeSteps = append(upgradeSteps, upgrade)
+				currentK8s = upgrade.To // Update current Kubernetes version
+			}
+
+			currentRancher = v                  // Update current Rancher version
+			currentRancherVersion = nextVersion // Update current Rancher version object
+		}
+	}
+
+	// Without a newer checkpoint, such as at the target or once a resumed
+	// plan made its last hop, Kubernetes still goes as far as the current
+	// Rancher version supports
+	if len(upgradeSteps) == 0 {
+		r := paths.RancherManager[currentRancher]
+		upgradeSteps = allowedK8sUpgrades(currentK8s, platform, r, r, rules, releases)
+	}
+
+	return upgradeSteps, nil
+}
+
+// GetKeyVersions returns the key Rancher versions for the upgrade plan: the
+// highest patch of every minor, sorted ascending. Versions are not filtered by
+// kind; callers drop prerelease and hotfix versions their policy excludes
+// before calling it.
+func GetKeyVersions(versions []string) []string {
+	highest := make(map[[2]int]*version.Version)
+	for _, v := range versions {
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		s := ver.Segments()
+		minor := [2]int{s[0], s[1]}
+		if cur, ok := highest[minor]; !ok || ver.GreaterThan(cur) {
+			highest[minor] = ver
+		}
+	}
+
+	keyVersions := make([]*version.Version, 0, len(highest))
+	for _, v := range highest {
+		keyVersions = append(keyVersions, v)
+	}
+	sort.Stable(version.Collection(keyVersions))
+
+	// Convert back to string slices
+	sortedKeyVersions := make([]string, len(keyVersions))
+	for i, v := range keyVersions {
+		sortedKeyVersions[i] = v.String()
+	}
+
+	return sortedKeyVersions
+}
+
+// selectCheckpoints returns the key versions plus every version the data
+// flags as a mandatory waypoint, sorted ascending
+func selectCheckpoints(versions []string, paths UpgradePaths) []string {
+	checkpoints := GetKeyVersions(versions)
+	selected := make(map[string]bool, len(checkpoints))
+	for _, v := range checkpoints {
+		selected[v] = true
+	}
+
+	var all []*version.Version
+	for _, v := range versions {
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if selected[ver.String()] || paths.RancherManager[v].Waypoint {
+			all = append(all, ver)
+		}
+	}
+	sort.Stable(version.Collection(all))
+
+	result := make([]string, len(all))
+	for i, v := range all {
+		result[i] = v.String()
+	}
+	return result
+}
+
+// sortedVersions returns the Rancher versions in the data sorted using
+// semantic versioning, reporting versions that cannot be parsed
+func sortedVersions(paths UpgradePaths, logf func(format string, args ...interface{})) []string {
+	// Visit the keys in a fixed order so equal versions always sort the same way
+	keys := make([]string, 0, len(paths.RancherManager))
+	for v := range paths.RancherManager {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+
+	parsedVersions := make([]*version.Version, 0, len(keys))
+	for _, v := range keys {
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			logf("Skipping Rancher version '%s' in upgrade paths: %v", v, err)
+			continue
+		}
+		parsedVersions = append(parsedVersions, ver)
+	}
+	sort.Stable(version.Collection(parsedVersions))
+
+	// Convert back to string slices
+	sorted := make([]string, len(parsedVersions))
+	for i, v := range parsedVersions {
+		sorted[i] = v.String()
+	}
+	return sorted
+}
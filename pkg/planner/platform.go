@@ -0,0 +1,182 @@
+package planner
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-version"
+)
+
+// SkipPolicy describes how far a single Kubernetes upgrade step may go.
+type SkipPolicy struct {
+	// MaxMinors is the number of minor versions a single step may advance.
+	MaxMinors int
+	// Stepwise takes the next available version instead of the furthest allowed one.
+	Stepwise bool
+}
+
+// PlatformRules captures the planning behavior that differs between
+// Kubernetes distributions. Implementations are registered with Register
+// and looked up by the platform name submitted with a request.
+type PlatformRules interface {
+	// Name returns the lowercase platform name as used in the compatibility data.
+	Name() string
+	// SkipPolicy returns how Kubernetes minors may be skipped on this platform.
+	SkipPolicy() SkipPolicy
+	// FormatVersion renders a Kubernetes version as shown in upgrade steps.
+	FormatVersion(v *version.Version) string
+	// Annotate adds platform specific guidance to a Kubernetes upgrade step.
+	Annotate(step *UpgradeStep)
+}
+
+var (
+	rulesMu  sync.RWMutex
+	registry = make(map[string]PlatformRules)
+	aliases  = make(map[string]string)
+)
+
+// Register makes the rules available under their name, replacing any rules
+// previously registered for the same platform.
+func Register(r PlatformRules) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	registry[strings.ToLower(r.Name())] = r
+}
+
+// Lookup returns the rules registered for the platform, ignoring case.
+func Lookup(platform string) (PlatformRules, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	r, ok := registry[strings.ToLower(platform)]
+	return r, ok
+}
+
+// RegisteredPlatforms returns the names of all registered platforms, sorted.
+func RegisteredPlatforms() []string {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterAlias makes an alternative name resolve to the platform, e.g.
+// "RKE" to rke1. Aliases are matched ignoring case, spaces, hyphens, and
+// underscores.
+func RegisterAlias(alias, platform string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	aliases[aliasKey(alias)] = strings.ToLower(strings.TrimSpace(platform))
+}
+
+// CanonicalPlatform resolves a submitted platform name to the name used in the
+// compatibility data. Names that are neither registered nor aliased are
+// returned lowercased and trimmed.
+func CanonicalPlatform(name string) string {
+	return canonicalPlatform(name, nil)
+}
+
+// canonicalPlatform resolves the name using the extra aliases, keyed by
+// aliasKey, before the registered ones
+func canonicalPlatform(name string, extra map[string]string) string {
+	key := aliasKey(name)
+	if platform, ok := extra[key]; ok {
+		return platform
+	}
+
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	if platform, ok := aliases[key]; ok {
+		return platform
+	}
+	if _, ok := registry[key]; ok {
+		return key
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// aliasKey folds case and drops the separators users put in platform names
+func aliasKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// rulesFor returns the registered rules for the platform, falling back to
+// conservative defaults for platforms without their own rules
+func rulesFor(platform string) PlatformRules {
+	if r, ok := Lookup(platform); ok {
+		return r
+	}
+	return BaseRules{PlatformName: strings.ToLower(platform), Skip: SkipPolicy{MaxMinors: 1, Stepwise: true}}
+}
+
+// BaseRules is a configurable PlatformRules implementation that other rules
+// can embed to override individual behaviors.
+type BaseRules struct {
+	PlatformName string
+	Skip         SkipPolicy
+	Notes        []string // Added to every Kubernetes step
+}
+
+// Name returns the platform name
+func (b BaseRules) Name() string { return b.PlatformName }
+
+// SkipPolicy returns the configured skip policy
+func (b BaseRules) SkipPolicy() SkipPolicy { return b.Skip }
+
+// FormatVersion renders the version with a "v" prefix
+func (b BaseRules) FormatVersion(v *version.Version) string { return "v" + v.Original() }
+
+// Annotate appends the configured notes to the step
+func (b BaseRules) Annotate(step *UpgradeStep) {
+	step.Notes = append(step.Notes, b.Notes...)
+}
+
+// Rancher-provisioned distributions may skip one minor per upgrade; hosted
+// providers only upgrade one minor at a time and manage the control plane.
+func init() {
+	for _, name := range []string{"rke1", "rke2", "k3s"} {
+		Register(BaseRules{PlatformName: name, Skip: SkipPolicy{MaxMinors: 2}})
+	}
+
+	hosted := map[string]string{
+		"aks": "AKS",
+		"eks": "EKS",
+		"gke": "GKE",
+	}
+	for name, provider := range hosted {
+		Register(BaseRules{
+			PlatformName: name,
+			Skip:         SkipPolicy{MaxMinors: 1, Stepwise: true},
+			Notes: []string{
+				"The control plane is upgraded by " + provider + "; upgrade node pools after the control plane completes",
+			},
+		})
+	}
+
+	for alias, platform := range map[string]string{
+		"rke":                         "rke1",
+		"rancher kubernetes engine":   "rke1",
+		"rancher kubernetes engine 1": "rke1",
+		"rancher kubernetes engine 2": "rke2",
+		"rke government":              "rke2",
+		"rkegov":                      "rke2",
+		"k3os":                        "k3s",
+		"azure kubernetes service":    "aks",
+		"amazon eks":                  "eks",
+		"elastic kubernetes service":  "eks",
+		"google kubernetes engine":    "gke",
+	} {
+		RegisterAlias(alias, platform)
+	}
+}
@@ -0,0 +1,112 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Policy holds an organization's upgrade standards, stricter than upstream
+// support. It only limits the versions plans upgrade to; clusters already
+// past a limit are still planned from their current versions.
+type Policy struct {
+	// MaxRancher is the newest Rancher version plans may upgrade to
+	MaxRancher string `json:"max_rancher,omitempty"`
+	// MaxKubernetes is the newest Kubernetes version plans may upgrade to; a
+	// minor such as v1.28 allows all of its patches
+	MaxKubernetes string `json:"max_kubernetes,omitempty"`
+	// Banned lists Rancher versions and Kubernetes releases plans never
+	// upgrade to, e.g. 2.8.3 or v1.28.9+rke2r1
+	Banned []string `json:"banned,omitempty"`
+	// Strategy is the strategy of every plan, whatever the request asks for
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// Validate checks that the policy's versions parse and its strategy exists
+func (pol *Policy) Validate() error {
+	if pol == nil {
+		return nil
+	}
+	for _, v := range append([]string{pol.MaxRancher, pol.MaxKubernetes}, pol.Banned...) {
+		if v == "" {
+			continue
+		}
+		if _, err := version.NewVersion(normalizeVersion(v)); err != nil {
+			return fmt.Errorf("invalid version %q in policy: %v", v, err)
+		}
+	}
+	if pol.Strategy != "" {
+		if _, ok := LookupStrategy(pol.Strategy); !ok {
+			return fmt.Errorf("unknown strategy %q in policy, expected one of: %s", pol.Strategy, strings.Join(RegisteredStrategies(), ", "))
+		}
+	}
+	return nil
+}
+
+// banned reports whether the policy bans the version
+func (pol *Policy) banned(v *version.Version) bool {
+	for _, b := range pol.Banned {
+		if bv, err := version.NewVersion(normalizeVersion(b)); err == nil && bv.Equal(v) && bv.Metadata() == v.Metadata() {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRancher reports whether plans may upgrade to the Rancher version
+func (pol *Policy) allowsRancher(v *version.Version) bool {
+	if pol == nil {
+		return true
+	}
+	if pol.MaxRancher != "" {
+		if maxVer, err := version.NewVersion(normalizeVersion(pol.MaxRancher)); err == nil && v.GreaterThan(maxVer) {
+			return false
+		}
+	}
+	return !pol.banned(v)
+}
+
+// apply returns the data with the Kubernetes limits of the policy applied:
+// supported ranges end at MaxKubernetes, and banned releases are dropped
+func (pol *Policy) apply(paths UpgradePaths) UpgradePaths {
+	if pol == nil {
+		return paths
+	}
+
+	if len(pol.Banned) > 0 && len(paths.Releases) > 0 {
+		releases := make(map[string][]string, len(paths.Releases))
+		for platform, list := range paths.Releases {
+			for _, r := range list {
+				if v, err := version.NewVersion(cleanVersion(r)); err != nil || !pol.banned(v) {
+					releases[platform] = append(releases[platform], r)
+				}
+			}
+		}
+		paths.Releases = releases
+	}
+
+	limit, err := version.NewVersion(normalizeVersion(pol.MaxKubernetes))
+	if pol.MaxKubernetes == "" || err != nil {
+		return paths
+	}
+	rancher := make(map[string]RancherManagerVersion, len(paths.RancherManager))
+	for v, r := range paths.RancherManager {
+		platforms := make([]Platform, 0, len(r.SupportedPlatforms))
+		for _, p := range r.SupportedPlatforms {
+			minVer, minErr := version.NewVersion(cleanVersion(p.MinVersion))
+			maxVer, maxErr := version.NewVersion(cleanVersion(p.MaxVersion))
+			if minErr == nil && maxErr == nil && maxVer.GreaterThan(limit) && !sameMinorBound(maxVer, limit) {
+				if minVer.GreaterThan(limit) && !sameMinorBound(minVer, limit) {
+					continue
+				}
+				p.MaxVersion = pol.MaxKubernetes
+			}
+			platforms = append(platforms, p)
+		}
+		r.SupportedPlatforms = platforms
+		rancher[v] = r
+	}
+	paths.RancherManager = rancher
+	return paths
+}
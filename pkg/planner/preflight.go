@@ -0,0 +1,84 @@
+package planner
+
+import "time"
+
+// RuleCertificateExpiry identifies the warning added when the cluster's
+// certificates expire soon after the planned date
+const RuleCertificateExpiry = "certificate-expiry"
+
+// certificateExpiryWindow is how close to the planned date certificates may
+// expire before the plan asks to rotate them first. RKE2 and K3s renew
+// certificates within this window when their service restarts.
+const certificateExpiryWindow = 90 * 24 * time.Hour
+
+// PreflightCheck is a check to run before the first step of a plan
+type PreflightCheck struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Commands    []string `json:"commands,omitempty"`
+}
+
+// rancherCertificateCheck checks the certificate Rancher serves its UI and
+// API with; it is the same on every platform
+var rancherCertificateCheck = PreflightCheck{
+	ID:          "rancher-certificates",
+	Description: "Check the expiry of the certificate Rancher serves; renew it, or let cert-manager renew it, before upgrading if it expires soon",
+	Commands: []string{
+		`kubectl -n cattle-system get secret tls-rancher-ingress -o jsonpath='{.data.tls\.crt}' | base64 -d | openssl x509 -noout -enddate`,
+	},
+}
+
+// certificateChecks are the certificate checks and rotation commands of the
+// Rancher-provisioned platforms; hosted providers manage their certificates
+var certificateChecks = map[string]PreflightCheck{
+	"rke1": {
+		ID:          "cluster-certificates",
+		Description: "Check the expiry of the RKE cluster certificates and rotate them with RKE before upgrading if they expire soon",
+		Commands: []string{
+			"openssl x509 -noout -enddate -in /etc/kubernetes/ssl/kube-apiserver.pem",
+			"rke cert rotate --config cluster.yml",
+		},
+	},
+	"rke2": {
+		ID:          "cluster-certificates",
+		Description: "Check the expiry of the RKE2 certificates on every server node and rotate them before upgrading if they expire soon; restarting rke2-server also renews certificates that expire within 90 days",
+		Commands: []string{
+			"openssl x509 -noout -enddate -in /var/lib/rancher/rke2/server/tls/serving-kube-apiserver.crt",
+			"systemctl stop rke2-server && rke2 certificate rotate && systemctl start rke2-server",
+		},
+	},
+	"k3s": {
+		ID:          "cluster-certificates",
+		Description: "Check the expiry of the K3s certificates on every server node and rotate them before upgrading if they expire soon; restarting k3s also renews certificates that expire within 90 days",
+		Commands: []string{
+			"openssl x509 -noout -enddate -in /var/lib/rancher/k3s/server/tls/serving-kube-apiserver.crt",
+			"systemctl stop k3s && k3s certificate rotate && systemctl start k3s",
+		},
+	},
+}
+
+// preflightChecks returns the checks to run before a plan on the platform
+func preflightChecks(platform string, pr printer) []PreflightCheck {
+	var checks []PreflightCheck
+	if c, ok := certificateChecks[platform]; ok {
+		checks = append(checks, c)
+	}
+	checks = append(checks, rancherCertificateCheck)
+	for i := range checks {
+		checks[i].Description = pr.text(checks[i].Description)
+	}
+	return checks
+}
+
+// certificateWarning warns on the first step when the certificates expire
+// within certificateExpiryWindow of the planned date
+func certificateWarning(expiry time.Time, planned time.Time, pr printer) *Warning {
+	if expiry.IsZero() || expiry.Sub(planned) > certificateExpiryWindow {
+		return nil
+	}
+	return &Warning{
+		Rule:    RuleCertificateExpiry,
+		Step:    0,
+		Message: pr.sprintf("The cluster certificates expire on %s, within 90 days of the planned date %s; rotate them before the first step", expiry.Format(dateLayout), planned.Format(dateLayout)),
+	}
+}
@@ -0,0 +1,168 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// VersionPolicy controls how prerelease and hotfix Rancher versions in the
+// data take part in planning.
+type VersionPolicy string
+
+const (
+	// PolicyExclude rejects plans from such versions and never uses them as checkpoints.
+	PolicyExclude VersionPolicy = "exclude"
+	// PolicyInclude treats such versions like any other release.
+	PolicyInclude VersionPolicy = "include"
+	// PolicyOnlyIfCurrent plans from such versions but never upgrades to them.
+	// Prerelease versions are only planned from for requests allowing
+	// prereleases, and rejected otherwise.
+	PolicyOnlyIfCurrent VersionPolicy = "only-if-current"
+)
+
+// ParseVersionPolicy returns the policy with the given name; empty selects
+// PolicyOnlyIfCurrent
+func ParseVersionPolicy(s string) (VersionPolicy, error) {
+	switch p := VersionPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyOnlyIfCurrent, nil
+	case PolicyExclude, PolicyInclude, PolicyOnlyIfCurrent:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown version policy %q, expected one of: %s, %s, %s", s, PolicyExclude, PolicyInclude, PolicyOnlyIfCurrent)
+}
+
+// releaseKind classifies a Rancher version as a release, a prerelease such as
+// 2.9.0-rc1, or a hotfix build such as 2.7.5-hotfix-1a2b.1
+type releaseKind int
+
+const (
+	kindRelease releaseKind = iota
+	kindPrerelease
+	kindHotfix
+)
+
+// String returns the name used in step notes
+func (k releaseKind) String() string {
+	switch k {
+	case kindPrerelease:
+		return "prerelease"
+	case kindHotfix:
+		return "hotfix"
+	}
+	return "release"
+}
+
+// kindOf classifies the version by its prerelease part
+func kindOf(v *version.Version) releaseKind {
+	pre := v.Prerelease()
+	switch {
+	case pre == "":
+		return kindRelease
+	case strings.Contains(strings.ToLower(pre), "hotfix"):
+		return kindHotfix
+	}
+	return kindPrerelease
+}
+
+// policyFor returns the policy that applies to the kind of version
+func (o Options) policyFor(k releaseKind) VersionPolicy {
+	var p VersionPolicy
+	switch k {
+	case kindPrerelease:
+		p = o.Prereleases
+	case kindHotfix:
+		p = o.Hotfixes
+	default:
+		return PolicyInclude
+	}
+	if p == "" {
+		return PolicyOnlyIfCurrent
+	}
+	return p
+}
+
+// checkpointCandidates drops the versions the release kind policies and the
+// organization policy keep from being checkpoints
+func checkpointCandidates(versions []string, opts Options) []string {
+	var candidates []string
+	for _, v := range versions {
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if opts.policyFor(kindOf(ver)) == PolicyInclude && opts.Policy.allowsRancher(ver) {
+			candidates = append(candidates, v)
+		}
+	}
+	return candidates
+}
+
+// releaseKinds returns the options, checkpoint candidates, and checkpoints a
+// request plans with. Requests allowing prereleases include them like any
+// other release, unless the prerelease policy excludes them.
+func (p *Planner) releaseKinds(allowPrerelease bool) (Options, []string, []string, error) {
+	if !allowPrerelease || p.opts.policyFor(kindPrerelease) == PolicyInclude {
+		return p.opts, p.candidates, p.checkpoints, nil
+	}
+	if p.opts.policyFor(kindPrerelease) == PolicyExclude {
+		return Options{}, nil, nil, classify(CodeUnsupportedCombination, fmt.Errorf("prerelease Rancher versions cannot be allowed, the %s policy excludes them from planning", kindPrerelease))
+	}
+	opts := p.opts
+	opts.Prereleases = PolicyInclude
+	candidates := checkpointCandidates(p.versions, opts)
+	return opts, candidates, selectCheckpoints(candidates, p.paths), nil
+}
+
+// checkCurrentPolicy rejects planning from a version its policy excludes,
+// and from a prerelease version unless the options include prereleases, as
+// they do for requests allowing them
+func checkCurrentPolicy(current string, opts Options) error {
+	v, err := version.NewVersion(current)
+	if err != nil {
+		return nil
+	}
+	kind := kindOf(v)
+	switch policy := opts.policyFor(kind); {
+	case policy == PolicyExclude:
+		return classify(CodeUnsupportedCombination, fmt.Errorf("Rancher %s is a %s version, which the %s policy excludes from planning", current, kind, kind))
+	case kind == kindPrerelease && policy != PolicyInclude:
+		return classify(CodeUnsupportedCombination, fmt.Errorf("Rancher %s is a prerelease version; allow prereleases with allow_prerelease to plan from it", current))
+	}
+	return nil
+}
+
+// releaseKindNotes are the notes for steps starting from or leading to a
+// prerelease or hotfix version, by kind and end of the step
+var releaseKindNotes = map[releaseKind]map[string]string{
+	kindPrerelease: {
+		"from": "Upgrading from prerelease version %s (prerelease policy: %s)",
+		"to":   "Upgrading to prerelease version %s (prerelease policy: %s)",
+	},
+	kindHotfix: {
+		"from": "Upgrading from hotfix version %s (hotfix policy: %s)",
+		"to":   "Upgrading to hotfix version %s (hotfix policy: %s)",
+	},
+}
+
+// annotateReleaseKinds notes on Rancher steps when they start from or lead to
+// a prerelease or hotfix version and which policy allowed it
+func annotateReleaseKinds(steps []UpgradeStep, opts Options, pr printer) {
+	for i := range steps {
+		step := &steps[i]
+		if step.Type != "Rancher" {
+			continue
+		}
+		for _, end := range []struct{ v, role string }{{step.From, "from"}, {step.To, "to"}} {
+			v, err := version.NewVersion(end.v)
+			if err != nil {
+				continue
+			}
+			if kind := kindOf(v); kind != kindRelease {
+				step.Notes = append(step.Notes, pr.sprintf(releaseKindNotes[kind][end.role], end.v, opts.policyFor(kind)))
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// releaseSuffixes matches the build metadata of distributions that publish
+// their own builds of each Kubernetes release, e.g. v1.26.8+rke2r1 or
+// v1.27.6+k3s1
+var releaseSuffixes = map[string]*regexp.Regexp{
+	"rke2": regexp.MustCompile(`^rke2r\d+$`),
+	"k3s":  regexp.MustCompile(`^k3s\d+$`),
+}
+
+// checkReleaseSuffix rejects a version whose build metadata belongs to a
+// different distribution than the platform, such as +rke2r1 on k3s
+func checkReleaseSuffix(platform string, v *version.Version) error {
+	metadata := v.Metadata()
+	if metadata == "" {
+		return nil
+	}
+	if re, ok := releaseSuffixes[platform]; ok && re.MatchString(metadata) {
+		return nil
+	}
+	for name, re := range releaseSuffixes {
+		if name != platform && re.MatchString(metadata) {
+			return fmt.Errorf("%s is a release of %s, not %s", v.Original(), name, platform)
+		}
+	}
+	return nil
+}
+
+// platformReleases returns the published releases listed for the platform in
+// the data, sorted ascending; unparsable entries are skipped
+func platformReleases(paths UpgradePaths, platform string) []*version.Version {
+	names := make([]string, 0, len(paths.Releases))
+	for name := range paths.Releases {
+		if strings.EqualFold(name, platform) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var releases []*version.Version
+	for _, name := range names {
+		for _, r := range paths.Releases[name] {
+			v, err := version.NewVersion(cleanVersion(r))
+			if err != nil {
+				continue
+			}
+			releases = append(releases, v)
+		}
+	}
+	sort.Stable(version.Collection(releases))
+	return releases
+}
+
+// newestRelease returns the newest release of the given minor within the
+// range, or nil when the data lists none
+func newestRelease(releases []*version.Version, major, minor int, minVer, maxVer *version.Version) *version.Version {
+	var newest *version.Version
+	for _, r := range releases {
+		s := r.Segments()
+		if s[0] == major && s[1] == minor && inRange(r, minVer, maxVer) {
+			newest = r
+		}
+	}
+	return newest
+}
+
+// inRange reports whether v lies within the inclusive range. A bound written
+// without a patch, such as "v1.24", covers every patch of that minor.
+func inRange(v, minVer, maxVer *version.Version) bool {
+	if v.LessThan(minVer) && !sameMinorBound(v, minVer) {
+		return false
+	}
+	if v.GreaterThan(maxVer) && !sameMinorBound(v, maxVer) {
+		return false
+	}
+	return true
+}
+
+// sameMinorBound reports whether the bound is written as major.minor and v
+// belongs to that minor
+func sameMinorBound(v, bound *version.Version) bool {
+	core := cleanVersion(bound.Original())
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	if strings.Count(core, ".") != 1 {
+		return false
+	}
+	vs, bs := v.Segments(), bound.Segments()
+	return vs[0] == bs[0] && vs[1] == bs[1]
+}
+
+// checkPublished rejects plans with a Kubernetes or Migration step to a
+// version the data does not list as a release of the step's platform. Every
+// minor is resolved to its newest listed release, so such a step means the
+// data lists no release of that minor. Platforms the data lists no releases
+// for at all are not checked.
+func checkPublished(paths UpgradePaths, steps []UpgradeStep) error {
+	for _, step := range steps {
+		if step.Type != "Kubernetes" && step.Type != "Migration" {
+			continue
+		}
+		releases := platformReleases(paths, step.Platform)
+		if len(releases) == 0 || isListedRelease(releases, step.To) {
+			continue
+		}
+		v, err := version.NewVersion(cleanVersion(step.To))
+		if err != nil {
+			return classify(CodeNoPathFound, fmt.Errorf("the compatibility data lists no published %s release %s", step.Platform, step.To))
+		}
+		s := v.Segments()
+		return classify(CodeNoPathFound, fmt.Errorf("the compatibility data lists no published %s release of Kubernetes v%d.%d to upgrade to", step.Platform, s[0], s[1]))
+	}
+	return nil
+}
+
+// isListedRelease reports whether the version is one of the releases, as
+// spelled in the data
+func isListedRelease(releases []*version.Version, v string) bool {
+	target := cleanVersion(v)
+	for _, release := range releases {
+		if release.Original() == target {
+			return true
+		}
+	}
+	return false
+}
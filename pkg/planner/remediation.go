@@ -0,0 +1,87 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PatchRecommendation is the newest Kubernetes patch of the running minor
+// that the running Rancher version supports, with the advisories moving to
+// it fixes
+type PatchRecommendation struct {
+	Platform    string `json:"platform"`
+	Rancher     string `json:"rancher"`
+	Current     string `json:"current"`
+	Recommended string `json:"recommended"` // The current version when no newer patch is listed
+	UpToDate    bool   `json:"up_to_date"`
+	// Fixed are the advisories affecting the current version but not the
+	// recommended one; Remaining still affect the recommended one
+	Fixed     []Advisory `json:"fixed"`
+	Remaining []Advisory `json:"remaining"`
+}
+
+// PatchRemediation recommends the newest patch release of the cluster's
+// Kubernetes minor within the Rancher version's supported range, an upgrade
+// that does not touch Rancher. It needs the data to list the platform's
+// releases.
+func (p *Planner) PatchRemediation(platform, rancher, k8s string) (*PatchRecommendation, error) {
+	k8sVer, err := parseInputVersion(k8s)
+	if err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+	if _, err := parseInputVersion(rancher); err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Rancher version: %v", err))
+	}
+	currentRancher, err := findRancherVersion(p.versions, normalizeVersion(rancher))
+	if err != nil {
+		return nil, err
+	}
+	platform = canonicalPlatform(platform, p.aliases)
+	if err := checkReleaseSuffix(platform, k8sVer); err != nil {
+		return nil, classify(CodeInvalidVersion, fmt.Errorf("invalid current Kubernetes version: %v", err))
+	}
+
+	minVer, maxVer, ok := platformRange(p.paths.RancherManager[currentRancher], platform)
+	if !ok {
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("Rancher %s does not support %s", currentRancher, platform))
+	}
+	if !inRange(k8sVer, minVer, maxVer) {
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("Kubernetes %s is outside the range Rancher %s supports on %s, v%s to v%s", k8s, currentRancher, platform, minVer.Original(), maxVer.Original()))
+	}
+	releases := platformReleases(p.paths, platform)
+	if len(releases) == 0 {
+		return nil, classify(CodeNoPathFound, fmt.Errorf("the compatibility data lists no %s releases to recommend a patch from", platform))
+	}
+
+	current := strings.TrimSpace(k8s)
+	rec := &PatchRecommendation{
+		Platform:    platform,
+		Rancher:     currentRancher,
+		Current:     current,
+		Recommended: current,
+		Fixed:       []Advisory{},
+		Remaining:   []Advisory{},
+	}
+	s := k8sVer.Segments()
+	if newest := newestRelease(releases, s[0], s[1], minVer, maxVer); newest != nil && newest.GreaterThan(k8sVer) {
+		rec.Recommended = "v" + newest.Original()
+	} else {
+		rec.UpToDate = true
+	}
+
+	for _, a := range p.paths.Advisories {
+		if component := strings.ToLower(a.Component); component != "kubernetes" && component != platform {
+			continue
+		}
+		switch {
+		case a.affects(rec.Recommended):
+			rec.Remaining = append(rec.Remaining, a)
+		case a.affects(rec.Current):
+			rec.Fixed = append(rec.Fixed, a)
+		}
+	}
+	sort.SliceStable(rec.Fixed, func(i, j int) bool { return rec.Fixed[i].ID < rec.Fixed[j].ID })
+	sort.SliceStable(rec.Remaining, func(i, j int) bool { return rec.Remaining[i].ID < rec.Remaining[j].ID })
+	return rec, nil
+}
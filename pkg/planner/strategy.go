@@ -0,0 +1,114 @@
+package planner
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultStrategy is used when a request does not name a strategy
+const DefaultStrategy = "greedy"
+
+// Strategy selects the steps that take a cluster from its current versions to
+// the newest checkpoint. Strategies are registered with RegisterStrategy and
+// chosen per request by name.
+type Strategy interface {
+	// Name returns the lowercase name requests use to select the strategy.
+	Name() string
+	// Steps returns the ordered upgrade steps for the input.
+	Steps(in PlanInput) ([]UpgradeStep, error)
+}
+
+// PlanInput is everything a Strategy needs to plan a single request
+type PlanInput struct {
+	Platform       string        // Lowercase platform name
+	Rules          PlatformRules // Rules registered for the platform
+	CurrentRancher string
+	CurrentK8s     string
+	Checkpoints    []string // Rancher versions plans hop through, ascending
+	Paths          UpgradePaths
+	Graph          *Graph // Precomputed compatibility graph for the platform
+
+	// Context is done when the request is canceled or times out; strategies
+	// doing more than a trivial amount of work should stop early when it is.
+	// Nil never cancels.
+	Context context.Context
+}
+
+// canceled returns the context's error once it is done
+func (in PlanInput) canceled() error {
+	if in.Context == nil {
+		return nil
+	}
+	return in.Context.Err()
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[string]Strategy)
+)
+
+// RegisterStrategy makes the strategy available under its name, replacing
+// any strategy previously registered with the same name.
+func RegisterStrategy(s Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[strings.ToLower(s.Name())] = s
+}
+
+// LookupStrategy returns the strategy registered under name, ignoring case.
+func LookupStrategy(name string) (Strategy, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	s, ok := strategies[strings.ToLower(name)]
+	return s, ok
+}
+
+// RegisteredStrategies returns the names of all registered strategies, sorted.
+func RegisteredStrategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterStrategy(greedyStrategy{})
+	RegisterStrategy(conservativeStrategy{})
+	RegisterStrategy(shortestPathStrategy{})
+}
+
+// greedyStrategy hops through every checkpoint and upgrades Kubernetes as far
+// as the platform's skip policy allows after each hop
+type greedyStrategy struct{}
+
+func (greedyStrategy) Name() string { return "greedy" }
+
+func (greedyStrategy) Steps(in PlanInput) ([]UpgradeStep, error) {
+	return planThroughCheckpoints(in.CurrentRancher, in.CurrentK8s, in.Platform, in.Checkpoints, in.Paths, in.Rules)
+}
+
+// conservativeStrategy hops through every checkpoint like greedy, but never
+// skips a Kubernetes minor version regardless of the platform
+type conservativeStrategy struct{}
+
+func (conservativeStrategy) Name() string { return "conservative" }
+
+func (conservativeStrategy) Steps(in PlanInput) ([]UpgradeStep, error) {
+	return planThroughCheckpoints(in.CurrentRancher, in.CurrentK8s, in.Platform, in.Checkpoints, in.Paths, stepwiseRules{in.Rules})
+}
+
+// stepwiseRules overrides the skip policy of the wrapped rules to one minor per step
+type stepwiseRules struct {
+	PlatformRules
+}
+
+func (stepwiseRules) SkipPolicy() SkipPolicy {
+	return SkipPolicy{MaxMinors: 1, Stepwise: true}
+}
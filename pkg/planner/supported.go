@@ -0,0 +1,93 @@
+package planner
+
+import "fmt"
+
+// RuleAlwaysSupported identifies the warning added when a request asking for
+// an always supported path was planned with the shortest-path strategy
+// because the requested strategy passes through an unsupported state
+const RuleAlwaysSupported = "always-supported"
+
+// UnsupportedStateError is returned for requests asking for an always
+// supported path when the cluster would run a Rancher and Kubernetes
+// combination the data does not list as supported
+type UnsupportedStateError struct {
+	Platform string
+	Rancher  string
+	K8s      string
+	Step     int // Index of the step leading to the state, -1 for the current state
+}
+
+// Error implements error
+func (e *UnsupportedStateError) Error() string {
+	if e.Step < 0 {
+		return fmt.Sprintf("the cluster runs Kubernetes %s on Rancher %s, which is not supported on %s; no path keeps it supported at every step",
+			e.K8s, e.Rancher, e.Platform)
+	}
+	return fmt.Sprintf("no path keeps the cluster supported at every step: after step %d it would run Kubernetes %s on Rancher %s, which is not supported on %s",
+		e.Step+1, e.K8s, e.Rancher, e.Platform)
+}
+
+// firstUnsupported returns the first state of the plan, starting with the
+// current one, in which the Rancher version does not support the Kubernetes
+// version, or nil when every state is supported
+func firstUnsupported(g *Graph, rancher, k8s string, steps []UpgradeStep) *UnsupportedStateError {
+	for i := -1; i < len(steps); i++ {
+		if i >= 0 {
+			switch steps[i].Type {
+			case "Rancher":
+				rancher = steps[i].To
+			case "Kubernetes":
+				k8s = steps[i].To
+			default:
+				continue
+			}
+		}
+		if v, err := parseK8sVersion(k8s); err != nil || !g.Supports(rancher, v) {
+			return &UnsupportedStateError{Platform: g.Platform, Rancher: rancher, K8s: k8s, Step: i}
+		}
+	}
+	return nil
+}
+
+// alwaysSupported returns steps keeping the cluster supported at every step:
+// the strategy's own when they do, otherwise those of the shortest-path
+// strategy, which only moves between supported states, with a warning
+// explaining the switch on the first step where the plans part
+func alwaysSupported(strategy Strategy, in PlanInput, steps []UpgradeStep, pr printer) ([]UpgradeStep, Strategy, *Warning, error) {
+	unsupported := firstUnsupported(in.Graph, in.CurrentRancher, in.CurrentK8s, steps)
+	if unsupported == nil {
+		return steps, strategy, nil, nil
+	}
+	fallback := Strategy(shortestPathStrategy{})
+	if unsupported.Step < 0 || strategy.Name() == fallback.Name() {
+		return nil, nil, nil, unsupported
+	}
+	alt, err := fallback.Steps(in)
+	if err != nil {
+		if ctxErr := in.canceled(); ctxErr != nil {
+			return nil, nil, nil, fmt.Errorf("planning canceled: %w", ctxErr)
+		}
+		return nil, nil, nil, unsupported
+	}
+	if e := firstUnsupported(in.Graph, in.CurrentRancher, in.CurrentK8s, alt); e != nil {
+		return nil, nil, nil, e
+	}
+	return alt, fallback, &Warning{
+		Rule: RuleAlwaysSupported,
+		Step: firstDifference(steps, alt),
+		Message: pr.sprintf("The %s strategy would run Kubernetes %s on Rancher %s, which is not supported, so the plan uses the %s strategy to keep the cluster supported at every step",
+			strategy.Name(), unsupported.K8s, unsupported.Rancher, fallback.Name()),
+	}, nil
+}
+
+// firstDifference returns the index of the first step of alt that differs
+// from steps. The strategy's steps leave the supported matrix by then, so
+// alt differs at the latest at the step doing so, and always within alt.
+func firstDifference(steps, alt []UpgradeStep) int {
+	for i := range alt {
+		if i >= len(steps) || steps[i].Type != alt[i].Type || steps[i].To != alt[i].To {
+			return i
+		}
+	}
+	return len(alt) - 1
+}
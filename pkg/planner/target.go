@@ -0,0 +1,47 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// targetCheckpoints ends the checkpoints at the target Rancher version
+// instead of the newest one. The target must not be older than the current
+// version; at the current version the plan only upgrades Kubernetes as far
+// as that version supports. It must be allowed by the release kind and
+// organization policies, and support the platform.
+func targetCheckpoints(paths UpgradePaths, platform, current, target string, checkpoints, candidates []string) ([]string, error) {
+	cur, err := version.NewVersion(current)
+	if err != nil {
+		return nil, err
+	}
+	tv, err := version.NewVersion(target)
+	if err != nil {
+		return nil, err
+	}
+	if tv.LessThan(cur) {
+		return nil, classify(CodeInvalidRequest, fmt.Errorf("target Rancher version %s is older than the current version %s", target, current))
+	}
+	allowed := false
+	for _, c := range candidates {
+		if c == target {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("target Rancher version %s is excluded from plans by the prerelease, hotfix, or organization policy", target))
+	}
+	if !listsPlatform(paths.RancherManager[target], platform) {
+		return nil, classify(CodeUnsupportedCombination, fmt.Errorf("target Rancher version %s does not support %s", target, platform))
+	}
+
+	var kept []string
+	for _, c := range checkpoints {
+		if v, err := version.NewVersion(c); err == nil && v.LessThan(tv) {
+			kept = append(kept, c)
+		}
+	}
+	return append(kept, target), nil
+}
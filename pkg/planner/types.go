@@ -0,0 +1,179 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Platform defines the compatibility of Kubernetes versions with a Rancher version
+type Platform struct {
+	Platform   string `json:"platform"`
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+	Notes      string `json:"notes,omitempty"`
+}
+
+// RancherManagerVersion contains supported platforms for each Rancher version
+type RancherManagerVersion struct {
+	SupportedPlatforms []Platform `json:"supported_platforms"`
+
+	// Waypoint marks a version every upgrade from an older version must pass
+	// through, in addition to the highest patch of each minor
+	Waypoint bool `json:"waypoint,omitempty"`
+
+	// UIExtensionsAPI is the version of the UI extensions API the Rancher
+	// version provides, checked against the extensions of a request
+	UIExtensionsAPI string `json:"ui_extensions_api,omitempty"`
+
+	// Components lists the versions of components embedded in the Rancher
+	// version, such as cluster-api and rancher-provisioning-capi
+	Components map[string]string `json:"components,omitempty"`
+
+	// CertManager is the range of cert-manager versions the Rancher version
+	// supports in go-version constraint syntax, e.g. ">= 1.11.0, < 1.15.0"
+	CertManager string `json:"cert_manager,omitempty"`
+
+	// Management lists the requirements on the local cluster Rancher is
+	// installed on, checked against the management cluster of a request
+	Management *ManagementRequirements `json:"management,omitempty"`
+}
+
+// UpgradePaths stores all Rancher versions and their compatibility data
+type UpgradePaths struct {
+	Version        string                           `json:"version,omitempty"` // Optional version of the data itself
+	RancherManager map[string]RancherManagerVersion `json:"rancher_manager"`
+	Constraints    []Constraint                     `json:"constraints,omitempty"`
+
+	// Releases lists the published Kubernetes releases of each platform, keyed
+	// by lowercase platform name, e.g. "rke2": ["v1.26.8+rke2r1"]. Plans target
+	// the newest listed release of a minor instead of a bare version.
+	Releases map[string][]string `json:"releases,omitempty"`
+
+	// CertManagerReleases lists the published cert-manager releases that
+	// cert-manager steps upgrade to, e.g. ["v1.13.3", "v1.14.5"]
+	CertManagerReleases []string `json:"cert_manager_releases,omitempty"`
+
+	// DockerReleases lists the Docker release lines RKE1 nodes can run and
+	// the Kubernetes versions each supports
+	DockerReleases []DockerRelease `json:"docker_releases,omitempty"`
+
+	// Advisories lists published security advisories and the versions they
+	// affect. Plans report the advisories their upgrade fixes or introduces.
+	Advisories []Advisory `json:"advisories,omitempty"`
+
+	// OperatingSystems lists the releases of node operating systems and the
+	// Kubernetes versions they support, keyed by lowercase OS name, e.g. "sles"
+	OperatingSystems map[string][]OSRelease `json:"operating_systems,omitempty"`
+
+	// Lifecycle holds the support phases of Rancher minors, keyed by minor,
+	// e.g. "2.8"
+	Lifecycle map[string]Lifecycle `json:"lifecycle,omitempty"`
+
+	// Cadence holds the release cadence assumptions projecting the releases
+	// of future planned dates
+	Cadence *ReleaseCadence `json:"release_cadence,omitempty"`
+}
+
+// Validate checks the parts of the data that would otherwise be skipped
+// silently when they do not parse: the constraints, the affected ranges of
+// the advisories, and the cert-manager ranges of the Rancher versions
+func (paths UpgradePaths) Validate() error {
+	for _, c := range paths.Constraints {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range paths.Advisories {
+		if err := a.validate(); err != nil {
+			return err
+		}
+	}
+	versions := make([]string, 0, len(paths.RancherManager))
+	for v := range paths.RancherManager {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	for _, v := range versions {
+		if r := paths.RancherManager[v].CertManager; r != "" {
+			if _, err := version.NewConstraint(r); err != nil {
+				return fmt.Errorf("Rancher %s has invalid cert_manager range %q: %v", v, r, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UpgradeStep represents a single upgrade step
+type UpgradeStep struct {
+	ID       string `json:"id"`       // Unique within the plan, e.g. rancher-2.8.5 or k8s-v1.27.16
+	Type     string `json:"type"`     // Rancher, Kubernetes, OS, Docker, cert-manager, or Migration
+	Platform string `json:"platform"` // RKE1, RKE2, etc., or the operating system of OS steps
+	From     string `json:"from"`     // Previous version
+	To       string `json:"to"`       // New version
+
+	Notes []string `json:"notes,omitempty"` // Platform specific guidance
+
+	// Verify lists the checks to pass after the step before continuing
+	Verify []string `json:"verify,omitempty"`
+
+	// SupportPhase is the support phase of the target of a Rancher step at
+	// the planned date, when the data has lifecycle data for its minor
+	SupportPhase string `json:"support_phase,omitempty"`
+
+	// Components are the embedded component versions of the target of a
+	// Rancher step, when the data lists them
+	Components map[string]string `json:"components,omitempty"`
+
+	// DependsOn lists the IDs of the earlier steps that must complete before
+	// this one starts; steps that do not depend on each other may run in parallel
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Changelog is left empty by the planner for callers that attach
+	// release notes, such as the service with ?changelog=
+	Changelog *Changelog `json:"changelog,omitempty"`
+}
+
+// Changelog aggregates the release notes of every version a step passes
+// through, up to and including its target
+type Changelog struct {
+	Releases    []ReleaseNotes `json:"releases"`
+	Unavailable []string       `json:"unavailable,omitempty"` // Versions whose notes could not be fetched
+}
+
+// ReleaseNotes are the published notes of a single release
+type ReleaseNotes struct {
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+	Notes   string `json:"notes"`
+}
+
+// Plan is the result of planning an upgrade
+type Plan struct {
+	Platform  string           `json:"platform"` // Canonical name of the submitted platform
+	Steps     []UpgradeStep    `json:"upgrade_path"`
+	Warnings  []Warning        `json:"warnings,omitempty"`
+	Preflight []PreflightCheck `json:"preflight,omitempty"` // Checks to run before the first step
+	// Projected are the Rancher minors expected by the planned date that are
+	// not in the data; only set for requests with a planned date
+	Projected []ProjectedRelease `json:"projected_releases,omitempty"`
+	Effort    *Effort            `json:"effort,omitempty"`   // Set when the planner has an EffortModel
+	Security  *SecurityDelta     `json:"security,omitempty"` // Set when the data lists advisories
+	Meta      *Meta              `json:"meta,omitempty"`     // How the plan was produced
+}
+
+// canonicalize puts the parts of a plan without an inherent order into a
+// fixed one: warnings are ordered by step, then rule, then message
+func (p *Plan) canonicalize() {
+	sort.SliceStable(p.Warnings, func(i, j int) bool {
+		a, b := p.Warnings[i], p.Warnings[j]
+		if a.Step != b.Step {
+			return a.Step < b.Step
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+}
@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	// maxVersionLength bounds the length of version strings accepted in requests
+	maxVersionLength = 64
+	// maxVersionSegment bounds each numeric segment so minor arithmetic cannot overflow
+	maxVersionSegment = 100000
+)
+
+// cleanVersion removes the "v" prefix from a version string
+func cleanVersion(v string) string {
+	v = strings.TrimPrefix(v, "v")
+	return v
+}
+
+// normalizeVersion trims whitespace and a "v" or "V" prefix from user input
+func normalizeVersion(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) > 0 && (v[0] == 'v' || v[0] == 'V') {
+		v = v[1:]
+	}
+	return v
+}
+
+// versionKey identifies a version by its segments and prerelease, ignoring
+// metadata and spelling, so "1.24", "1.24.0" and "1.24.0+rke2r1" share a key
+// just as they compare equal
+func versionKey(v *version.Version) string {
+	segments := v.Segments()
+	parts := make([]string, len(segments))
+	for i, s := range segments {
+		parts[i] = fmt.Sprint(s)
+	}
+	key := strings.Join(parts, ".")
+	if pre := v.Prerelease(); pre != "" {
+		key += "-" + pre
+	}
+	return key
+}
+
+// parseK8sVersion parses a Kubernetes version string
+func parseK8sVersion(v string) (*version.Version, error) {
+	return version.NewVersion(normalizeVersion(v))
+}
+
+// parseInputVersion parses a version submitted in a request. Unlike versions
+// from the data, it must be major.minor or major.minor.patch with an optional
+// prerelease or metadata suffix, and is bounded in length and magnitude.
+func parseInputVersion(v string) (*version.Version, error) {
+	if len(v) > maxVersionLength {
+		return nil, fmt.Errorf("version is longer than %d characters", maxVersionLength)
+	}
+	cleaned := normalizeVersion(v)
+	ver, err := version.NewVersion(cleaned)
+	if err != nil {
+		return nil, err
+	}
+
+	core := cleaned
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	if n := strings.Count(core, ".") + 1; n < 2 || n > 3 {
+		return nil, fmt.Errorf("malformed version %q, expected major.minor or major.minor.patch", v)
+	}
+	for _, segment := range ver.Segments() {
+		if segment > maxVersionSegment {
+			return nil, fmt.Errorf("version %q is out of range", v)
+		}
+	}
+	return ver, nil
+}
@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	StateClosed   State = iota // Requests flow normally
+	StateHalfOpen              // A single trial request is allowed through
+	StateOpen                  // Requests fail fast until the open timeout expires
+)
+
+// String returns the lowercase name of the state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// breaker is a consecutive-failure circuit breaker for a single host
+type breaker struct {
+	host      string
+	threshold int
+	timeout   time.Duration
+	notify    func(host string, state State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow reports whether a request may be sent
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+		b.set(StateHalfOpen)
+		b.trial = true
+		return true
+	case StateHalfOpen:
+		// Only one trial request at a time
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// success records a successful request and closes the breaker
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.set(StateClosed)
+}
+
+// failure records a failed request, opening the breaker at the threshold
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == StateHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.set(StateOpen)
+	}
+}
+
+// current returns the breaker state
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// set changes the state and notifies the listener; callers must hold mu
+func (b *breaker) set(s State) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	if b.notify != nil {
+		b.notify(b.host, s)
+	}
+}
@@ -0,0 +1,226 @@
+// Package remote provides the HTTP client used for outbound fetches made by the
+// upgrade tool. Every request goes through a per-host circuit breaker and is
+// retried with exponential backoff, so a flaky upstream can neither stall a
+// refresh indefinitely nor hang the goroutine that triggered it.
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when the breaker for the target host is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Options configures retries, timeouts, and circuit breaking for a Client.
+type Options struct {
+	Timeout          time.Duration // Timeout for a single attempt
+	MaxRetries       int           // Retries after the first attempt
+	InitialBackoff   time.Duration // Delay before the first retry
+	MaxBackoff       time.Duration // Upper bound for the backoff delay
+	FailureThreshold int           // Consecutive failed calls, after their retries, that open the breaker
+	OpenTimeout      time.Duration // Time the breaker stays open before a trial request
+
+	// RootCAs overrides the trusted CA pool; nil uses the system pool.
+	RootCAs *x509.CertPool
+
+	// OnStateChange is called whenever the breaker for a host changes state.
+	OnStateChange func(host string, state State)
+}
+
+// DefaultOptions returns the options used when nothing is configured.
+func DefaultOptions() Options {
+	return Options{
+		Timeout:          10 * time.Second,
+		MaxRetries:       3,
+		InitialBackoff:   500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      60 * time.Second,
+	}
+}
+
+// Client performs outbound HTTP requests with retries and circuit breaking.
+type Client struct {
+	http *http.Client
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// New creates a Client using the given options. Proxies are taken from the
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+func New(opts Options) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.RootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    opts.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &Client{
+		http:     &http.Client{Timeout: opts.Timeout, Transport: transport},
+		opts:     opts,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// LoadCertPool returns the system CA pool extended with the PEM bundles at paths.
+func LoadCertPool(paths ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, path := range paths {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %s: %v", path, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// Get issues a GET request for url, bound to ctx.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends the request, retrying network errors, 429s, and 5xx responses.
+// Requests with a body are only retried when GetBody is set. The call counts
+// as a single success or failure for the host's breaker, however many
+// attempts it took, and a failed call returns the last upstream response or
+// error.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	b := c.breaker(host)
+	if !b.allow() {
+		return nil, fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+
+	resp, err := c.send(req)
+	if err == nil && !retryableStatus(resp.StatusCode) {
+		b.success()
+	} else {
+		b.failure()
+	}
+	return resp, err
+}
+
+// send makes the attempts of a request with exponential backoff between
+// them, until one succeeds or the retries are used up
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	backoff := c.opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.http.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		retryable := attempt < c.opts.MaxRetries && (req.Body == nil || req.GetBody != nil)
+		if !retryable {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			// Drain so the connection can be reused by the next attempt
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), jitter(backoff)); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+		if backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// States returns the current breaker state of every host contacted so far.
+func (c *Client) States() map[string]State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make(map[string]State, len(c.breakers))
+	for host, b := range c.breakers {
+		states[host] = b.current()
+	}
+	return states
+}
+
+// breaker returns the breaker for host, creating it on first use
+func (c *Client) breaker(host string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &breaker{
+			host:      host,
+			threshold: c.opts.FailureThreshold,
+			timeout:   c.opts.OpenTimeout,
+			notify:    c.opts.OnStateChange,
+		}
+		c.breakers[host] = b
+		if b.notify != nil {
+			b.notify(host, StateClosed)
+		}
+	}
+	return b
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// jitter spreads retries out to between half and the full backoff delay
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
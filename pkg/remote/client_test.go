@@ -0,0 +1,253 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/remote"
+)
+
+// upstream is a test server answering with its current status code and
+// recording when each request arrived
+type upstream struct {
+	*httptest.Server
+	status atomic.Int32
+
+	mu       sync.Mutex
+	requests []time.Time
+}
+
+func newUpstream(t *testing.T, status int) *upstream {
+	u := &upstream{}
+	u.status.Store(int32(status))
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests = append(u.requests, time.Now())
+		u.mu.Unlock()
+		w.WriteHeader(int(u.status.Load()))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// hits returns the arrival times of the requests so far
+func (u *upstream) hits() []time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]time.Time(nil), u.requests...)
+}
+
+// stateLog records the breaker state changes of a client
+type stateLog struct {
+	mu     sync.Mutex
+	states []remote.State
+}
+
+func (l *stateLog) record(_ string, s remote.State) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.states = append(l.states, s)
+}
+
+func (l *stateLog) get() []remote.State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]remote.State(nil), l.states...)
+}
+
+// get sends a GET request and returns the status code, closing the body
+func get(t *testing.T, c *remote.Client, url string) (int, error) {
+	t.Helper()
+	resp, err := c.Get(context.Background(), url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// TestBreakerLifecycle walks a host's breaker from closed to open after the
+// threshold of failed calls, to half-open once the open timeout expires, and
+// back to closed when the trial call succeeds
+func TestBreakerLifecycle(t *testing.T) {
+	u := newUpstream(t, http.StatusServiceUnavailable)
+	var log stateLog
+	c := remote.New(remote.Options{
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+		OnStateChange:    log.record,
+	})
+
+	for i := 0; i < 2; i++ {
+		if code, err := get(t, c, u.URL); err != nil || code != http.StatusServiceUnavailable {
+			t.Fatalf("call %d: got %d, %v, expected the upstream 503", i+1, code, err)
+		}
+	}
+	host := u.Listener.Addr().String()
+	if s := c.States()[host]; s != remote.StateOpen {
+		t.Fatalf("breaker is %s after reaching the threshold, expected open", s)
+	}
+
+	if _, err := get(t, c, u.URL); !errors.Is(err, remote.ErrCircuitOpen) {
+		t.Fatalf("call on an open breaker returned %v, expected ErrCircuitOpen", err)
+	}
+	if n := len(u.hits()); n != 2 {
+		t.Fatalf("upstream received %d requests, expected the open breaker to stop the third", n)
+	}
+
+	// A failed trial opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	if code, err := get(t, c, u.URL); err != nil || code != http.StatusServiceUnavailable {
+		t.Fatalf("trial call: got %d, %v, expected the upstream 503", code, err)
+	}
+	if s := c.States()[host]; s != remote.StateOpen {
+		t.Fatalf("breaker is %s after a failed trial, expected open", s)
+	}
+
+	// A successful trial closes it
+	time.Sleep(60 * time.Millisecond)
+	u.status.Store(http.StatusOK)
+	if code, err := get(t, c, u.URL); err != nil || code != http.StatusOK {
+		t.Fatalf("trial call: got %d, %v, expected 200", code, err)
+	}
+	if s := c.States()[host]; s != remote.StateClosed {
+		t.Fatalf("breaker is %s after a successful trial, expected closed", s)
+	}
+
+	expected := []remote.State{
+		remote.StateClosed, remote.StateOpen,
+		remote.StateHalfOpen, remote.StateOpen,
+		remote.StateHalfOpen, remote.StateClosed,
+	}
+	if got := log.get(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("state changes %v, expected %v", got, expected)
+	}
+}
+
+// TestRetriesCountAsOneFailure checks that the attempts of a single call
+// count once toward the threshold and that the last upstream response is
+// returned, not ErrCircuitOpen
+func TestRetriesCountAsOneFailure(t *testing.T) {
+	u := newUpstream(t, http.StatusBadGateway)
+	c := remote.New(remote.Options{
+		Timeout:          time.Second,
+		MaxRetries:       3,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	})
+
+	code, err := get(t, c, u.URL)
+	if err != nil || code != http.StatusBadGateway {
+		t.Fatalf("got %d, %v, expected the upstream 502", code, err)
+	}
+	if n := len(u.hits()); n != 4 {
+		t.Fatalf("upstream received %d requests, expected the first attempt and 3 retries", n)
+	}
+	host := u.Listener.Addr().String()
+	if s := c.States()[host]; s != remote.StateClosed {
+		t.Fatalf("breaker is %s after one failed call, expected closed", s)
+	}
+
+	if _, err := get(t, c, u.URL); err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if s := c.States()[host]; s != remote.StateOpen {
+		t.Fatalf("breaker is %s after two failed calls, expected open", s)
+	}
+}
+
+// TestNetworkErrorReturned checks that a call failing on the network returns
+// the network error even when the failure opens the breaker
+func TestNetworkErrorReturned(t *testing.T) {
+	u := newUpstream(t, http.StatusOK)
+	url := u.URL
+	u.Close()
+	c := remote.New(remote.Options{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	})
+
+	_, err := get(t, c, url)
+	if err == nil || errors.Is(err, remote.ErrCircuitOpen) {
+		t.Fatalf("got %v, expected the connection error", err)
+	}
+	if _, err := get(t, c, url); !errors.Is(err, remote.ErrCircuitOpen) {
+		t.Fatalf("call after the failure returned %v, expected ErrCircuitOpen", err)
+	}
+}
+
+// TestBackoffLimits checks that retries stop after MaxRetries, wait at least
+// half the backoff between attempts, and never wait much longer than
+// MaxBackoff
+func TestBackoffLimits(t *testing.T) {
+	const (
+		initial    = 10 * time.Millisecond
+		maxBackoff = 20 * time.Millisecond
+		retries    = 5
+	)
+	u := newUpstream(t, http.StatusTooManyRequests)
+	c := remote.New(remote.Options{
+		Timeout:        time.Second,
+		MaxRetries:     retries,
+		InitialBackoff: initial,
+		MaxBackoff:     maxBackoff,
+		OpenTimeout:    time.Minute,
+	})
+
+	if code, err := get(t, c, u.URL); err != nil || code != http.StatusTooManyRequests {
+		t.Fatalf("got %d, %v, expected the upstream 429", code, err)
+	}
+	hits := u.hits()
+	if len(hits) != retries+1 {
+		t.Fatalf("upstream received %d requests, expected %d", len(hits), retries+1)
+	}
+
+	backoff := initial
+	for i := 1; i < len(hits); i++ {
+		gap := hits[i].Sub(hits[i-1])
+		if gap < backoff/2 {
+			t.Errorf("retry %d came %v after the previous attempt, expected at least %v", i, gap, backoff/2)
+		}
+		// Uncapped, the last retries would wait 80ms and more
+		if gap > maxBackoff+50*time.Millisecond {
+			t.Errorf("retry %d came %v after the previous attempt, expected the backoff capped at %v", i, gap, maxBackoff)
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// TestCanceledBackoff checks that a canceled context ends the backoff
+func TestCanceledBackoff(t *testing.T) {
+	u := newUpstream(t, http.StatusServiceUnavailable)
+	c := remote.New(remote.Options{
+		Timeout:        time.Second,
+		MaxRetries:     3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Minute,
+		OpenTimeout:    time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Get(ctx, u.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, expected the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("call returned after %v, expected the cancellation to end the backoff", elapsed)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// runPlan plans an upgrade without starting the service and prints the plan
+// as a table or JSON, for CI pipelines and air-gapped environments. It plans
+// with the same data and environment configuration as the service.
+func runPlan(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	data := fs.String("data", upgradePathsFile, "dataset to plan against")
+	var req planner.Request
+	fs.StringVar(&req.Platform, "platform", "", "Kubernetes distribution, e.g. rke2")
+	fs.StringVar(&req.CurrentRancher, "rancher", "", "running Rancher version, e.g. 2.7.5")
+	fs.StringVar(&req.CurrentK8s, "k8s", "", "running Kubernetes version, e.g. 1.24.9")
+	fs.StringVar(&req.TargetRancher, "target", "", "Rancher version to stop at; empty plans to the newest")
+	fs.BoolVar(&req.AllowPrerelease, "allow-prerelease", false, "plan with prerelease Rancher versions such as 2.9.0-rc1 like releases")
+	fs.StringVar(&req.RancherHops, "rancher-hops", "", "Rancher hops: latest-patch (default) or minor-only")
+	fs.StringVar(&req.MigrateTo, "migrate-to", "", "platform to migrate the cluster to, e.g. rke2 for an rke1 cluster")
+	fs.StringVar(&req.PlannedDate, "date", "", "date the plan is executed on, as YYYY-MM-DD; empty means today")
+	fs.BoolVar(&req.LTSS, "ltss", false, "the cluster has a long-term service pack support contract")
+	fs.StringVar(&req.DockerVersion, "docker", "", "Docker version of the nodes of an RKE1 cluster, e.g. 20.10.21")
+	var facts stringList
+	fs.Var(&facts, "fact", "version of another cluster component as name=version, e.g. docker=20.10.24, repeatable")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if req.Platform == "" || req.CurrentRancher == "" || req.CurrentK8s == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: rancher-upgrade-tool plan -platform <platform> -rancher <version> -k8s <version> [-target version] [-o table|json]")
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q, use table or json\n", *output)
+		return 2
+	}
+	for _, f := range facts {
+		name, v, ok := strings.Cut(f, "=")
+		if !ok || name == "" || v == "" {
+			fmt.Fprintf(os.Stderr, "invalid fact %q, use name=version\n", f)
+			return 2
+		}
+		if req.Facts == nil {
+			req.Facts = make(map[string]string)
+		}
+		req.Facts[name] = v
+	}
+
+	opts, err := plannerOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	paths, err := loadUpgradePathsFile(*data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	planner.Commit = currentBuild().Commit
+
+	plan, err := planner.New(paths, opts).Plan(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No plan: %v\n", err)
+		return 1
+	}
+	if *output == "json" {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintln(out, string(data))
+		return 0
+	}
+	printPlanTable(out, plan)
+	return 0
+}
+
+// printPlanTable prints the plan's steps as a table, followed by its warnings
+func printPlanTable(out io.Writer, plan *planner.Plan) {
+	fmt.Fprintf(out, "Plan for %s: %d steps\n\n", plan.Platform, len(plan.Steps))
+	if len(plan.Steps) > 0 {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STEP\tTYPE\tPLATFORM\tFROM\tTO\tAFTER")
+		for i, s := range plan.Steps {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, s.Type, orDash(s.Platform), s.From, s.To, orDash(strings.Join(s.DependsOn, ",")))
+		}
+		w.Flush()
+	}
+	if len(plan.Warnings) > 0 {
+		fmt.Fprintln(out, "\nWarnings:")
+		for _, w := range plan.Warnings {
+			if w.Step >= 0 {
+				fmt.Fprintf(out, "  step %d [%s]: %s\n", w.Step+1, w.Rule, w.Message)
+			} else {
+				fmt.Fprintf(out, "  [%s]: %s\n", w.Rule, w.Message)
+			}
+		}
+	}
+}
+
+// orDash returns s, or "-" for an empty table cell
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
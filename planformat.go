@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats plans are returned in
+const (
+	planFormatJSON     = "json"
+	planFormatYAML     = "yaml"
+	planFormatText     = "text"
+	planFormatMarkdown = "markdown"
+)
+
+// planFormatTypes maps the plan formats to their media types, in the order
+// they are preferred when an Accept header allows several. Formats without a
+// media type are only chosen with ?format=.
+var planFormatTypes = []struct{ format, mime string }{
+	{planFormatJSON, fiber.MIMEApplicationJSON},
+	{planFormatYAML, "application/yaml"},
+	{planFormatText, fiber.MIMETextPlain},
+	{planFormatMarkdown, "text/markdown"},
+	{diagramMermaid, ""},
+	{diagramDOT, ""},
+}
+
+// planFormat returns the format chosen with ?format=, or negotiated from the
+// Accept header, JSON when neither picks one
+func planFormat(c *fiber.Ctx) (string, error) {
+	c.Vary(fiber.HeaderAccept)
+	names := make([]string, len(planFormatTypes))
+	for i, f := range planFormatTypes {
+		names[i] = f.format
+	}
+	if format := c.Query("format"); format != "" {
+		for _, name := range names {
+			if format == name {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(names, ", "))
+	}
+
+	var mimes []string
+	for _, f := range planFormatTypes {
+		if f.mime != "" {
+			mimes = append(mimes, f.mime)
+		}
+	}
+	accepted := c.Accepts(mimes...)
+	for _, f := range planFormatTypes {
+		if f.mime != "" && f.mime == accepted {
+			return f.format, nil
+		}
+	}
+	return planFormatJSON, nil
+}
+
+// sendPlan writes the plan in the format
+func sendPlan(c *fiber.Ctx, plan *planner.Plan, format string) error {
+	switch format {
+	case planFormatYAML:
+		data, err := marshalYAML(plan)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		return c.Send(data)
+	case planFormatText:
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(renderPlanText(plan))
+	case planFormatMarkdown:
+		doc, err := renderRunbook(plan)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		return c.SendString(doc)
+	case diagramMermaid, diagramDOT:
+		c.Set(fiber.HeaderContentType, diagramContentType(format))
+		return c.SendString(renderPlanDiagram(plan, format))
+	}
+	return c.JSON(plan)
+}
+
+// marshalYAML encodes the value as YAML with its JSON field names. The types
+// only have JSON field names, so the value goes through JSON first.
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// renderPlanText renders the plan as a step by step checklist to paste into
+// a change ticket: the preflight checks, then every step with its notes,
+// checks, and warnings, then the warnings on the plan as a whole
+func renderPlanText(plan *planner.Plan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Upgrade plan for %s: %d steps\n", plan.Platform, len(plan.Steps))
+	if plan.Meta != nil {
+		fmt.Fprintf(&b, "Planned %s with data %s, strategy %s\n", plan.Meta.GeneratedAt.Format("2006-01-02 15:04 MST"), plan.Meta.DatasetHash, plan.Meta.Strategy)
+	}
+	if plan.Effort != nil {
+		fmt.Fprintf(&b, "Estimated effort: %.1f engineer-hours\n", plan.Effort.Hours)
+	}
+
+	if len(plan.Preflight) > 0 {
+		b.WriteString("\nBefore the first step:\n")
+		for _, check := range plan.Preflight {
+			fmt.Fprintf(&b, "[ ] %s\n", check.Description)
+			for _, cmd := range check.Commands {
+				fmt.Fprintf(&b, "      $ %s\n", cmd)
+			}
+		}
+	}
+
+	if len(plan.Steps) == 0 {
+		b.WriteString("\nThe cluster is up to date, there is nothing to upgrade.\n")
+	} else {
+		b.WriteString("\nSteps:\n")
+	}
+	for i, s := range plan.Steps {
+		fmt.Fprintf(&b, "[ ] %d. %s\n", i+1, stepTitle(s))
+		if len(s.DependsOn) > 0 {
+			fmt.Fprintf(&b, "      After: %s\n", strings.Join(s.DependsOn, ", "))
+		}
+		if s.SupportPhase != "" {
+			fmt.Fprintf(&b, "      Support phase: %s\n", s.SupportPhase)
+		}
+		for _, n := range s.Notes {
+			fmt.Fprintf(&b, "      Note: %s\n", n)
+		}
+		for _, w := range plan.Warnings {
+			if w.Step == i {
+				fmt.Fprintf(&b, "      Warning [%s]: %s\n", w.Rule, w.Message)
+			}
+		}
+		for _, v := range s.Verify {
+			fmt.Fprintf(&b, "      [ ] Verify: %s\n", v)
+		}
+	}
+
+	if general := generalWarnings(plan); len(general) > 0 {
+		b.WriteString("\nWarnings:\n")
+		for _, w := range general {
+			fmt.Fprintf(&b, "- [%s] %s\n", w.Rule, w.Message)
+		}
+	}
+	return b.String()
+}
+
+// stepTitle describes what a step upgrades, e.g. "Upgrade rke2 Kubernetes
+// from v1.26 to v1.27"
+func stepTitle(s planner.UpgradeStep) string {
+	if s.Type == "Migration" {
+		return fmt.Sprintf("Migrate to %s %s from Kubernetes %s", s.Platform, s.To, s.From)
+	}
+	return fmt.Sprintf("Upgrade %s from %s to %s", strings.TrimSpace(s.Platform+" "+s.Type), s.From, s.To)
+}
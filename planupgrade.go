@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// planUpgradeRequest is a plan request with the options of the service, and
+// the body of POST /api/plan-upgrade
+type planUpgradeRequest struct {
+	planner.Request
+	Changelog string `json:"changelog,omitempty"` // Release notes to attach, rancher or all
+}
+
+// planUpgradeParams reads the plan request of GET /api/plan-upgrade from the
+// route and query parameters
+func planUpgradeParams(c *fiber.Ctx) (planUpgradeRequest, error) {
+	req, err := planRequest(c)
+	return planUpgradeRequest{Request: req, Changelog: c.Query("changelog")}, err
+}
+
+// planUpgradeBody reads the plan request of POST /api/plan-upgrade from the
+// JSON body. Versions with suffixes such as +rke2r1 need no escaping there.
+func planUpgradeBody(c *fiber.Ctx) (planUpgradeRequest, error) {
+	var req planUpgradeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return req, fmt.Errorf("invalid request body: %v", err)
+	}
+	if req.Platform == "" || req.CurrentRancher == "" || req.CurrentK8s == "" {
+		return req, errors.New("platform, current_rancher, and current_k8s are required")
+	}
+	if req.Language == "" {
+		req.Language = requestLanguage(c)
+	}
+	return req, nil
+}
+
+// handlePlanUpgrade generates the upgrade plan of the request read by parse,
+// in the format chosen with ?format= or the Accept header
+func handlePlanUpgrade(ds *dataset, parse func(*fiber.Ctx) (planUpgradeRequest, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Increment active requests gauge
+		activeRequests.Inc()
+		defer activeRequests.Dec()
+
+		// Handle request timestamps for sliding window
+		updateRequestTimestamps()
+
+		format, err := planFormat(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		req, err := parse(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		// Increment versions submitted counter
+		versionsSubmitted.WithLabelValues(req.Platform, req.CurrentRancher, req.CurrentK8s).Inc()
+		noteUsage(c, req.Request)
+
+		if req.Changelog != "" && req.Changelog != changelogRancher && req.Changelog != changelogAll {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid changelog %q, expected %s or %s", req.Changelog, changelogRancher, changelogAll),
+			})
+		}
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		upgradePlanner, paths := ds.Current()
+		plan, err := upgradePlanner.PlanContext(ctx, req.Request)
+		recordPlanOutcome(err)
+		recordPlan(c, ds, upgradePlanner, paths, req.Request, plan, err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+
+		if req.Changelog != "" {
+			hits, misses := attachChangelogs(ctx, plan, paths, req.Changelog)
+			noteChangelogLookups(c, hits, misses)
+		}
+
+		c.Set(fiber.HeaderContentLanguage, plan.Meta.Language)
+		return sendPlan(c, plan, format)
+	}
+}
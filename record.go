@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner/fixture"
+)
+
+// Record mode: with PLAN_RECORD_DIR set, every plan request is written to the
+// directory with the options and outcome it was planned with, and every
+// dataset planned with is kept once under datasets/, so that the replay
+// command can plan the request again with the current code
+var planRecordDir = envString("PLAN_RECORD_DIR", "")
+
+// planRecording is a recorded plan request
+type planRecording struct {
+	RecordedAt time.Time       `json:"recorded_at"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Route      string          `json:"route"`
+	Request    planner.Request `json:"request"`
+	Dataset    dataProvenance  `json:"dataset"`
+	Options    recordedOptions `json:"options"`
+	Plan       *planner.Plan   `json:"plan,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// recordedOptions are the planner options a recording was planned with,
+// without the logger
+type recordedOptions struct {
+	Prereleases planner.VersionPolicy `json:"prerelease_policy,omitempty"`
+	Hotfixes    planner.VersionPolicy `json:"hotfix_policy,omitempty"`
+	Aliases     map[string]string     `json:"aliases,omitempty"`
+	Effort      planner.EffortModel   `json:"effort"`
+	Policy      *planner.Policy       `json:"policy,omitempty"`
+	Blackouts   []planner.Blackout    `json:"blackouts,omitempty"`
+	// Today is the date a request without a planned date was planned on, as
+	// YYYY-MM-DD, so that replays plan it for the same day
+	Today string `json:"today,omitempty"`
+}
+
+// planner returns the options to plan the recording again with
+func (o recordedOptions) planner() planner.Options {
+	opts := planner.Options{
+		Prereleases: o.Prereleases,
+		Hotfixes:    o.Hotfixes,
+		Aliases:     o.Aliases,
+		Effort:      o.Effort,
+		Policy:      o.Policy,
+		Blackouts:   o.Blackouts,
+	}
+	// Recordings made before the date was kept are planned for today
+	if today, err := time.Parse("2006-01-02", o.Today); err == nil {
+		opts.Today = today
+	}
+	return opts
+}
+
+// recordPlan writes the request and its outcome to PLAN_RECORD_DIR when
+// recording is enabled. It must be called before the plan is changed for the
+// response, e.g. by attaching release notes.
+func recordPlan(c *fiber.Ctx, ds *dataset, p *planner.Planner, paths planner.UpgradePaths, req planner.Request, plan *planner.Plan, planErr error) {
+	if planRecordDir == "" {
+		return
+	}
+	_, provenance := ds.Snapshot()
+	provenance.Hash = p.DatasetHash()
+	id, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+	now := time.Now().UTC()
+	rec := planRecording{
+		RecordedAt: now,
+		RequestID:  id,
+		Route:      c.Route().Path,
+		Request:    req,
+		Dataset:    provenance,
+		Options: recordedOptions{
+			Prereleases: ds.opts.Prereleases,
+			Hotfixes:    ds.opts.Hotfixes,
+			Aliases:     ds.opts.Aliases,
+			Effort:      ds.opts.Effort,
+			Policy:      ds.opts.Policy,
+			Blackouts:   ds.opts.Blackouts,
+			Today:       now.Format("2006-01-02"),
+		},
+		Plan: plan,
+	}
+	if planErr != nil {
+		rec.Error = planErr.Error()
+	}
+	if err := writeRecording(rec, paths); err != nil {
+		log.Printf("Failed to record plan request %s: %v", id, err)
+	}
+}
+
+// writeRecording writes the recording, and the dataset unless it is already
+// kept
+func writeRecording(rec planRecording, paths planner.UpgradePaths) error {
+	datasets := filepath.Join(planRecordDir, "datasets")
+	if err := os.MkdirAll(datasets, 0o755); err != nil {
+		return err
+	}
+	snapshot := filepath.Join(datasets, snapshotName(rec.Dataset.Hash))
+	if _, err := os.Stat(snapshot); os.IsNotExist(err) {
+		data, err := json.Marshal(paths)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(snapshot, data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := rec.RecordedAt.Format("20060102T150405.000000000Z")
+	if id := fileSafeID(rec.RequestID); id != "" {
+		name += "-" + id
+	}
+	return os.WriteFile(filepath.Join(planRecordDir, name+".json"), append(data, '\n'), 0o644)
+}
+
+// fileSafeID returns the request ID reduced to letters, digits and dashes,
+// and at most 64 characters long. The ID comes from the client's
+// X-Request-ID header, so it must not be able to name another path.
+func fileSafeID(id string) string {
+	id = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return -1
+	}, id)
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	return id
+}
+
+// snapshotName is the file name a dataset with the hash is kept under
+func snapshotName(hash string) string {
+	return strings.ReplaceAll(hash, ":", "-") + ".json"
+}
+
+// runReplay plans recorded requests again with the current code and reports
+// every outcome that differs from the recorded one
+func runReplay(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	data := fs.String("data", "", "dataset to plan against; empty uses the dataset kept with each recording")
+	verbose := fs.Bool("v", false, "print how each changed plan changes")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: rancher-upgrade-tool replay [-data file] [-v] <recording or directory>...")
+		return 2
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		files = append(files, matches...)
+	}
+
+	var override *planner.UpgradePaths
+	if *data != "" {
+		paths, err := loadUpgradePathsFile(*data)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		override = &paths
+	}
+
+	changed := 0
+	for _, file := range files {
+		diff, err := replayFile(file, override)
+		switch {
+		case err != nil:
+			changed++
+			fmt.Fprintf(out, "ERROR   %s: %v\n", file, err)
+		case diff == "":
+			fmt.Fprintf(out, "SAME    %s\n", file)
+		default:
+			changed++
+			fmt.Fprintf(out, "CHANGED %s\n", file)
+			if *verbose {
+				fmt.Fprintln(out, diff)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d recordings, %d changed\n", len(files), changed)
+	if changed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replayFile plans a recording again, against its kept dataset unless paths
+// is given, and returns a line diff of the recorded and current outcomes
+func replayFile(file string, paths *planner.UpgradePaths) (string, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var rec planRecording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return "", fmt.Errorf("failed to parse recording: %v", err)
+	}
+	if paths == nil {
+		snapshot := filepath.Join(filepath.Dir(file), "datasets", snapshotName(rec.Dataset.Hash))
+		kept, err := loadUpgradePathsFile(snapshot)
+		if err != nil {
+			return "", fmt.Errorf("dataset %s of the recording is not kept, replay it with -data: %v", rec.Dataset.Hash, err)
+		}
+		paths = &kept
+	}
+
+	plan, planErr := planner.New(*paths, rec.Options.planner()).Plan(rec.Request)
+	return fixture.Diff(recordedOutcome(rec.Plan, rec.Error), recordedOutcome(plan, errString(planErr))), nil
+}
+
+// recordedOutcome renders a plan without its metadata, or the error
+func recordedOutcome(plan *planner.Plan, planErr string) string {
+	if planErr != "" {
+		return "error: " + planErr
+	}
+	if plan == nil {
+		return ""
+	}
+	stripped := *plan
+	stripped.Meta = nil
+	data, err := json.MarshalIndent(stripped, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// errString returns the error's message, or "" for nil
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
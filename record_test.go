@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// TestFileSafeID checks that request IDs cannot name paths outside the
+// record directory
+func TestFileSafeID(t *testing.T) {
+	for id, expected := range map[string]string{
+		"":                          "",
+		"3f2c-11ee":                 "3f2c-11ee",
+		"../../etc/passwd":          "etcpasswd",
+		`..\windows\system32`:       "windowssystem32",
+		"id with spaces/and:colons": "idwithspacesandcolons",
+		strings.Repeat("a", 100):    strings.Repeat("a", 64),
+	} {
+		if got := fileSafeID(id); got != expected {
+			t.Errorf("fileSafeID(%q) = %q, expected %q", id, got, expected)
+		}
+	}
+}
+
+// recordOne plans the request through a route recording it, and returns the
+// path of the recording
+func recordOne(t *testing.T, ds *dataset, req planner.Request, requestID string) string {
+	t.Helper()
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Get("/api/plan-upgrade", func(c *fiber.Ctx) error {
+		p, paths := ds.Current()
+		plan, err := p.Plan(req)
+		recordPlan(c, ds, p, paths, req, plan, err)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	httpReq := httptest.NewRequest(fiber.MethodGet, "/api/plan-upgrade", nil)
+	httpReq.Header.Set(fiber.HeaderXRequestID, requestID)
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	files, err := filepath.Glob(filepath.Join(planRecordDir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("recordings %v (%v), expected one", files, err)
+	}
+	return files[0]
+}
+
+// TestRecordReplay records a plan request, checks what the recording holds,
+// and replays it against the kept dataset without changes
+func TestRecordReplay(t *testing.T) {
+	defer func(dir string) { planRecordDir = dir }(planRecordDir)
+	planRecordDir = t.TempDir()
+	ds := testDataset(t)
+
+	req := planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9+rke2r1"}
+	file := recordOne(t, ds, req, "../evil id")
+	if !strings.HasSuffix(file, "-evilid.json") {
+		t.Errorf("recording %s is not named after the sanitized request ID", file)
+	}
+
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec planRecording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Route != "/api/plan-upgrade" || rec.RequestID != "../evil id" || rec.Plan == nil || rec.Error != "" {
+		t.Fatalf("recording has route %q, request ID %q, plan %v, error %q", rec.Route, rec.RequestID, rec.Plan != nil, rec.Error)
+	}
+	if today := time.Now().UTC().Format("2006-01-02"); rec.Options.Today != today {
+		t.Errorf("recording planned on %q, expected %s", rec.Options.Today, today)
+	}
+	if _, err := os.Stat(filepath.Join(planRecordDir, "datasets", snapshotName(rec.Dataset.Hash))); err != nil {
+		t.Fatalf("dataset of the recording is not kept: %v", err)
+	}
+
+	diff, err := replayFile(file, nil)
+	if err != nil || diff != "" {
+		t.Fatalf("replay returned %v with diff:\n%s", err, diff)
+	}
+}
+
+// TestReplayRecordedDay checks that a request without a planned date is
+// replayed for the day it was recorded, not the day of the replay
+func TestReplayRecordedDay(t *testing.T) {
+	defer func(dir string) { planRecordDir = dir }(planRecordDir)
+	planRecordDir = t.TempDir()
+	ds := testDataset(t)
+
+	req := planner.Request{Platform: "rke2", CurrentRancher: "2.7.5", CurrentK8s: "v1.25.9+rke2r1"}
+	file := recordOne(t, ds, req, "")
+
+	// Rewrite the recording as if made on a day 2.7 was still maintained,
+	// when its support phases differed from today's
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec planRecording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		t.Fatal(err)
+	}
+	day, _ := time.Parse("2006-01-02", "2023-06-01")
+	rec.Options.Today = "2023-06-01"
+	rec.Plan, err = planner.New(ds.paths, planner.Options{Today: day}).Plan(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func() {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write()
+	if diff, err := replayFile(file, nil); err != nil || diff != "" {
+		t.Fatalf("replay returned %v with diff:\n%s", err, diff)
+	}
+
+	// Without the day, the replay plans for today and reports a change
+	rec.Options.Today = ""
+	write()
+	if diff, err := replayFile(file, nil); err != nil || diff == "" {
+		t.Fatalf("replay without the recorded day returned %v and no diff, expected the support phases to differ", err)
+	}
+}
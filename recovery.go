@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// Panics are also POSTed to ERROR_REPORT_URL when it is set, e.g. an
+// ingestion webhook of the team's error tracker
+var errorReportURL = envString("ERROR_REPORT_URL", "")
+
+// panicReport describes a panic recovered while handling a request
+type panicReport struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Route     string    `json:"route,omitempty"`
+	Query     string    `json:"query,omitempty"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+}
+
+// errorReporter receives recovered panics, e.g. to forward them to Sentry or
+// Rollbar. Reporters are called on the request's goroutine and should not
+// block.
+type errorReporter interface {
+	Report(r panicReport)
+}
+
+var (
+	reportersMu sync.RWMutex
+	reporters   []errorReporter
+)
+
+// registerErrorReporter adds a reporter receiving every recovered panic
+func registerErrorReporter(r errorReporter) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters = append(reporters, r)
+}
+
+// reportPanic logs the panic and passes it to every registered reporter
+func reportPanic(r panicReport) {
+	where := ""
+	if r.RequestID != "" {
+		where = fmt.Sprintf(" handling request %s (%s %s)", r.RequestID, r.Method, r.Path)
+	}
+	log.Printf("Panic%s: %s\n%s", where, r.Panic, r.Stack)
+	reportersMu.RLock()
+	defer reportersMu.RUnlock()
+	for _, reporter := range reporters {
+		reporter.Report(r)
+	}
+}
+
+// webhookReporter queues every panic for delivery to a URL as a "panic" event
+type webhookReporter struct {
+	url string
+}
+
+func (w webhookReporter) Report(r panicReport) {
+	if err := webhooks.Enqueue("panic", w.url, r); err != nil {
+		log.Printf("Reporting panic %s to %s failed: %v", r.RequestID, w.url, err)
+	}
+}
+
+// recoverPanics is middleware turning a handler panic into a 500 response
+// carrying the request ID, and reporting it with its stack trace
+func recoverPanics(c *fiber.Ctx) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		id, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+		reportPanic(panicReport{
+			Time:      time.Now().UTC(),
+			RequestID: id,
+			Method:    strings.Clone(c.Method()),
+			Path:      strings.Clone(c.Path()),
+			Route:     c.Route().Path,
+			Query:     string(c.Request().URI().QueryString()),
+			Panic:     fmt.Sprint(r),
+			Stack:     string(debug.Stack()),
+		})
+		err = c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":      "internal error",
+			"request_id": id,
+		})
+	}()
+	return c.Next()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// TestRecoverPanics checks that a handler panic is answered with a JSON 500
+// carrying the request ID, and reported with the request and its stack
+func TestRecoverPanics(t *testing.T) {
+	panics := recordPanics(t)
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(recoverPanics)
+	app.Get("/boom/:name", func(c *fiber.Ctx) error { panic("handler bug") })
+	app.Get("/fine", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/fine", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusNoContent || len(panics.reports) != 0 {
+		t.Fatalf("got %d with %d panics reported, expected 204 with none", resp.StatusCode, len(panics.reports))
+	}
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/boom/a?b=c", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Fatalf("got %d, expected 500", resp.StatusCode)
+	}
+	var body struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	id := resp.Header.Get(fiber.HeaderXRequestID)
+	if id == "" || body.RequestID != id || body.Error == "" {
+		t.Fatalf("body %+v, expected an error with the request ID %q", body, id)
+	}
+
+	if len(panics.reports) != 1 {
+		t.Fatalf("%d panics reported, expected 1", len(panics.reports))
+	}
+	r := panics.reports[0]
+	expected := panicReport{Time: r.Time, RequestID: id, Method: fiber.MethodGet, Path: "/boom/a", Route: "/boom/:name", Query: "b=c", Panic: "handler bug", Stack: r.Stack}
+	if r != expected {
+		t.Errorf("reported %+v, expected %+v", r, expected)
+	}
+	if r.Time.IsZero() || !strings.Contains(r.Stack, "recovery_test.go") {
+		t.Errorf("reported at %v with stack %q, expected the time and the handler's stack", r.Time, r.Stack)
+	}
+}
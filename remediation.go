@@ -0,0 +1,15 @@
+package main
+
+import "github.com/gofiber/fiber/v2"
+
+// handlePatchRemediation recommends the newest Kubernetes patch the running
+// Rancher supports and the advisories it fixes
+func handlePatchRemediation(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rec, err := ds.Planner().PatchRemediation(c.Params("platform"), c.Params("rancher"), c.Params("k8s"))
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		return c.JSON(rec)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner/fixture"
+)
+
+// Scheduled re-planning of the clusters in WATCH_CLUSTERS_FILE, a batch
+// request body; it is disabled without the file
+var (
+	watchClustersFile = envString("WATCH_CLUSTERS_FILE", "")
+	replanInterval    = envDuration("REPLAN_INTERVAL", 6*time.Hour)
+	replanNotifyURL   = envString("REPLAN_NOTIFY_URL", "")
+)
+
+// watchedPlan is the latest plan of a watched cluster
+type watchedPlan struct {
+	Cluster   string        `json:"cluster"`
+	Revision  int           `json:"revision"` // Incremented every time the plan changes
+	ChangedAt time.Time     `json:"changed_at"`
+	Plan      *planner.Plan `json:"plan,omitempty"`
+	Error     string        `json:"error,omitempty"`
+
+	rendered string // Compared against the next plan
+}
+
+// planChange is the notification sent when the plan of a watched cluster changes
+type planChange struct {
+	Cluster     string        `json:"cluster"`
+	Revision    int           `json:"revision"`
+	DatasetHash string        `json:"dataset_hash,omitempty"`
+	Diff        string        `json:"diff"` // Line diff of the previous and the new plan
+	Plan        *planner.Plan `json:"plan,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// replanner periodically plans the watched clusters and notifies about the
+// plans that changed since the previous run. Plans are kept in memory, so the
+// first run after a restart sets the baseline without notifying.
+type replanner struct {
+	ds        *dataset
+	clusters  []batchCluster
+	notifyURL string
+
+	mu      sync.Mutex
+	plans   map[string]*watchedPlan
+	lastRun time.Time // When the last run finished
+	failed  int       // Clusters the last run could not plan
+}
+
+// loadWatchedClusters reads the clusters to watch from a batch request file.
+// Every cluster needs a unique name to match its plans across runs.
+func loadWatchedClusters(path string) ([]batchCluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var req batchRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid watched clusters in %s: %v", path, err)
+	}
+	seen := make(map[string]bool)
+	for i, cluster := range req.Clusters {
+		if cluster.Name == "" {
+			return nil, fmt.Errorf("watched cluster #%d in %s has no name", i, path)
+		}
+		if seen[cluster.Name] {
+			return nil, fmt.Errorf("watched cluster %q is listed twice in %s", cluster.Name, path)
+		}
+		seen[cluster.Name] = true
+	}
+	return req.Clusters, nil
+}
+
+// newReplanner creates a replanner for the clusters
+func newReplanner(ds *dataset, clusters []batchCluster, notifyURL string) *replanner {
+	return &replanner{ds: ds, clusters: clusters, notifyURL: notifyURL, plans: make(map[string]*watchedPlan)}
+}
+
+// run re-plans right away and then every interval until ctx is done
+func (r *replanner) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.replan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replan plans every watched cluster with the current data
+func (r *replanner) replan(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	failed := 0
+	planBatch(ctx, r.ds.Planner(), r.clusters, batchWorkers, func(res batchResult) bool {
+		if res.Error != "" {
+			failed++
+		}
+		r.record(res)
+		return true
+	})
+
+	r.mu.Lock()
+	r.lastRun, r.failed = time.Now().UTC(), failed
+	r.mu.Unlock()
+}
+
+// health reports the last run, degraded when none finished in the last two
+// intervals
+func (r *replanner) health(interval time.Duration) componentHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := componentHealth{Status: healthOK, Details: map[string]interface{}{
+		"clusters":        len(r.clusters),
+		"last_run":        r.lastRun,
+		"failed_clusters": r.failed,
+	}}
+	if time.Since(r.lastRun) > 2*interval {
+		h.Status = healthDegraded
+	}
+	return h
+}
+
+// record stores the result when it differs from the previous plan of the
+// cluster, and sends a notification unless it is the first plan
+func (r *replanner) record(res batchResult) {
+	rendered := renderWatchedPlan(res)
+
+	r.mu.Lock()
+	prev := r.plans[res.Name]
+	if prev != nil && prev.rendered == rendered {
+		r.mu.Unlock()
+		return
+	}
+	current := &watchedPlan{
+		Cluster:   res.Name,
+		Revision:  1,
+		ChangedAt: time.Now().UTC(),
+		Plan:      res.Plan,
+		Error:     res.Error,
+		rendered:  rendered,
+	}
+	if prev != nil {
+		current.Revision = prev.Revision + 1
+	}
+	r.plans[res.Name] = current
+	r.mu.Unlock()
+
+	if prev == nil {
+		return
+	}
+	change := planChange{
+		Cluster:  res.Name,
+		Revision: current.Revision,
+		Diff:     fixture.Diff(prev.rendered, rendered),
+		Plan:     res.Plan,
+		Error:    res.Error,
+	}
+	if res.Plan != nil && res.Plan.Meta != nil {
+		change.DatasetHash = res.Plan.Meta.DatasetHash
+	}
+	log.Printf("Plan of watched cluster %s changed (revision %d)", res.Name, current.Revision)
+	if err := r.notify(change); err != nil {
+		log.Printf("Error notifying about the plan of watched cluster %s: %v", res.Name, err)
+	}
+}
+
+// notify queues the change for delivery to the notification URL, if one is set
+func (r *replanner) notify(change planChange) error {
+	if r.notifyURL == "" {
+		return nil
+	}
+	return webhooks.Enqueue("plan.changed", r.notifyURL, change)
+}
+
+// Plans returns the latest plan of every watched cluster, ordered by name
+func (r *replanner) Plans() []watchedPlan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plans := make([]watchedPlan, 0, len(r.plans))
+	for _, p := range r.plans {
+		plans = append(plans, *p)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Cluster < plans[j].Cluster })
+	return plans
+}
+
+// renderWatchedPlan renders the result for comparison, leaving out the
+// metadata that changes on every run
+func renderWatchedPlan(res batchResult) string {
+	if res.Plan == nil {
+		return "error: " + res.Error
+	}
+	plan := *res.Plan
+	plan.Meta = nil
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(data)
+}
+
+// handleWatchedPlans returns the latest plan of every watched cluster
+func handleWatchedPlans(r *replanner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"clusters": r.Plans()})
+	}
+}
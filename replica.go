@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// Replica mode: with REPLICA_OF set to the URL of a primary instance, the
+// compatibility data is synced from the primary's data export instead of
+// being read from the local file. DATA_EXPORT_TOKEN on the primary restricts
+// the export to callers sending it, as replicas do with REPLICA_TOKEN, which
+// replicas require.
+var (
+	replicaOf           = strings.TrimSuffix(envString("REPLICA_OF", ""), "/")
+	replicaToken        = envString("REPLICA_TOKEN", "")
+	replicaSyncInterval = envDuration("REPLICA_SYNC_INTERVAL", 5*time.Minute)
+	dataExportToken     = envString("DATA_EXPORT_TOKEN", "")
+)
+
+// replica keeps the dataset in sync with a primary instance
+type replica struct {
+	ds      *dataset
+	primary string
+	token   string
+
+	mu       sync.Mutex
+	lastSync time.Time // Last successful sync, whether or not the data changed
+	lastErr  error     // Error of the last attempt
+}
+
+// newReplica returns a replica syncing the dataset from the primary with the
+// token, which is required so the primary's data is never served unguarded
+func newReplica(ds *dataset, primary, token string) (*replica, error) {
+	if token == "" {
+		return nil, fmt.Errorf("REPLICA_OF is set without REPLICA_TOKEN; set it to the DATA_EXPORT_TOKEN of %s", primary)
+	}
+	return &replica{ds: ds, primary: primary, token: token}, nil
+}
+
+// run syncs the data right away and then every interval until ctx is done
+func (r *replica) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := r.sync(ctx)
+		if err != nil {
+			log.Printf("Replica sync from %s failed, keeping the current data: %v", r.primary, err)
+		}
+		r.mu.Lock()
+		r.lastErr = err
+		if err == nil {
+			r.lastSync = time.Now().UTC()
+		}
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync fetches the primary's data and swaps it in when its hash differs from
+// the current one. The data must hash to the value the primary reports. The
+// primary answers 304 without the data while it has the current one.
+func (r *replica) sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.primary+"/api/data/export", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", fiber.MIMEApplicationJSON)
+	req.Header.Set("If-None-Match", datasetETag(r.ds.Planner().DatasetHash()))
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching the data export: %s", resp.Status)
+	}
+	var export struct {
+		Provenance dataProvenance       `json:"provenance"`
+		Data       planner.UpgradePaths `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return fmt.Errorf("decoding the data export: %v", err)
+	}
+
+	if export.Provenance.Hash == r.ds.Planner().DatasetHash() {
+		return nil
+	}
+	if err := export.Data.Validate(); err != nil {
+		return fmt.Errorf("the exported data is not valid: %v", err)
+	}
+	if hash := planner.New(export.Data, planner.Options{}).DatasetHash(); hash != export.Provenance.Hash {
+		return fmt.Errorf("the exported data hashes to %s, not %s as the primary reports", hash, export.Provenance.Hash)
+	}
+	r.ds.Replace(export.Data, r.primary)
+	log.Printf("Replica synced data %s from %s", export.Provenance.Hash, r.primary)
+	return nil
+}
+
+// health reports the last sync, degraded when the last attempt failed
+func (r *replica) health() componentHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := componentHealth{Status: healthOK, Details: map[string]interface{}{
+		"primary":   r.primary,
+		"last_sync": r.lastSync,
+	}}
+	if r.lastErr != nil {
+		h.Status = healthDegraded
+		h.Details["error"] = r.lastErr.Error()
+	}
+	return h
+}
+
+// requireExportToken rejects requests without the DATA_EXPORT_TOKEN bearer
+// token when one is configured
+func requireExportToken(c *fiber.Ctx) error {
+	if dataExportToken == "" {
+		return c.Next()
+	}
+	if !hasBearerToken(c, dataExportToken) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "missing or invalid export token",
+		})
+	}
+	return c.Next()
+}
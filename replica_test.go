@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestNewReplicaRequiresToken checks that a replica is only created with the
+// token of the primary's data export
+func TestNewReplicaRequiresToken(t *testing.T) {
+	ds := testDataset(t)
+	if _, err := newReplica(ds, "http://primary", ""); err == nil || !strings.Contains(err.Error(), "REPLICA_TOKEN") {
+		t.Fatalf("error %v, expected REPLICA_TOKEN to be required", err)
+	}
+	if _, err := newReplica(ds, "http://primary", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReplicaSync checks that a replica swaps in the primary's data when it
+// differs, keeps its data when the primary answers 304, and refuses data
+// not hashing to the value the primary reports or an unauthorized sync
+func TestReplicaSync(t *testing.T) {
+	defer func(token string) { dataExportToken = token }(dataExportToken)
+	dataExportToken = "secret"
+
+	primaryData := testDataset(t)
+	primaryHash := primaryData.Planner().DatasetHash()
+
+	// The primary serves its export, or with tampered set, its data with a
+	// hash it does not have
+	var tampered atomic.Bool
+	var status atomic.Int32
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+		status.Store(int32(c.Response().StatusCode()))
+		return err
+	})
+	app.Get("/api/data/export", requireExportToken, func(c *fiber.Ctx) error {
+		if !tampered.Load() {
+			return c.Next()
+		}
+		paths, provenance := primaryData.Snapshot()
+		provenance.Hash = "sha256:0000"
+		return c.JSON(fiber.Map{"provenance": provenance, "data": paths})
+	}, conditionalOnDataset(primaryData), handleDataExport(primaryData))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+	primary := "http://" + ln.Addr().String()
+
+	tests := []struct {
+		name     string
+		stale    bool // Whether the replica's data differs from the primary's
+		token    string
+		tampered bool
+		status   int
+		err      string
+	}{
+		{name: "stale data", stale: true, token: "secret", status: fiber.StatusOK},
+		{name: "current data", token: "secret", status: fiber.StatusNotModified},
+		{name: "hash mismatch", stale: true, token: "secret", tampered: true, status: fiber.StatusOK, err: "hashes to"},
+		{name: "wrong token", stale: true, token: "guess", status: fiber.StatusUnauthorized, err: "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered.Store(tt.tampered)
+			ds := testDataset(t)
+			if tt.stale {
+				paths, _ := ds.Snapshot()
+				paths.Version = "stale"
+				ds.Replace(paths, "stale")
+			}
+			hashBefore := ds.Planner().DatasetHash()
+			r, err := newReplica(ds, primary, tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.sync(context.Background())
+			if got := int(status.Load()); got != tt.status {
+				t.Errorf("primary answered %d, expected %d", got, tt.status)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, expected one containing %q", err, tt.err)
+				}
+				if hash := ds.Planner().DatasetHash(); hash != hashBefore {
+					t.Fatalf("data replaced with %s after a failed sync", hash)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash := ds.Planner().DatasetHash(); hash != primaryHash {
+				t.Fatalf("replica has data %s, expected the primary's %s", hash, primaryHash)
+			}
+			_, provenance := ds.Snapshot()
+			if synced := provenance.Source == primary; synced != tt.stale {
+				t.Fatalf("data from %s, expected it replaced only when stale", provenance.Source)
+			}
+		})
+	}
+}
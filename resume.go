@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// resumeRequest is the request body of /api/plan-resume
+type resumeRequest struct {
+	// Request is the request the original plan was made with
+	Request planner.Request `json:"request"`
+	// Plan is the original plan, as returned by the service
+	Plan planner.Plan `json:"plan"`
+	// Completed is the ID of the last completed step of the original plan
+	Completed string `json:"completed"`
+}
+
+// resumeResponse is the remaining plan of a partially executed upgrade
+type resumeResponse struct {
+	// Plan is the remainder recomputed against the current data
+	Plan *planner.Plan `json:"plan"`
+	// Changed reports whether the remainder differs from the steps left in
+	// the original plan
+	Changed bool `json:"changed"`
+	// Original are the steps the original plan had left
+	Original []planner.UpgradeStep `json:"original_remaining"`
+}
+
+// resumedRequest returns the request for the state the cluster is in after
+// the steps up to and including the completed one, and the steps left
+func resumedRequest(req planner.Request, steps []planner.UpgradeStep, completed string) (planner.Request, []planner.UpgradeStep, error) {
+	for i, step := range steps {
+		switch step.Type {
+		case "Rancher":
+			req.CurrentRancher = step.To
+		case "Kubernetes":
+			req.CurrentK8s = step.To
+		case "OS":
+			req.NodeOSVersion = step.To
+		case "Docker":
+			req.DockerVersion = step.To
+			if req.Facts[planner.DockerFact] != "" {
+				facts := make(map[string]string, len(req.Facts))
+				for name, v := range req.Facts {
+					facts[name] = v
+				}
+				facts[planner.DockerFact] = step.To
+				req.Facts = facts
+			}
+		case "cert-manager":
+			facts := make(map[string]string, len(req.Facts)+1)
+			for name, v := range req.Facts {
+				facts[name] = v
+			}
+			facts[planner.CertManagerFact] = step.To
+			req.Facts = facts
+		case "Migration":
+			req.Platform = step.Platform
+			req.CurrentK8s = step.To
+			req.MigrateTo = ""
+		}
+		if step.ID == completed {
+			return req, steps[i+1:], nil
+		}
+	}
+	return req, nil, fmt.Errorf("the plan has no step %q", completed)
+}
+
+// sameSteps reports whether the steps go through the same upgrades
+func sameSteps(a, b []planner.UpgradeStep) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].To != b[i].To {
+			return false
+		}
+	}
+	return true
+}
+
+// handleResume recomputes the rest of a partially executed plan against the
+// current data, flagging when it no longer matches the original remainder,
+// so multi-window upgrades pick up new releases and data fixes as they go
+func handleResume(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body resumeRequest
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid request body: %v", err),
+			})
+		}
+		req, remaining, err := resumedRequest(body.Request, body.Plan.Steps, body.Completed)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if req.Language == "" {
+			req.Language = requestLanguage(c)
+		}
+		noteUsage(c, req)
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		plan, err := ds.Planner().PlanContext(ctx, req)
+		recordPlanOutcome(err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+
+		if remaining == nil {
+			remaining = []planner.UpgradeStep{}
+		}
+		return c.JSON(resumeResponse{
+			Plan:     plan,
+			Changed:  !sameSteps(remaining, plan.Steps),
+			Original: remaining,
+		})
+	}
+}
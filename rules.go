@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Alert thresholds of the generated rules
+var (
+	alertDatasetMaxAge = envDuration("ALERT_DATASET_MAX_AGE", 7*24*time.Hour)
+	alertErrorRatio    = envFloat("ALERT_ERROR_RATIO", 0.05)
+)
+
+// ruleGroup is a group of a Prometheus rules file
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+// rule is a recording or alerting rule
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// recordErrorRatio is the recorded share of plan requests that did not produce a plan
+const recordErrorRatio = "rancher_upgrade_tool:plan_error_ratio:rate5m"
+
+// prometheusRules returns the recording and alerting rules for the service's
+// metrics with the configured thresholds
+func prometheusRules() []ruleGroup {
+	return []ruleGroup{
+		{
+			Name: "rancher-upgrade-tool.rules",
+			Rules: []rule{
+				{
+					Record: "rancher_upgrade_tool:plans:rate5m",
+					Expr:   fmt.Sprintf("sum by (outcome) (rate(%s[5m]))", metricPlansTotal),
+				},
+				{
+					Record: recordErrorRatio,
+					Expr: fmt.Sprintf(`sum(rate(%[1]s{outcome!="%[2]s"}[5m])) / sum(rate(%[1]s[5m]))`,
+						metricPlansTotal, outcomePlanned),
+				},
+			},
+		},
+		{
+			Name: "rancher-upgrade-tool.alerts",
+			Rules: []rule{
+				{
+					Alert:  "RancherUpgradeToolDatasetStale",
+					Expr:   fmt.Sprintf("time() - %s > %d", metricDatasetLoaded, int(alertDatasetMaxAge.Seconds())),
+					For:    "15m",
+					Labels: map[string]string{"severity": "warning"},
+					Annotations: map[string]string{
+						"summary":     "Compatibility data is stale",
+						"description": fmt.Sprintf("The upgrade tool has served the same compatibility data for more than %s; plans may miss newer Rancher and Kubernetes releases.", alertDatasetMaxAge),
+					},
+				},
+				{
+					Alert:  "RancherUpgradeToolHighErrorRate",
+					Expr:   fmt.Sprintf("%s > %g", recordErrorRatio, alertErrorRatio),
+					For:    "10m",
+					Labels: map[string]string{"severity": "warning"},
+					Annotations: map[string]string{
+						"summary":     "Many plan requests fail",
+						"description": fmt.Sprintf("More than %g%% of plan requests failed or timed out over the last 5 minutes.", alertErrorRatio*100),
+					},
+				},
+			},
+		},
+	}
+}
+
+// handlePrometheusRules serves the rules as a Prometheus rules file, or as a
+// PrometheusRule resource for the Prometheus Operator with ?format=prometheusrule
+func handlePrometheusRules(c *fiber.Ctx) error {
+	groups := prometheusRules()
+
+	var doc interface{}
+	switch format := c.Query("format", "rules"); format {
+	case "rules":
+		doc = map[string]interface{}{"groups": groups}
+	case "prometheusrule":
+		doc = map[string]interface{}{
+			"apiVersion": "monitoring.coreos.com/v1",
+			"kind":       "PrometheusRule",
+			"metadata": map[string]interface{}{
+				"name":   c.Query("name", "rancher-upgrade-tool"),
+				"labels": map[string]string{"app.kubernetes.io/name": "rancher-upgrade-tool"},
+			},
+			"spec": map[string]interface{}{"groups": groups},
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("unknown format %q, expected rules or prometheusrule", format),
+		})
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	c.Set(fiber.HeaderContentType, "application/yaml")
+	return c.Send(out.Bytes())
+}
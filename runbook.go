@@ -0,0 +1,169 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// defaultRunbookTemplate renders plans as Markdown runbooks
+//
+//go:embed templates/runbook.md.tmpl
+var defaultRunbookTemplate string
+
+// runbookTemplateDir holds *.tmpl files parsed after the default runbook
+// template: they may redefine its templates, such as "step", or define
+// guidance templates adding text to steps
+var runbookTemplateDir = envString("RUNBOOK_TEMPLATE_DIR", "")
+
+// runbook renders plans requested as Markdown, replaced at startup by the
+// template with the files of RUNBOOK_TEMPLATE_DIR
+var runbook = template.Must(loadRunbookTemplate(""))
+
+// upgradeDocs links the upgrade documentation of Rancher and each platform
+var upgradeDocs = map[string]string{
+	"rancher": "https://ranchermanager.docs.rancher.com/getting-started/installation-and-upgrade/install-upgrade-on-a-kubernetes-cluster/upgrades",
+	"rke1":    "https://rke.docs.rancher.com/upgrades",
+	"rke2":    "https://docs.rke2.io/upgrade",
+	"k3s":     "https://docs.k3s.io/upgrades",
+}
+
+// runbookData is what the "runbook" template renders
+type runbookData struct {
+	Plan *planner.Plan
+}
+
+// runbookStep is what the "step" template renders
+type runbookStep struct {
+	Number   int // From 1
+	Step     planner.UpgradeStep
+	Warnings []planner.Warning // On the step
+}
+
+// runbookLink is a link listed with a step
+type runbookLink struct {
+	Title, URL string
+}
+
+// loadRunbookTemplate parses the default runbook template followed by the
+// *.tmpl files of the directory, if any. Guidance templates are named after
+// the step type, optionally followed by the platform, e.g.
+// "guidance:kubernetes:rke2" or "guidance:rancher", and are executed with
+// the step; the most specific one defined is used.
+func loadRunbookTemplate(dir string) (*template.Template, error) {
+	t := template.New("runbook")
+	t.Funcs(template.FuncMap{
+		"join":            strings.Join,
+		"title":           stepTitle,
+		"stepData":        runbookStepData,
+		"generalWarnings": generalWarnings,
+		"stepLinks":       stepLinks,
+		"guidance": func(s planner.UpgradeStep) (string, error) {
+			return stepGuidance(t, s)
+		},
+	})
+	if _, err := t.Parse(defaultRunbookTemplate); err != nil {
+		return nil, fmt.Errorf("failed to parse the default runbook template: %v", err)
+	}
+	if dir == "" {
+		return t, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.tmpl files in runbook template directory %s", dir)
+	}
+	if _, err := t.ParseFiles(files...); err != nil {
+		return nil, fmt.Errorf("failed to parse runbook templates: %v", err)
+	}
+	return t, nil
+}
+
+// renderRunbook renders the plan as a Markdown runbook
+func renderRunbook(plan *planner.Plan) (string, error) {
+	var b strings.Builder
+	if err := runbook.ExecuteTemplate(&b, "runbook", runbookData{Plan: plan}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// runbookStepData returns the data of the "step" template for step i
+func runbookStepData(data runbookData, i int) runbookStep {
+	s := runbookStep{Number: i + 1, Step: data.Plan.Steps[i]}
+	for _, w := range data.Plan.Warnings {
+		if w.Step == i {
+			s.Warnings = append(s.Warnings, w)
+		}
+	}
+	return s
+}
+
+// generalWarnings returns the warnings on the plan as a whole rather than on
+// one of its steps
+func generalWarnings(plan *planner.Plan) []planner.Warning {
+	var general []planner.Warning
+	for _, w := range plan.Warnings {
+		if w.Step < 0 || w.Step >= len(plan.Steps) {
+			general = append(general, w)
+		}
+	}
+	return general
+}
+
+// stepGuidance executes the most specific guidance template defined for the
+// step, returning "" when there is none
+func stepGuidance(t *template.Template, s planner.UpgradeStep) (string, error) {
+	names := []string{"guidance:" + strings.ToLower(s.Type)}
+	if s.Platform != "" {
+		names = append([]string{names[0] + ":" + strings.ToLower(s.Platform)}, names...)
+	}
+	for _, name := range names {
+		g := t.Lookup(name)
+		if g == nil {
+			continue
+		}
+		var b strings.Builder
+		if err := g.Execute(&b, s); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+	return "", nil
+}
+
+// stepLinks returns the release notes of the step's target, when it is a
+// release, and the upgrade documentation of what the step upgrades
+func stepLinks(s planner.UpgradeStep) []runbookLink {
+	var links []runbookLink
+	component := strings.ToLower(s.Platform)
+	if s.Type == "Rancher" {
+		component = "rancher"
+	} else if s.Type != "Kubernetes" {
+		return nil
+	}
+
+	if repo, ok := changelogRepositories[component]; ok {
+		tag := s.To
+		if component == "rancher" {
+			tag = "v" + strings.TrimPrefix(tag, "v")
+		}
+		// Kubernetes steps to a minor have no release to link
+		if component == "rancher" || strings.Contains(tag, "+") {
+			links = append(links, runbookLink{
+				Title: "Release notes of " + tag,
+				URL:   "https://github.com/" + repo + "/releases/tag/" + tag,
+			})
+		}
+	}
+	if docs, ok := upgradeDocs[component]; ok {
+		links = append(links, runbookLink{Title: "Upgrade documentation", URL: docs})
+	}
+	return links
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// handleSecurityDelta serves the security section of the plan for a cluster:
+// the advisories fixed and introduced between its current and final versions
+func handleSecurityDelta(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		plan, err := ds.Planner().PlanContext(ctx, planner.Request{
+			Platform:       c.Params("platform"),
+			CurrentRancher: c.Params("rancher"),
+			CurrentK8s:     c.Params("k8s"),
+			Strategy:       c.Query("strategy"),
+		})
+		recordPlanOutcome(err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		if plan.Security == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "the compatibility data lists no security advisories",
+			})
+		}
+		return c.JSON(plan.Security)
+	}
+}
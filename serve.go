@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// What to do when the metrics server cannot listen, set with
+// METRICS_FAILURE_MODE. A monitoring port conflict should not take down the
+// planner, so the default keeps the API up and retries.
+const (
+	metricsRetry    = "retry"    // Retry every METRICS_RETRY_INTERVAL
+	metricsDisable  = "disable"  // Serve the API without metrics
+	metricsShutdown = "shutdown" // Shut the API down cleanly as well
+)
+
+var (
+	metricsFailureMode   = envString("METRICS_FAILURE_MODE", metricsRetry)
+	metricsRetryInterval = envDuration("METRICS_RETRY_INTERVAL", 30*time.Second)
+	shutdownTimeout      = envDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+	// Addresses the API and the metrics are served on
+	apiAddr     = ":3000"
+	metricsAddr = ":9000"
+)
+
+// serve runs the API on port 3000 and the metrics on port 9000 until ctx is
+// done or the API fails, then shuts both down. Metrics failures are handled
+// according to METRICS_FAILURE_MODE.
+func serve(ctx context.Context, app, metricsApp *fiber.App) error {
+	switch metricsFailureMode {
+	case metricsRetry, metricsDisable, metricsShutdown:
+	default:
+		return fmt.Errorf("unknown METRICS_FAILURE_MODE %q, expected %s, %s, or %s", metricsFailureMode, metricsRetry, metricsDisable, metricsShutdown)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := listen(gctx, app, apiAddr); err != nil {
+			return fmt.Errorf("API server: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		return serveMetrics(gctx, metricsApp)
+	})
+	g.Go(func() error {
+		<-gctx.Done()
+		log.Printf("Shutting down")
+		for _, a := range []*fiber.App{app, metricsApp} {
+			if err := a.ShutdownWithTimeout(shutdownTimeout); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Shutdown: %v", err)
+			}
+		}
+		return nil
+	})
+	return g.Wait()
+}
+
+// serveMetrics serves the metrics app until ctx is done
+func serveMetrics(ctx context.Context, metricsApp *fiber.App) error {
+	for {
+		err := listen(ctx, metricsApp, metricsAddr)
+		if err == nil {
+			return nil
+		}
+		switch metricsFailureMode {
+		case metricsDisable:
+			log.Printf("Metrics server failed, serving without metrics: %v", err)
+			return nil
+		case metricsShutdown:
+			return fmt.Errorf("metrics server: %w", err)
+		}
+		log.Printf("Metrics server failed, retrying in %s: %v", metricsRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(metricsRetryInterval):
+		}
+	}
+}
+
+// listen serves the app on addr until ctx is done. Errors after that, such as
+// from closing the listener, are not reported.
+func listen(ctx context.Context, app *fiber.App, addr string) error {
+	ln, err := net.Listen(app.Config().Network, addr)
+	if err != nil {
+		return err
+	}
+	// The app may not be serving yet when it is shut down, so close the
+	// listener as well
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	if err := app.Listener(ln); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// servesMetrics reports whether the metrics app answers on addr
+func servesMetrics(addr string) bool {
+	resp, err := http.Get("http://" + addr + "/ping")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusNoContent
+}
+
+// TestServeMetricsFailure checks what each METRICS_FAILURE_MODE does when
+// the metrics port is taken: retry serves the metrics once it frees, disable
+// keeps the API up without them, and shutdown stops the API with an error
+func TestServeMetricsFailure(t *testing.T) {
+	defer func(mode, api, metrics string, interval time.Duration) {
+		metricsFailureMode, apiAddr, metricsAddr, metricsRetryInterval = mode, api, metrics, interval
+	}(metricsFailureMode, apiAddr, metricsAddr, metricsRetryInterval)
+	apiAddr = "127.0.0.1:0"
+	metricsRetryInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		mode    string
+		stops   bool   // Whether serve returns while the port is taken
+		err     string // Returned when serve stops
+		metrics bool   // Whether the metrics are served once the port frees
+	}{
+		{mode: metricsRetry, metrics: true},
+		{mode: metricsDisable},
+		{mode: metricsShutdown, stops: true, err: "metrics server"},
+		{mode: "ignore", stops: true, err: "unknown METRICS_FAILURE_MODE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			metricsFailureMode = tt.mode
+			taken, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer taken.Close()
+			metricsAddr = taken.Addr().String()
+
+			app := fiber.New(fiber.Config{DisableStartupMessage: true})
+			metricsApp := fiber.New(fiber.Config{DisableStartupMessage: true})
+			metricsApp.Get("/ping", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- serve(ctx, app, metricsApp) }()
+
+			// Long enough for several retries
+			select {
+			case err := <-done:
+				if !tt.stops {
+					t.Fatalf("serve stopped with %v while the metrics port was taken", err)
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, expected one containing %q", err, tt.err)
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+				if tt.stops {
+					t.Fatal("serve still running after the metrics server failed")
+				}
+			}
+
+			taken.Close()
+			if tt.metrics {
+				waitFor(t, "the metrics to be served", func() bool { return servesMetrics(metricsAddr) })
+			} else {
+				time.Sleep(100 * time.Millisecond)
+				if servesMetrics(metricsAddr) {
+					t.Fatal("metrics served after they were disabled")
+				}
+			}
+
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("serve stopped with %v, expected a clean shutdown", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("serve still running after the context ended")
+			}
+		})
+	}
+}
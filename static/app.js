@@ -18,7 +18,7 @@ document.getElementById('planButton').addEventListener('click', async () => {
         } else if (!result.upgrade_path || result.upgrade_path.length === 0) {
             document.getElementById('planOutput').innerText = 'No upgrade path found for the provided input.';
         } else {
-            const formattedPlan = formatUpgradePlan(result.upgrade_path);
+            const formattedPlan = formatUpgradePlan(result.upgrade_path) + formatWarnings(result.warnings);
             document.getElementById('planOutput').innerHTML = formattedPlan;
         }
     } catch (error) {
@@ -45,3 +45,17 @@ function formatUpgradePlan(upgradePath) {
 
     return formatted;
 }
+
+// Helper function to format the warnings raised by data constraints
+function formatWarnings(warnings) {
+    if (!warnings || warnings.length === 0) {
+        return '';
+    }
+
+    let formatted = '<br><hr><br>Warnings:<br>';
+    warnings.forEach((warning) => {
+        formatted += `- ${warning.message}<br>`;
+    });
+
+    return formatted;
+}
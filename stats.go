@@ -0,0 +1,335 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// requestRecord is a single handled request kept for usage statistics
+type requestRecord struct {
+	Time     time.Time
+	Route    string
+	Status   int
+	Duration time.Duration
+	usageNote
+}
+
+// usageNote is what a handler reports about its request for the usage
+// statistics: the version combinations it planned and its release notes
+// lookups
+type usageNote struct {
+	Combinations    []versionCombination
+	ChangelogHits   int
+	ChangelogMisses int
+}
+
+// usageLocalsKey holds the request's usageNote in the context locals
+const usageLocalsKey = "usage-note"
+
+// requestUsage returns the usage note of the request, adding one on first use
+func requestUsage(c *fiber.Ctx) *usageNote {
+	if n, ok := c.Locals(usageLocalsKey).(*usageNote); ok {
+		return n
+	}
+	n := &usageNote{}
+	c.Locals(usageLocalsKey, n)
+	return n
+}
+
+// noteUsage records the version combinations of the plan requests a handler
+// parsed, whether they came from the route, the query, or the body
+func noteUsage(c *fiber.Ctx, reqs ...planner.Request) {
+	n := requestUsage(c)
+	for _, req := range reqs {
+		// Route parameters point into fasthttp's reused buffers, so copy
+		// them before keeping them
+		n.Combinations = append(n.Combinations, versionCombination{
+			Platform: strings.Clone(req.Platform),
+			Rancher:  strings.Clone(req.CurrentRancher),
+			K8s:      strings.Clone(req.CurrentK8s),
+		})
+	}
+}
+
+// noteChangelogLookups records release notes lookups in the changelog cache
+func noteChangelogLookups(c *fiber.Ctx, hits, misses int) {
+	n := requestUsage(c)
+	n.ChangelogHits += hits
+	n.ChangelogMisses += misses
+}
+
+// requestLog keeps recent request records in memory, bounded by age and
+// count. Records are kept in a ring buffer of maxRecords entries so that
+// adding one never moves the others.
+type requestLog struct {
+	mu         sync.Mutex
+	records    []requestRecord
+	start      int
+	count      int
+	retention  time.Duration
+	maxRecords int
+}
+
+// usage is the in-process analytics store backing /admin/stats
+var usage = &requestLog{
+	retention:  envDuration("STATS_RETENTION", 24*time.Hour),
+	maxRecords: envInt("STATS_MAX_RECORDS", 100000),
+}
+
+// at returns the i-th oldest record
+func (l *requestLog) at(i int) *requestRecord {
+	return &l.records[(l.start+i)%len(l.records)]
+}
+
+// add appends a record, overwriting the oldest one when the log is full, and
+// drops records that fall outside the retention
+func (l *requestLog) add(r requestRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxRecords < 1 {
+		return
+	}
+	if l.records == nil {
+		l.records = make([]requestRecord, l.maxRecords)
+	}
+
+	if l.count == len(l.records) {
+		*l.at(0) = r
+		l.start = (l.start + 1) % len(l.records)
+	} else {
+		*l.at(l.count) = r
+		l.count++
+	}
+
+	cutoff := r.Time.Add(-l.retention)
+	for l.count > 0 && !l.at(0).Time.After(cutoff) {
+		*l.at(0) = requestRecord{}
+		l.start = (l.start + 1) % len(l.records)
+		l.count--
+	}
+}
+
+// since returns a copy of the records newer than t
+func (l *requestLog) since(t time.Time) []requestRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	idx := sort.Search(l.count, func(i int) bool {
+		return l.at(i).Time.After(t)
+	})
+	records := make([]requestRecord, 0, l.count-idx)
+	for i := idx; i < l.count; i++ {
+		records = append(records, *l.at(i))
+	}
+	return records
+}
+
+// recordUsage is middleware that records every request in the usage log and
+// its duration in request_duration_seconds. Version combinations are those
+// the handler noted, or the route's parameters for handlers that note none.
+func recordUsage(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+
+	route := c.Route().Path
+	requestDuration.WithLabelValues(route, strings.Clone(c.Method()), strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+
+	var note usageNote
+	if n, ok := c.Locals(usageLocalsKey).(*usageNote); ok {
+		note = *n
+	} else if platform := c.Params("platform"); platform != "" {
+		note.Combinations = []versionCombination{{
+			Platform: strings.Clone(platform),
+			Rancher:  strings.Clone(c.Params("rancher")),
+			K8s:      strings.Clone(c.Params("k8s")),
+		}}
+	}
+	usage.add(requestRecord{
+		Time:      start,
+		Route:     route,
+		Status:    status,
+		Duration:  time.Since(start),
+		usageNote: note,
+	})
+	return err
+}
+
+// versionCombination is a platform/Rancher/Kubernetes tuple and its request count
+type versionCombination struct {
+	Platform string `json:"platform"`
+	Rancher  string `json:"rancher"`
+	K8s      string `json:"k8s"`
+	Requests int    `json:"requests"`
+}
+
+// routeStats summarizes the requests handled by a single route
+type routeStats struct {
+	Route     string  `json:"route"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	AvgMillis float64 `json:"avg_latency_ms"`
+	// NotModified counts the 304 responses to conditional requests, the
+	// clients' cache hits
+	NotModified      int     `json:"not_modified"`
+	NotModifiedRatio float64 `json:"not_modified_ratio"`
+}
+
+// cacheStats summarizes the lookups in a cache
+type cacheStats struct {
+	Hits     int     `json:"hits"`
+	Misses   int     `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// usageStats is the response body of /admin/stats
+type usageStats struct {
+	Window          string               `json:"window"`
+	From            time.Time            `json:"from"`
+	To              time.Time            `json:"to"`
+	Requests        int                  `json:"requests"`
+	Errors          int                  `json:"errors"`
+	ErrorRate       float64              `json:"error_rate"`
+	Routes          []routeStats         `json:"routes"`
+	TopCombinations []versionCombination `json:"top_combinations"`
+	ChangelogCache  cacheStats           `json:"changelog_cache"`
+}
+
+// summarizeUsage aggregates the records into usage statistics
+func summarizeUsage(records []requestRecord, top int) ([]routeStats, []versionCombination, cacheStats, int) {
+	type routeAcc struct {
+		requests, errors, notModified int
+		total                         time.Duration
+	}
+	routes := make(map[string]*routeAcc)
+	combos := make(map[versionCombination]int)
+	var changelog cacheStats
+	errors := 0
+
+	for _, r := range records {
+		acc, ok := routes[r.Route]
+		if !ok {
+			acc = &routeAcc{}
+			routes[r.Route] = acc
+		}
+		acc.requests++
+		acc.total += r.Duration
+		if r.Status >= fiber.StatusBadRequest {
+			acc.errors++
+			errors++
+		}
+		if r.Status == fiber.StatusNotModified {
+			acc.notModified++
+		}
+		for _, combo := range r.Combinations {
+			if combo.Platform != "" {
+				combos[combo]++
+			}
+		}
+		changelog.Hits += r.ChangelogHits
+		changelog.Misses += r.ChangelogMisses
+	}
+	if lookups := changelog.Hits + changelog.Misses; lookups > 0 {
+		changelog.HitRatio = float64(changelog.Hits) / float64(lookups)
+	}
+
+	routeList := make([]routeStats, 0, len(routes))
+	for route, acc := range routes {
+		routeList = append(routeList, routeStats{
+			Route:     route,
+			Requests:  acc.requests,
+			Errors:    acc.errors,
+			ErrorRate: float64(acc.errors) / float64(acc.requests),
+			AvgMillis: float64(acc.total.Microseconds()) / float64(acc.requests) / 1000,
+
+			NotModified:      acc.notModified,
+			NotModifiedRatio: float64(acc.notModified) / float64(acc.requests),
+		})
+	}
+	sort.Slice(routeList, func(i, j int) bool {
+		if routeList[i].Requests != routeList[j].Requests {
+			return routeList[i].Requests > routeList[j].Requests
+		}
+		return routeList[i].Route < routeList[j].Route
+	})
+
+	comboList := make([]versionCombination, 0, len(combos))
+	for combo, n := range combos {
+		combo.Requests = n
+		comboList = append(comboList, combo)
+	}
+	sort.Slice(comboList, func(i, j int) bool {
+		a, b := comboList[i], comboList[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		if a.Rancher != b.Rancher {
+			return a.Rancher < b.Rancher
+		}
+		return a.K8s < b.K8s
+	})
+	if len(comboList) > top {
+		comboList = comboList[:top]
+	}
+
+	return routeList, comboList, changelog, errors
+}
+
+// handleAdminStats serves usage statistics for the window given in ?window= (default 1h)
+func handleAdminStats(c *fiber.Ctx) error {
+	window, err := time.ParseDuration(c.Query("window", "1h"))
+	if err != nil || window <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid window, expected a duration such as 15m, 1h or 24h",
+		})
+	}
+	if window > usage.retention {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "window exceeds the retention of " + usage.retention.String(),
+		})
+	}
+
+	top := c.QueryInt("top", 10)
+	if top < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid top, expected a positive number of combinations",
+		})
+	}
+
+	now := time.Now()
+	records := usage.since(now.Add(-window))
+	routes, combos, changelog, errors := summarizeUsage(records, top)
+
+	stats := usageStats{
+		Window:          window.String(),
+		From:            now.Add(-window),
+		To:              now,
+		Requests:        len(records),
+		Errors:          errors,
+		Routes:          routes,
+		TopCombinations: combos,
+		ChangelogCache:  changelog,
+	}
+	if len(records) > 0 {
+		stats.ErrorRate = float64(errors) / float64(len(records))
+	}
+	return c.JSON(stats)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// adminStats fetches /admin/stats with the query from the app
+func adminStats(t *testing.T, app *fiber.App, query string) (int, usageStats) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/stats"+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats usageStats
+	if resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, stats
+}
+
+// TestAdminStats checks that the version combinations of plans are recorded
+// whether they come from the route or the body, that batches record every
+// cluster, and that 304 responses are reported per route
+func TestAdminStats(t *testing.T) {
+	defer func(l *requestLog) { usage = l }(usage)
+	usage = &requestLog{retention: time.Hour, maxRecords: 100}
+
+	ds := testDataset(t)
+	app := fiber.New()
+	app.Use(recordUsage)
+	app.Get("/api/plan-upgrade/:platform/:rancher/:k8s", handlePlanUpgrade(ds, planUpgradeParams))
+	app.Post("/api/plan-upgrade", handlePlanUpgrade(ds, planUpgradeBody))
+	app.Post("/api/plan-batch", handlePlanBatch(ds))
+	app.Get("/api/versions", conditionalOnDataset(ds), func(c *fiber.Ctx) error { return c.SendString("versions") })
+	app.Get("/admin/stats", handleAdminStats)
+
+	requests := []struct {
+		method, url, body string
+		header            map[string]string
+	}{
+		{method: fiber.MethodGet, url: "/api/plan-upgrade/rke2/2.8.5/v1.27.16"},
+		{method: fiber.MethodPost, url: "/api/plan-upgrade", body: `{"platform": "rke2", "current_rancher": "2.8.5", "current_k8s": "v1.27.16"}`},
+		{method: fiber.MethodPost, url: "/api/plan-batch", body: `{"clusters": [
+			{"platform": "rke2", "current_rancher": "2.8.5", "current_k8s": "v1.27.16"},
+			{"platform": "k3s", "current_rancher": "2.7.5", "current_k8s": "v1.25.9"}
+		]}`},
+		{method: fiber.MethodGet, url: "/api/versions"},
+		{method: fiber.MethodGet, url: "/api/versions", header: map[string]string{fiber.HeaderIfNoneMatch: "*"}},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.url, strings.NewReader(r.body))
+		if r.body != "" {
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		}
+		for k, v := range r.header {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	status, stats := adminStats(t, app, "?window=1h&top=5")
+	if status != fiber.StatusOK {
+		t.Fatalf("got %d, expected 200", status)
+	}
+	if stats.Requests != len(requests) {
+		t.Errorf("%d requests, expected %d", stats.Requests, len(requests))
+	}
+	combos := []versionCombination{
+		{Platform: "rke2", Rancher: "2.8.5", K8s: "v1.27.16", Requests: 3},
+		{Platform: "k3s", Rancher: "2.7.5", K8s: "v1.25.9", Requests: 1},
+	}
+	if !reflect.DeepEqual(stats.TopCombinations, combos) {
+		t.Errorf("top combinations %+v, expected %+v", stats.TopCombinations, combos)
+	}
+	found := false
+	for _, r := range stats.Routes {
+		if r.Route != "/api/versions" {
+			continue
+		}
+		found = true
+		if r.Requests != 2 || r.NotModified != 1 || r.NotModifiedRatio != 0.5 {
+			t.Errorf("/api/versions: %d requests, %d not modified, ratio %v; expected 2, 1, 0.5", r.Requests, r.NotModified, r.NotModifiedRatio)
+		}
+	}
+	if !found {
+		t.Errorf("no stats for /api/versions in %+v", stats.Routes)
+	}
+}
+
+// TestAdminStatsQuery checks the validation of the window and top
+func TestAdminStatsQuery(t *testing.T) {
+	defer func(l *requestLog) { usage = l }(usage)
+	usage = &requestLog{retention: time.Hour, maxRecords: 100}
+
+	app := fiber.New()
+	app.Get("/admin/stats", handleAdminStats)
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{query: "", status: fiber.StatusOK},
+		{query: "?window=15m&top=1", status: fiber.StatusOK},
+		{query: "?window=soon", status: fiber.StatusBadRequest},
+		{query: "?window=-1h", status: fiber.StatusBadRequest},
+		{query: "?window=2h", status: fiber.StatusBadRequest}, // Past the retention
+		{query: "?top=0", status: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if status, _ := adminStats(t, app, tt.query); status != tt.status {
+				t.Fatalf("got %d, expected %d", status, tt.status)
+			}
+		})
+	}
+}
+
+// TestSummarizeUsage checks the error, 304, and changelog cache ratios and
+// the ranking of version combinations
+func TestSummarizeUsage(t *testing.T) {
+	combo := func(platform, rancher, k8s string) versionCombination {
+		return versionCombination{Platform: platform, Rancher: rancher, K8s: k8s}
+	}
+	records := []requestRecord{
+		{Route: "/plan", Status: 200, Duration: 10 * time.Millisecond, usageNote: usageNote{
+			Combinations: []versionCombination{combo("rke2", "2.8.5", "v1.27.16")}, ChangelogHits: 3, ChangelogMisses: 1,
+		}},
+		{Route: "/plan", Status: 422, Duration: 30 * time.Millisecond, usageNote: usageNote{
+			Combinations: []versionCombination{combo("k3s", "2.7.5", "v1.25.9"), combo("rke2", "2.8.5", "v1.27.16")},
+		}},
+		{Route: "/data", Status: 304},
+		{Route: "/data", Status: 200},
+		{Route: "/data", Status: 304},
+		{Route: "/data", Status: 200},
+	}
+	routes, combos, changelog, errors := summarizeUsage(records, 1)
+
+	expectedRoutes := []routeStats{
+		{Route: "/data", Requests: 4, NotModified: 2, NotModifiedRatio: 0.5},
+		{Route: "/plan", Requests: 2, Errors: 1, ErrorRate: 0.5, AvgMillis: 20},
+	}
+	if !reflect.DeepEqual(routes, expectedRoutes) {
+		t.Errorf("routes %+v, expected %+v", routes, expectedRoutes)
+	}
+	if expected := []versionCombination{{Platform: "rke2", Rancher: "2.8.5", K8s: "v1.27.16", Requests: 2}}; !reflect.DeepEqual(combos, expected) {
+		t.Errorf("top combinations %+v, expected %+v", combos, expected)
+	}
+	if expected := (cacheStats{Hits: 3, Misses: 1, HitRatio: 0.75}); changelog != expected {
+		t.Errorf("changelog cache %+v, expected %+v", changelog, expected)
+	}
+	if errors != 1 {
+		t.Errorf("%d errors, expected 1", errors)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// runTUI plans an upgrade interactively in the terminal: the platform and
+// versions are picked from the data, and the plan is browsed step by step
+// and exported to a file, for operators without a browser for the web UI
+func runTUI(args []string, in io.Reader, out io.Writer) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	data := fs.String("data", upgradePathsFile, "dataset to plan against")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	paths, err := loadUpgradePathsFile(*data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p := planner.New(paths, planner.Options{Aliases: envMap("PLATFORM_ALIASES")})
+
+	t := &tui{in: bufio.NewScanner(in), out: out}
+	req := planner.Request{}
+	var ok bool
+	if req.Platform, ok = t.choose("Platform", planner.RegisteredPlatforms()); !ok {
+		return 0
+	}
+	if req.CurrentRancher, ok = t.choose("Current Rancher version", p.Versions()); !ok {
+		return 0
+	}
+	if req.CurrentK8s, ok = t.choose("Current Kubernetes version", paths.Releases[planner.CanonicalPlatform(req.Platform)]); !ok {
+		return 0
+	}
+
+	plan, err := p.Plan(req)
+	if err != nil {
+		fmt.Fprintf(out, "\nNo plan: %v\n", err)
+		return 1
+	}
+	t.browse(plan)
+	return 0
+}
+
+// tui reads the operator's answers line by line
+type tui struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// ask prints the prompt and returns the trimmed answer, or false at the end
+// of the input
+func (t *tui) ask(prompt string) (string, bool) {
+	fmt.Fprintf(t.out, "%s> ", prompt)
+	if !t.in.Scan() {
+		fmt.Fprintln(t.out)
+		return "", false
+	}
+	return strings.TrimSpace(t.in.Text()), true
+}
+
+// choose lists the options by number and returns the one picked, by number
+// or typed out. Without options any non-empty answer is taken.
+func (t *tui) choose(title string, options []string) (string, bool) {
+	fmt.Fprintf(t.out, "\n%s\n", title)
+	for i, o := range options {
+		fmt.Fprintf(t.out, "  %3d) %s\n", i+1, o)
+	}
+	for {
+		answer, ok := t.ask(title)
+		if !ok || answer == "q" {
+			return "", false
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], true
+		}
+		if answer != "" {
+			return answer, true
+		}
+	}
+}
+
+// browse shows the plan one step at a time until the operator quits
+func (t *tui) browse(plan *planner.Plan) {
+	fmt.Fprintf(t.out, "\nPlan for %s: %d steps, %d warnings\n", plan.Platform, len(plan.Steps), len(plan.Warnings))
+	fmt.Fprintln(t.out, "Commands: n next, p previous, <number> go to step, l list steps, w warnings, e <file> export, q quit")
+	if len(plan.Steps) == 0 {
+		t.showWarnings(plan, -1)
+	}
+
+	current := 0
+	if len(plan.Steps) > 0 {
+		t.showStep(plan, current)
+	}
+	for {
+		answer, ok := t.ask("plan")
+		if !ok {
+			return
+		}
+		command, arg, _ := strings.Cut(answer, " ")
+		switch command {
+		case "q":
+			return
+		case "n", "":
+			if current+1 < len(plan.Steps) {
+				current++
+				t.showStep(plan, current)
+			} else {
+				fmt.Fprintln(t.out, "This is the last step")
+			}
+		case "p":
+			if current > 0 {
+				current--
+				t.showStep(plan, current)
+			} else {
+				fmt.Fprintln(t.out, "This is the first step")
+			}
+		case "l":
+			for i, s := range plan.Steps {
+				fmt.Fprintf(t.out, "  %3d) %s %s -> %s\n", i+1, s.Type, s.From, s.To)
+			}
+		case "w":
+			if len(plan.Warnings) == 0 {
+				fmt.Fprintln(t.out, "The plan has no warnings")
+			}
+			t.showWarnings(plan, -2)
+		case "e":
+			t.export(plan, strings.TrimSpace(arg))
+		default:
+			n, err := strconv.Atoi(command)
+			if err != nil || n < 1 || n > len(plan.Steps) {
+				fmt.Fprintf(t.out, "Unknown command %q\n", answer)
+				continue
+			}
+			current = n - 1
+			t.showStep(plan, current)
+		}
+	}
+}
+
+// showStep prints a step with its notes, checks, and warnings
+func (t *tui) showStep(plan *planner.Plan, i int) {
+	s := plan.Steps[i]
+	fmt.Fprintf(t.out, "\nStep %d of %d: %s %s -> %s\n", i+1, len(plan.Steps), strings.TrimSpace(s.Platform+" "+s.Type), s.From, s.To)
+	if len(s.DependsOn) > 0 {
+		fmt.Fprintf(t.out, "  After: %s\n", strings.Join(s.DependsOn, ", "))
+	}
+	if s.SupportPhase != "" {
+		fmt.Fprintf(t.out, "  Support phase: %s\n", s.SupportPhase)
+	}
+	for _, n := range s.Notes {
+		fmt.Fprintf(t.out, "  Note: %s\n", n)
+	}
+	for _, v := range s.Verify {
+		fmt.Fprintf(t.out, "  Verify: %s\n", v)
+	}
+	t.showWarnings(plan, i)
+}
+
+// showWarnings prints the warnings of step i, or every warning when i is -2
+func (t *tui) showWarnings(plan *planner.Plan, i int) {
+	for _, w := range plan.Warnings {
+		if i == -2 || w.Step == i {
+			fmt.Fprintf(t.out, "  Warning [%s]: %s\n", w.Rule, w.Message)
+		}
+	}
+}
+
+// export writes the plan as indented JSON to the file
+func (t *tui) export(plan *planner.Plan, file string) {
+	if file == "" {
+		fmt.Fprintln(t.out, "Usage: e <file>")
+		return
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err == nil {
+		err = os.WriteFile(file, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(t.out, "Export failed: %v\n", err)
+		return
+	}
+	fmt.Fprintf(t.out, "Plan written to %s\n", file)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// Releases publish the binary of every platform as
+// rancher-upgrade-tool-<os>-<arch>, the compatibility data as
+// upgrade-paths.json, the release version in VERSION, their SHA-256 sums in
+// checksums.txt, and the base64 ed25519 signature of checksums.txt in
+// checksums.txt.sig. Nothing is installed without a valid signature by
+// releasePublicKey, or by the key given with -public-key.
+var updateRepository = envString("UPDATE_REPOSITORY", "SupportTools/rancher-upgrade-tool")
+
+// releasePublicKey is the base64 ed25519 public key releases are signed
+// with, set with -ldflags "-X main.releasePublicKey=..." by the release job
+var releasePublicKey = ""
+
+// Release asset names
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+	dataAsset      = "upgrade-paths.json"
+	versionAsset   = "VERSION"
+)
+
+// binaryAsset is the name of the release binary for the running platform
+func binaryAsset() string {
+	name := fmt.Sprintf("rancher-upgrade-tool-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// releaseSource fetches the assets of a release
+type releaseSource interface {
+	// Tag returns the release's version tag, empty when unknown
+	Tag() string
+	// Asset returns the content of the named asset, or errAssetMissing
+	Asset(ctx context.Context, name string) ([]byte, error)
+}
+
+var errAssetMissing = errors.New("asset not in the release")
+
+// runUpdate installs a newer binary and compatibility data from the latest
+// release, or from a directory holding release assets with -from for sites
+// without internet access
+func runUpdate(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	from := fs.String("from", "", "directory holding the release assets, for air-gapped installs")
+	check := fs.Bool("check", false, "only report available updates")
+	dataPath := fs.String("data", upgradePathsFile, "compatibility data file to update")
+	skipBinary := fs.Bool("skip-binary", false, "do not update the binary")
+	skipData := fs.Bool("skip-data", false, "do not update the compatibility data")
+	force := fs.Bool("force", false, "install the release even when it is the same version, or over a development build")
+	publicKey := fs.String("public-key", "", "base64 ed25519 key of the release signer, replacing the key embedded in the build")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	encoded := releasePublicKey
+	if *publicKey != "" {
+		encoded = *publicKey
+	}
+	if encoded == "" {
+		fmt.Fprintln(os.Stderr, "this build has no release signing key, name the base64 ed25519 key of the release signer with -public-key")
+		return 2
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		fmt.Fprintln(os.Stderr, "the release signing key is not a base64 ed25519 public key")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	var src releaseSource
+	if *from != "" {
+		src = dirRelease{dir: *from}
+	} else {
+		client, err := newOutboundClient()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		outbound = client
+		rel, err := latestRelease(ctx, updateRepository)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		src = rel
+	}
+
+	sums, err := releaseChecksums(ctx, src, ed25519.PublicKey(key))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tag, err := releaseVersion(ctx, src, sums)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	failed := false
+	if !*skipBinary {
+		if err := updateBinary(ctx, src, sums, tag, *check, *force, out); err != nil {
+			fmt.Fprintf(os.Stderr, "binary: %v\n", err)
+			failed = true
+		}
+	}
+	if !*skipData {
+		if err := updateData(ctx, src, sums, *dataPath, *check, *force, out); err != nil {
+			fmt.Fprintf(os.Stderr, "data: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// releaseChecksums returns the SHA-256 sums of the release by asset name
+// once their signature by the key is verified
+func releaseChecksums(ctx context.Context, src releaseSource, key ed25519.PublicKey) (map[string]string, error) {
+	data, err := src.Asset(ctx, checksumsAsset)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", checksumsAsset, err)
+	}
+	sig, err := src.Asset(ctx, signatureAsset)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", signatureAsset, err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = decoded
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return nil, fmt.Errorf("the signature of %s is not valid", checksumsAsset)
+	}
+
+	// sha256sum format: "<hex>  <name>", with a "*" before binary mode names
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums, nil
+}
+
+// releaseVersion returns the version of the release from its signed VERSION
+// asset. A release whose tag differs from the signed version is refused, so
+// the assets of an older release cannot be served as a newer one.
+func releaseVersion(ctx context.Context, src releaseSource, sums map[string]string) (string, error) {
+	data, err := verifiedAsset(ctx, src, sums, versionAsset)
+	if err != nil {
+		return "", err
+	}
+	signed := strings.TrimSpace(string(data))
+	if signed == "" {
+		return "", fmt.Errorf("the release's %s is empty", versionAsset)
+	}
+	if tag := src.Tag(); tag != "" && tag != signed {
+		return "", fmt.Errorf("the release is tagged %s but signed as version %s", tag, signed)
+	}
+	return signed, nil
+}
+
+// verifiedAsset fetches the asset and checks it against its checksum
+func verifiedAsset(ctx context.Context, src releaseSource, sums map[string]string, name string) ([]byte, error) {
+	want, ok := sums[name]
+	if !ok {
+		return nil, fmt.Errorf("%s has no checksum in %s", name, checksumsAsset)
+	}
+	data, err := src.Asset(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("%s has checksum %s, expected %s", name, got, want)
+	}
+	return data, nil
+}
+
+// updateBinary replaces the running binary with the release's when the
+// release is newer. Development builds and the same version are only
+// replaced with force; older releases never are.
+func updateBinary(ctx context.Context, src releaseSource, sums map[string]string, tag string, check, force bool, out io.Writer) error {
+	current := planner.Version
+	newer, err := isNewerVersion(current, tag)
+	var older *olderReleaseError
+	switch {
+	case errors.As(err, &older):
+		return err
+	case err != nil && !force:
+		return err
+	case err == nil && !newer && !force:
+		fmt.Fprintf(out, "Binary %s is up to date\n", current)
+		return nil
+	}
+	if check {
+		fmt.Fprintf(out, "Binary %s can be updated to %s\n", current, tag)
+		return nil
+	}
+
+	data, err := verifiedAsset(ctx, src, sums, binaryAsset())
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if err := replaceExecutable(exe, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Binary updated from %s to %s\n", current, tag)
+	return nil
+}
+
+// olderReleaseError refuses a release older than the running build
+type olderReleaseError struct {
+	Current, Release string
+}
+
+func (e *olderReleaseError) Error() string {
+	return fmt.Sprintf("the release %s is older than the running build %s", e.Release, e.Current)
+}
+
+// isNewerVersion reports whether the release tag is newer than the running
+// version. Unversioned builds cannot be compared, and an older release is an
+// olderReleaseError.
+func isNewerVersion(current, tag string) (bool, error) {
+	if tag == "" {
+		return false, errors.New("the release has no version, update with -force")
+	}
+	cur, err := version.NewVersion(current)
+	if err != nil {
+		return false, fmt.Errorf("the running build %q has no version to compare, update with -force", current)
+	}
+	rel, err := version.NewVersion(tag)
+	if err != nil {
+		return false, fmt.Errorf("the release version %q is not a version", tag)
+	}
+	if rel.LessThan(cur) {
+		return false, &olderReleaseError{Current: current, Release: tag}
+	}
+	return rel.GreaterThan(cur), nil
+}
+
+// updateData replaces the compatibility data file with the release's when it
+// differs and is not older, as declared by the data's version
+func updateData(ctx context.Context, src releaseSource, sums map[string]string, path string, check, force bool, out io.Writer) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory of fragments, update a single file with -data", path)
+	}
+	data, err := verifiedAsset(ctx, src, sums, dataAsset)
+	if err != nil {
+		return err
+	}
+	var bundled planner.UpgradePaths
+	if err := json.Unmarshal(data, &bundled); err != nil {
+		return fmt.Errorf("the release data is not valid: %v", err)
+	}
+	if err := bundled.Validate(); err != nil {
+		return fmt.Errorf("the release data is not valid: %v", err)
+	}
+
+	// Missing local data is installed like outdated data
+	local, err := loadUpgradePathsFile(path)
+	if err == nil && !force {
+		if planner.New(local, planner.Options{}).DatasetHash() == planner.New(bundled, planner.Options{}).DatasetHash() {
+			fmt.Fprintf(out, "Data %s is up to date\n", path)
+			return nil
+		}
+		if older, _ := version.NewVersion(bundled.Version); older != nil {
+			if cur, _ := version.NewVersion(local.Version); cur != nil && !older.GreaterThan(cur) {
+				fmt.Fprintf(out, "Data %s version %s is newer than the release's %s\n", path, local.Version, bundled.Version)
+				return nil
+			}
+		}
+	}
+	if check {
+		fmt.Fprintf(out, "Data %s can be updated to version %s\n", path, orNone(bundled.Version))
+		return nil
+	}
+	if err := replaceFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Data %s updated to version %s\n", path, orNone(bundled.Version))
+	return nil
+}
+
+// replaceFile atomically replaces the file with the data, writing it next to
+// the file first
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// replaceExecutable replaces the running binary. Windows does not replace a
+// running executable, but lets it be renamed, so it is moved aside to
+// <name>.old first, to be removed by the next update.
+func replaceExecutable(path string, data []byte) error {
+	if runtime.GOOS != "windows" {
+		return replaceFile(path, data, 0o755)
+	}
+	old := path + ".old"
+	if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := replaceFile(path, data, 0o755); err != nil {
+		// Put the running binary back
+		if rerr := os.Rename(old, path); rerr != nil {
+			return fmt.Errorf("%v, and restoring %s failed: %v", err, path, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// dirRelease reads release assets from a local directory. Its version is
+// taken from the VERSION asset, and verified like the other assets.
+type dirRelease struct {
+	dir string
+}
+
+// Tag returns the contents of the VERSION file
+func (d dirRelease) Tag() string {
+	data, err := os.ReadFile(filepath.Join(d.dir, "VERSION"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Asset reads the asset file from the directory
+func (d dirRelease) Asset(_ context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, name))
+	if os.IsNotExist(err) {
+		return nil, errAssetMissing
+	}
+	return data, err
+}
+
+// githubRelease is a release published on GitHub
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// latestRelease fetches the latest release of the repository from the GitHub
+// API release notes are fetched from
+func latestRelease(ctx context.Context, repo string) (*githubRelease, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/releases/latest", changelogAPI, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if githubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+githubToken)
+	}
+	resp, err := outbound.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the latest release of %s: %s", repo, resp.Status)
+	}
+	var rel githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("decoding the latest release of %s: %v", repo, err)
+	}
+	return &rel, nil
+}
+
+// Tag returns the release's tag
+func (r *githubRelease) Tag() string {
+	return r.TagName
+}
+
+// Asset downloads the asset of the release
+func (r *githubRelease) Asset(ctx context.Context, name string) ([]byte, error) {
+	for _, a := range r.Assets {
+		if a.Name != name {
+			continue
+		}
+		resp, err := outbound.Get(ctx, a.URL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("downloading %s: %s", name, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	return nil, errAssetMissing
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// signedRelease writes the assets to a directory with their checksums signed
+// by the key, as the release job publishes them
+func signedRelease(t *testing.T, key ed25519.PrivateKey, assets map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sums strings.Builder
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(assets[name]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256([]byte(assets[name]))
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsAsset), []byte(sums.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(sums.String())))
+	if err := os.WriteFile(filepath.Join(dir, signatureAsset), []byte(sig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// tagged overrides the tag of a release, as a GitHub release's tag name
+type tagged struct {
+	releaseSource
+	tag string
+}
+
+func (r tagged) Tag() string { return r.tag }
+
+// TestReleaseVerification checks that assets are only returned when the
+// checksums are signed by the key, the asset matches its checksum, and the
+// signed version matches the release tag
+func TestReleaseVerification(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := map[string]string{
+		binaryAsset(): "binary",
+		dataAsset:     "{}",
+		versionAsset:  "v1.4.0\n",
+	}
+
+	tests := []struct {
+		name    string
+		key     ed25519.PublicKey
+		tamper  func(t *testing.T, dir string)
+		tag     string // tag of the release, the VERSION asset's when empty
+		asset   string // asset to fetch, the binary's when empty
+		version string
+		err     string
+	}{
+		{name: "valid", key: pub, version: "v1.4.0"},
+		{name: "tagged as signed", key: pub, tag: "v1.4.0", version: "v1.4.0"},
+		{name: "wrong key", key: otherPub, err: "signature of checksums.txt is not valid"},
+		{
+			name: "tampered checksum",
+			key:  pub,
+			tamper: func(t *testing.T, dir string) {
+				path := filepath.Join(dir, checksumsAsset)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sum := sha256.Sum256([]byte("evil"))
+				lines := strings.SplitAfter(string(data), "\n")
+				for i, line := range lines {
+					if strings.HasSuffix(strings.TrimSpace(line), binaryAsset()) {
+						lines[i] = hex.EncodeToString(sum[:]) + "  " + binaryAsset() + "\n"
+					}
+				}
+				if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: "signature of checksums.txt is not valid",
+		},
+		{
+			name: "missing signature",
+			key:  pub,
+			tamper: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, signatureAsset)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: "fetching checksums.txt.sig",
+		},
+		{
+			name: "tampered asset",
+			key:  pub,
+			tamper: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, binaryAsset()), []byte("evil"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			version: "v1.4.0",
+			err:     "has checksum",
+		},
+		{name: "asset without checksum", key: pub, asset: "extra", version: "v1.4.0", err: "extra has no checksum"},
+		{name: "older release served as newer", key: pub, tag: "v1.5.0", err: "tagged v1.5.0 but signed as version v1.4.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := signedRelease(t, key, assets)
+			if tt.tamper != nil {
+				tt.tamper(t, dir)
+			}
+			var src releaseSource = dirRelease{dir: dir}
+			if tt.tag != "" {
+				src = tagged{releaseSource: src, tag: tt.tag}
+			}
+			asset := tt.asset
+			if asset == "" {
+				asset = binaryAsset()
+			}
+
+			ctx := context.Background()
+			err := func() error {
+				sums, err := releaseChecksums(ctx, src, tt.key)
+				if err != nil {
+					return err
+				}
+				version, err := releaseVersion(ctx, src, sums)
+				if err != nil {
+					return err
+				}
+				if version != tt.version {
+					t.Errorf("version %q, expected %q", version, tt.version)
+				}
+				data, err := verifiedAsset(ctx, src, sums, asset)
+				if err != nil {
+					return err
+				}
+				if string(data) != assets[asset] {
+					t.Errorf("asset %q, expected %q", data, assets[asset])
+				}
+				return nil
+			}()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error %v, expected one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestUpdateBinaryVersions checks which releases replace the running binary,
+// with and without force
+func TestUpdateBinaryVersions(t *testing.T) {
+	defer func(v string) { planner.Version = v }(planner.Version)
+
+	tests := []struct {
+		name    string
+		current string
+		tag     string
+		force   bool
+		output  string
+		older   bool
+	}{
+		{name: "newer", current: "v1.3.0", tag: "v1.4.0", output: "can be updated to v1.4.0"},
+		{name: "same", current: "v1.4.0", tag: "v1.4.0", output: "is up to date"},
+		{name: "same forced", current: "v1.4.0", tag: "v1.4.0", force: true, output: "can be updated to v1.4.0"},
+		{name: "older", current: "v1.5.0", tag: "v1.4.0", older: true},
+		{name: "older forced", current: "v1.5.0", tag: "v1.4.0", force: true, older: true},
+		{name: "development build forced", current: "dev", tag: "v1.4.0", force: true, output: "can be updated to v1.4.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner.Version = tt.current
+			var out bytes.Buffer
+			// check stops before anything is fetched or replaced
+			err := updateBinary(context.Background(), nil, nil, tt.tag, true, tt.force, &out)
+			var older *olderReleaseError
+			if tt.older {
+				if !errors.As(err, &older) {
+					t.Fatalf("error %v, expected the release to be refused as older", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Fatalf("output %q, expected %q", out.String(), tt.output)
+			}
+		})
+	}
+
+	planner.Version = "dev"
+	if err := updateBinary(context.Background(), nil, nil, "v1.4.0", true, false, &bytes.Buffer{}); err == nil {
+		t.Fatal("a development build was updated without force")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// buildDate is when the binary was built, set with
+// -ldflags "-X main.buildDate=..."; the version and git SHA are those of the
+// planner package
+var buildDate = ""
+
+// buildInfo identifies the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuild returns the build info, falling back to the revision the Go
+// toolchain stamps into binaries built from a git checkout when no commit
+// was set at build time
+func currentBuild() buildInfo {
+	info := buildInfo{Version: planner.Version, Commit: planner.Commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok && info.Commit == "" {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" {
+				info.Commit = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// handleVersion returns the build info and the compatibility data in use
+func handleVersion(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_, provenance := ds.Snapshot()
+		return c.JSON(fiber.Map{"build": currentBuild(), "dataset": provenance})
+	}
+}
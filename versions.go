@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// rancherVersion is the response body of /api/versions/:rancher
+type rancherVersion struct {
+	Version string `json:"version"` // As listed in the data
+	planner.RancherManagerVersion
+}
+
+// handleVersions lists every Rancher version in the data, ascending
+func handleVersions(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"versions": ds.Planner().Versions(),
+		})
+	}
+}
+
+// handleRancherVersion returns the platforms and Kubernetes ranges a Rancher
+// version supports, with the rest of its data
+func handleRancherVersion(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, r, err := ds.Planner().RancherVersion(c.Params("rancher"))
+		if err != nil {
+			status := fiber.StatusBadRequest
+			var unknown *planner.UnknownRancherVersionError
+			var ahead *planner.VersionAheadError
+			if errors.As(err, &unknown) || errors.As(err, &ahead) {
+				status = fiber.StatusNotFound
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(rancherVersion{Version: key, RancherManagerVersion: r})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// githubWebhookSecret verifies GitHub webhook deliveries; the webhook
+// endpoint is disabled without it
+var githubWebhookSecret = envString("GITHUB_WEBHOOK_SECRET", "")
+
+// watchedRepositories maps the repositories whose releases are checked
+// against the data to the component they release
+var watchedRepositories = map[string]string{
+	"rancher/rancher": "rancher",
+	"rancher/rke2":    "rke2",
+	"k3s-io/k3s":      "k3s",
+}
+
+// releaseEvent is the part of a GitHub release event the webhook reads
+type releaseEvent struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
+	} `json:"release"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// webhookResult is the response to a handled release event
+type webhookResult struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	InData     bool   `json:"in_data"` // Whether the data served by this instance lists the release
+}
+
+// validSignature reports whether the X-Hub-Signature-256 header is the
+// HMAC-SHA256 of the body under the secret
+func validSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// handleGitHubWebhook records the releases the watched repositories publish
+// that the compatibility data does not list yet. It does not change the
+// data, which is updated by rolling out a new data file.
+func handleGitHubWebhook(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !validSignature(githubWebhookSecret, c.Body(), c.Get("X-Hub-Signature-256")) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid webhook signature",
+			})
+		}
+
+		switch event := c.Get("X-GitHub-Event"); event {
+		case "ping":
+			return c.JSON(fiber.Map{"status": "pong"})
+		case "release":
+		default:
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"status": fmt.Sprintf("ignored %q event", event),
+			})
+		}
+
+		var ev releaseEvent
+		if err := json.Unmarshal(c.Body(), &ev); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid release event: %v", err),
+			})
+		}
+		component, ok := watchedRepositories[strings.ToLower(ev.Repository.FullName)]
+		if !ok || ev.Action != "published" || ev.Release.Draft {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"status": fmt.Sprintf("ignored %s release event from %s", ev.Action, ev.Repository.FullName),
+			})
+		}
+
+		result := webhookResult{Repository: ev.Repository.FullName, Tag: ev.Release.TagName}
+		result.InData = ds.CheckRelease(component, ev.Release.TagName)
+		if !result.InData {
+			log.Printf("%s %s is not in the compatibility data yet", result.Repository, result.Tag)
+		}
+		return c.JSON(result)
+	}
+}
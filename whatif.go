@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/supporttools/rancher-upgrade-tool/pkg/planner"
+)
+
+// whatIfRequest is the request body of /api/plan-what-if
+type whatIfRequest struct {
+	Request   planner.Request      `json:"request"`
+	Overrides planner.UpgradePaths `json:"overrides"`
+}
+
+// handleWhatIf plans against the current data merged with the overrides in
+// the request body, such as an unreleased Rancher version with proposed
+// ranges. The merged data is discarded after planning and the plan is marked
+// hypothetical.
+func handleWhatIf(ds *dataset) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req whatIfRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid request body: %v", err),
+			})
+		}
+		if err := req.Overrides.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid overrides: %v", err),
+			})
+		}
+		noteUsage(c, req.Request)
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		plan, err := ds.WhatIf(req.Overrides).PlanContext(ctx, req.Request)
+		recordPlanOutcome(err)
+		if err != nil {
+			return c.Status(planErrorStatus(err)).JSON(planErrorBody(err))
+		}
+		plan.Meta.Hypothetical = true
+		return c.JSON(plan)
+	}
+}